@@ -8,11 +8,24 @@ import (
 )
 
 func main() {
+	command := app.Run
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "verify-audit":
+			command = app.VerifyAudit
+			args = args[1:]
+		case "export-jwks":
+			command = app.ExportJwks
+			args = args[1:]
+		}
+	}
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	var config_path string
-	flag.StringVar(&config_path, "config", os.Getenv("CONFIG_PATH"), "Config path")
-	flag.Parse()
+	flags.StringVar(&config_path, "config", os.Getenv("CONFIG_PATH"), "Config path")
+	flags.Parse(args)
 	if config_path == "" {
 		config_path = ".env"
 	}
-	app.Run(config_path)
+	command(config_path)
 }
@@ -0,0 +1,164 @@
+package http_adapters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIpKey struct{}
+
+const (
+	ForwardedHeader     = "Forwarded"
+	XForwardedForHeader = "X-Forwarded-For"
+	XRealIpHeader       = "X-Real-IP"
+)
+
+// Определяет ip-адрес клиента. Заголовок прокси учитывается только
+// если запрос пришел от доверенного адреса.
+type ClientIpResolver struct {
+	trustedProxies []netip.Prefix
+	chain          func(req *http.Request) []string
+}
+
+// Читается только заголовок `header`, который выставляет доверенный прокси,
+// иначе клиент мог бы подменить адрес другим заголовком.
+// Принимает CIDR или отдельные адреса прокси.
+func NewClientIpResolver(header string, trustedProxies []string) (*ClientIpResolver, error) {
+	var chain func(req *http.Request) []string
+	switch {
+	case strings.EqualFold(header, ForwardedHeader):
+		chain = forwardedChain
+	case strings.EqualFold(header, XForwardedForHeader):
+		chain = xForwardedForChain
+	case strings.EqualFold(header, XRealIpHeader):
+		chain = xRealIpChain
+	default:
+		return nil, fmt.Errorf("unsupported client ip header %q", header)
+	}
+	prefixes := make([]netip.Prefix, 0, len(trustedProxies))
+	for _, p := range trustedProxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return &ClientIpResolver{
+		trustedProxies: prefixes,
+		chain:          chain,
+	}, nil
+}
+
+func (r *ClientIpResolver) Resolve(req *http.Request) string {
+	remote, ok := parseAddr(req.RemoteAddr)
+	if !ok {
+		return stripPort(req.RemoteAddr)
+	}
+	if !r.trusted(remote) {
+		return remote.String()
+	}
+	// Цепочка просматривается справа налево, первый недоверенный
+	// адрес считается адресом клиента
+	client := remote
+	chain := r.chain(req)
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(chain[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !r.trusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (r *ClientIpResolver) trusted(addr netip.Addr) bool {
+	for _, p := range r.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7239
+func forwardedChain(req *http.Request) []string {
+	var chain []string
+	for _, header := range req.Header.Values(ForwardedHeader) {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+func xForwardedForChain(req *http.Request) []string {
+	var chain []string
+	for _, header := range req.Header.Values(XForwardedForHeader) {
+		for _, addr := range strings.Split(header, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return chain
+}
+
+func xRealIpChain(req *http.Request) []string {
+	if realIp := req.Header.Get(XRealIpHeader); realIp != "" {
+		return []string{strings.TrimSpace(realIp)}
+	}
+	return nil
+}
+
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+func parseAddr(addr string) (netip.Addr, bool) {
+	parsed, err := netip.ParseAddr(stripPort(addr))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return parsed.Unmap().WithZone(""), true
+}
+
+func ClientIp(resolver *ClientIpResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIpKey{}, resolver.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Без middleware `ClientIp` возвращает адрес соединения без порта
+func ClientIpAddress(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIpKey{}).(string); ok {
+		return ip
+	}
+	return stripPort(r.RemoteAddr)
+}
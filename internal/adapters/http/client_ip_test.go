@@ -0,0 +1,113 @@
+package http_adapters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIpResolver(t *testing.T) {
+	trustedProxies := []string{"10.0.0.0/8", "192.0.2.1"}
+	cases := []struct {
+		name string
+		// По умолчанию `X-Forwarded-For`
+		header     string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "should strip port",
+			remoteAddr: "203.0.113.1:54321",
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "should strip port from ipv6 address",
+			remoteAddr: "[2001:db8::1]:54321",
+			expected:   "2001:db8::1",
+		},
+		{
+			name:       "should ignore headers from untrusted address",
+			remoteAddr: "203.0.113.1:54321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "should use X-Forwarded-For from trusted proxy",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "should skip trusted proxies in chain",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.2, 198.51.100.1, 192.0.2.1, 10.0.0.2"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "should use Forwarded header",
+			header:     ForwardedHeader,
+			remoteAddr: "192.0.2.1:54321",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::2]:4711";proto=https, for=10.0.0.3`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			expected: "2001:db8::2",
+		},
+		{
+			name:       "should ignore Forwarded header when X-Forwarded-For is configured",
+			remoteAddr: "10.0.0.1:54321",
+			headers: map[string]string{
+				"Forwarded":       "for=198.51.100.9",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			expected: "198.51.100.1",
+		},
+		{
+			name:       "should ignore X-Forwarded-For when Forwarded is configured",
+			header:     ForwardedHeader,
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "should use X-Real-IP",
+			header:     "x-real-ip",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "should stop at invalid address",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"},
+			expected:   "10.0.0.1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := c.header
+			if header == "" {
+				header = XForwardedForHeader
+			}
+			resolver, err := NewClientIpResolver(header, trustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = c.remoteAddr
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			if actual := resolver.Resolve(r); actual != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClientIpResolverRejectsUnknownHeader(t *testing.T) {
+	if _, err := NewClientIpResolver("X-Client-IP", nil); err == nil {
+		t.Fatal("expected error for unsupported header")
+	}
+}
@@ -1,11 +1,16 @@
 package http_adapters
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"runtime/debug"
+	"strings"
+	"time"
 
 	"log/slog"
 
+	"github.com/google/uuid"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 )
 
@@ -29,9 +34,12 @@ func Logging(log *logger.Logger, next http.Handler) http.Handler {
 			r.Context(),
 			"request",
 			slog.String("method", r.Method),
-			slog.String("url", r.RequestURI),
+			// Параметры запроса не журналируются, в них передаются токены,
+			// например ссылки "это был не я"
+			slog.String("path", r.URL.Path),
 			slog.Int("status", c.status),
 			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("client_ip", ClientIpAddress(r)),
 		)
 	})
 }
@@ -47,3 +55,55 @@ func Recover(log *logger.Logger, next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func BearerAuth(token string, next http.Handler) http.Handler {
+	expected := []byte(token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(actual), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type userIdKey struct{}
+
+// Сохраняет идентификатор аутентифицированного пользователя
+func WithUserId(ctx context.Context, userId uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIdKey{}, userId)
+}
+
+func UserId(ctx context.Context) (uuid.UUID, bool) {
+	userId, ok := ctx.Value(userIdKey{}).(uuid.UUID)
+	return userId, ok
+}
+
+type authTimeKey struct{}
+
+// Сохраняет время входа, с которого началась сессия аутентифицированного пользователя
+func WithAuthTime(ctx context.Context, authTime time.Time) context.Context {
+	return context.WithValue(ctx, authTimeKey{}, authTime)
+}
+
+func AuthTime(ctx context.Context) (time.Time, bool) {
+	authTime, ok := ctx.Value(authTimeKey{}).(time.Time)
+	return authTime, ok
+}
+
+// Клиенты без секрета не допускаются
+func BasicAuth(credentials map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		expected, known := credentials[id]
+		if !ok || !known || expected == "" ||
+			subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="auth"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,27 @@
+package http_adapters
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+)
+
+func TestLoggingOmitsQuery(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(slog.New(slog.NewTextHandler(&buf, nil)))
+	handler := Logging(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auth/security/report?token=secret", nil))
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("query is logged: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "path=/auth/security/report") {
+		t.Errorf("path is not logged: %s", buf.String())
+	}
+}
@@ -0,0 +1,97 @@
+package http_adapters
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"log/slog"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+)
+
+type RateLimitRule struct {
+	Limit ratelimit.Limit
+	// Пустой ключ означает, что правило к запросу неприменимо
+	Key func(r *http.Request) string
+}
+
+func IpRateLimitKey(r *http.Request) string {
+	return "ip:" + ClientIpAddress(r)
+}
+
+// Каждое правило расходует свою корзину, запрос отклоняется при исчерпании любой из них.
+// Ошибки хранилища не блокируют запросы.
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+func RateLimit(
+	log *logger.Logger,
+	store ratelimit.Store,
+	route string,
+	rules []RateLimitRule,
+	next http.Handler,
+) http.Handler {
+	enabled := make([]RateLimitRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Limit.Enabled() {
+			enabled = append(enabled, rule)
+		}
+	}
+	if len(enabled) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			reported   *ratelimit.Result
+			limit      ratelimit.Limit
+			allowed    = true
+			retryAfter time.Duration
+		)
+		for _, rule := range enabled {
+			key := rule.Key(r)
+			if key == "" {
+				continue
+			}
+			result, err := store.Take(r.Context(), route+":"+key, rule.Limit)
+			if err != nil {
+				log.Error(r.Context(), "failed to take rate limit token", slog.String("route", route), sl.Err(err))
+				continue
+			}
+			if !result.Allowed {
+				allowed = false
+				retryAfter = max(retryAfter, result.RetryAfter)
+			}
+			// В заголовках отражается самое строгое из ограничений
+			if reported == nil || result.Remaining < reported.Remaining {
+				reported = &result
+				limit = rule.Limit
+			}
+		}
+		if reported != nil {
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(reported.Remaining))
+			h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(reported.Reset), 10))
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+			log.Warn(
+				r.Context(),
+				"rate limit exceeded",
+				slog.String("route", route),
+				slog.String("client_ip", ClientIpAddress(r)),
+			)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,77 @@
+package http_adapters
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	store := ratelimit.NewInMemoryStore(func() time.Time { return now })
+	log := logger.New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler := RateLimit(log, store, "login", []RateLimitRule{
+		{
+			Limit: ratelimit.Limit{Requests: 3, Period: time.Minute},
+			Key:   IpRateLimitKey,
+		},
+		{
+			Limit: ratelimit.Limit{Requests: 1, Period: time.Minute},
+			Key: func(r *http.Request) string {
+				if user := r.URL.Query().Get("user"); user != "" {
+					return "user:" + user
+				}
+				return ""
+			},
+		},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.RemoteAddr = "203.0.113.1:54321"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/login?user=a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expected strictest limit in headers, got %v", rec.Header())
+	}
+
+	rec = do("/login?user=a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("unexpected Retry-After: %q", rec.Header().Get("Retry-After"))
+	}
+
+	rec = do("/login?user=b")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	rec = do("/login")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected ip limit to be exhausted, got %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Policy") != "3;w=60" {
+		t.Fatalf("unexpected RateLimit-Policy: %q", rec.Header().Get("RateLimit-Policy"))
+	}
+
+	now = now.Add(20 * time.Second)
+	if rec = do("/login"); rec.Code != http.StatusOK {
+		t.Fatalf("expected refill, got %d", rec.Code)
+	}
+}
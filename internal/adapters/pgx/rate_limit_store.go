@@ -0,0 +1,90 @@
+package pgx_adapter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+// Разделяет счетчики между экземплярами сервиса
+type RateLimitStore struct {
+	log       *logger.Logger
+	pool      *pgxpool.Pool
+	now       func() time.Time
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewRateLimitStore(log *logger.Logger, pool *pgxpool.Pool, now func() time.Time) *RateLimitStore {
+	return &RateLimitStore{
+		log:  log,
+		pool: pool,
+		now:  now,
+	}
+}
+
+const insertRateLimitBucketQuery = `INSERT INTO rate_limit_bucket (key, tokens, updated_at, full_at)
+VALUES ($1, $2, $3, $3)
+ON CONFLICT (key) DO NOTHING`
+
+const rateLimitBucketQuery = `SELECT tokens, updated_at FROM rate_limit_bucket WHERE key = $1 FOR UPDATE`
+
+const updateRateLimitBucketQuery = `UPDATE rate_limit_bucket SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1`
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	now := s.now()
+	s.sweep(ctx, now)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Error(ctx, "failed to rollback transaction", sl.Err(err))
+		}
+	}()
+	args := []any{key, float64(limit.Requests), now}
+	s.log.Debug(ctx, "executing query", slog.String("query", insertRateLimitBucketQuery), slog.Any("args", args))
+	if _, err := tx.Exec(ctx, insertRateLimitBucketQuery, args...); err != nil {
+		return ratelimit.Result{}, err
+	}
+	s.log.Debug(ctx, "executing query", slog.String("query", rateLimitBucketQuery), slog.String("key", key))
+	var bucket ratelimit.Bucket
+	if err := tx.QueryRow(ctx, rateLimitBucketQuery, key).Scan(&bucket.Tokens, &bucket.UpdatedAt); err != nil {
+		return ratelimit.Result{}, err
+	}
+	bucket, result := ratelimit.Take(bucket, limit, now)
+	args = []any{key, bucket.Tokens, bucket.UpdatedAt, now.Add(result.Reset)}
+	s.log.Debug(ctx, "executing query", slog.String("query", updateRateLimitBucketQuery), slog.Any("args", args))
+	if _, err := tx.Exec(ctx, updateRateLimitBucketQuery, args...); err != nil {
+		return ratelimit.Result{}, err
+	}
+	return result, tx.Commit(ctx)
+}
+
+const deleteFullRateLimitBucketsQuery = `DELETE FROM rate_limit_bucket WHERE full_at < $1`
+
+// Полные корзины эквивалентны отсутствующим и периодически удаляются
+func (s *RateLimitStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	s.log.Debug(ctx, "executing query", slog.String("query", deleteFullRateLimitBucketsQuery))
+	if _, err := s.pool.Exec(ctx, deleteFullRateLimitBucketsQuery, now); err != nil {
+		s.log.Error(ctx, "failed to delete full rate limit buckets", sl.Err(err))
+	}
+}
@@ -2,19 +2,30 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/wneessen/go-mail"
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
 	pgx_adapter "github.com/x0k/medods-authentication-service/internal/adapters/pgx"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/auth"
+	"github.com/x0k/medods-authentication-service/internal/lib/jwk"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
-	email_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/email"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+	"github.com/x0k/medods-authentication-service/internal/notifications"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 	"github.com/x0k/medods-authentication-service/internal/users"
 )
 
@@ -40,46 +51,118 @@ func Run(configPath string) {
 	}
 	defer pgxPool.Close()
 
-	usersRepo := users.NewInMemoryRepo()
+	usersRepo := users.NewPgRepository(
+		log.With(slog.String("component", "users_repository")),
+		pgxPool,
+	)
 
-	firstUser := uuid.MustParse("00000000-0000-0000-0000-000000000000")
-	secondUser := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	usersRepo.Populate(map[uuid.UUID]string{
-		firstUser:  "first@test.com",
-		secondUser: "second@test.com",
-	})
+	messagesSender, err := newMessagesSender(log, cfg, usersRepo)
+	if err != nil {
+		log.Error(ctx, "cannot create messages sender", sl.Err(err))
+		os.Exit(1)
+	}
 
-	mailOptions := []mail.Option{
-		mail.WithPort(cfg.Smtp.Port),
-		mail.WithUsername(cfg.Smtp.Username),
-		mail.WithPassword(cfg.Smtp.Password),
+	signingKey, err := loadSigningKey(&cfg.Auth)
+	if err != nil {
+		log.Error(ctx, "cannot load signing key", sl.Err(err))
+		os.Exit(1)
 	}
-	if cfg.Smtp.TLS {
-		mailOptions = append(mailOptions, mail.WithTLSPolicy(mail.TLSMandatory))
-	} else {
-		mailOptions = append(mailOptions, mail.WithTLSPolicy(mail.NoTLS))
+
+	keysEncryptionKey, err := base64.StdEncoding.DecodeString(cfg.Auth.KeysEncryptionKey)
+	if err != nil {
+		log.Error(ctx, "cannot decode keys encryption key", sl.Err(err))
+		os.Exit(1)
 	}
-	mailClient, err := mail.NewClient(
-		cfg.Smtp.Host,
-		mailOptions...,
-	)
+
+	checkpointKey, err := loadCheckpointKey(&cfg.Audit)
 	if err != nil {
-		log.Error(ctx, "cannot create mail client", sl.Err(err))
+		log.Error(ctx, "cannot load checkpoint key", sl.Err(err))
 		os.Exit(1)
 	}
-	emailSender := email_messages_sender.New(
-		usersRepo,
-		mailClient,
-		cfg.Smtp.From,
-	)
 
-	router := NewRouter(
+	clientIpResolver, err := http_adapters.NewClientIpResolver(cfg.Server.ClientIpHeader, cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Error(ctx, "cannot create client ip resolver", sl.Err(err))
+		os.Exit(1)
+	}
+
+	rateLimits, err := loadRateLimits(&cfg.RateLimit)
+	if err != nil {
+		log.Error(ctx, "cannot load rate limits", sl.Err(err))
+		os.Exit(1)
+	}
+	rateLimitStore, err := newRateLimitStore(log, pgxPool, &cfg.RateLimit)
+	if err != nil {
+		log.Error(ctx, "cannot create rate limit store", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var geoLocator auth.GeoLocator
+	if cfg.Auth.GeoIpCountryDb != "" || cfg.Auth.GeoIpAsnDb != "" {
+		locator, err := auth.OpenMaxMindGeoLocator(cfg.Auth.GeoIpCountryDb, cfg.Auth.GeoIpAsnDb)
+		if err != nil {
+			log.Error(ctx, "cannot open geo databases", sl.Err(err))
+			os.Exit(1)
+		}
+		defer locator.Close()
+		geoLocator = locator
+	}
+
+	router, err := NewRouter(
+		ctx,
 		log,
 		pgxPool,
-		[]byte(cfg.Auth.Secret),
+		&auth.Config{
+			SigningKey:           signingKey,
+			KeysEncryptionKey:    keysEncryptionKey,
+			KeyRetention:         cfg.Auth.KeyRetention,
+			KeysReloadInterval:   cfg.Auth.KeysReloadInterval,
+			AccessTTL:            cfg.Auth.AccessTTL,
+			RefreshTTL:           cfg.Auth.RefreshTTL,
+			SessionMaxAge:        cfg.Auth.SessionMaxAge,
+			SessionIdleTimeout:   cfg.Auth.SessionIdleTimeout,
+			IntrospectionClients: cfg.Auth.IntrospectionClients,
+			DeviceIdFallback:     cfg.Auth.DeviceIdFallback,
+			RiskRules:            cfg.Auth.RiskRules,
+			GeoLocator:           geoLocator,
+			ChallengeTTL:         cfg.Auth.ChallengeTTL,
+			ChallengeMaxAttempts: cfg.Auth.ChallengeMaxAttempts,
+			PublicUrl:            strings.TrimSuffix(cfg.Auth.PublicUrl, "/"),
+			ReportLinkTTL:        cfg.Auth.ReportLinkTTL,
+			RateLimits:           rateLimits,
+			RateLimitStore:       rateLimitStore,
+		},
+		newAuditChainConfig(&cfg.Audit),
+		checkpointKey,
+		outbox.Config{
+			PollInterval: cfg.Outbox.PollInterval,
+			BatchSize:    cfg.Outbox.BatchSize,
+			MaxAttempts:  cfg.Outbox.MaxAttempts,
+			BaseBackoff:  cfg.Outbox.BaseBackoff,
+			MaxBackoff:   cfg.Outbox.MaxBackoff,
+			Lease:        cfg.Outbox.Lease,
+		},
+		notifications.Config{
+			Channels: cfg.Notifications.Channels,
+			Aggregation: notifications.AggregatorConfig{
+				Window:        cfg.Notifications.AggregationWindow,
+				FlushInterval: cfg.Notifications.FlushInterval,
+				BatchSize:     cfg.Notifications.FlushBatchSize,
+			},
+			FreshLoginMaxAge: cfg.Notifications.FreshLoginMaxAge,
+		},
+		cfg.Admin.Token,
+		clientIpResolver,
 		usersRepo,
-		emailSender,
+		messagesSender,
 	)
+	if err != nil {
+		log.Error(ctx, "cannot create router", sl.Err(err))
+		os.Exit(1)
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	waitWorkers := router.StartWorkers(workersCtx)
 
 	srv := http.Server{
 		Addr:    cfg.Server.Address,
@@ -107,5 +190,165 @@ func Run(configPath string) {
 		log.Error(ctx, "force shutdown", sl.Err(err))
 		os.Exit(1)
 	}
+	// Фоновые процессы останавливаются после запросов, которые могли поставить им задачи
+	stopWorkers()
+	waitWorkers()
 	log.Info(ctx, "graceful shutdown")
 }
+
+// Проверяет цепочку журнала аудита и завершает процесс с ненулевым кодом
+// при обнаружении нарушения. Ключи чекпоинтов читаются только из `AUDIT_VERIFY_JWKS`,
+// ключам из проверяемой базы данных доверять нельзя.
+func VerifyAudit(configPath string) {
+	cfg := mustLoadConfig(configPath)
+	log := mustNewLogger(&cfg.Logger)
+	ctx := context.Background()
+
+	if cfg.Audit.VerifyJwks == "" {
+		log.Error(ctx, "AUDIT_VERIFY_JWKS is required to verify checkpoints")
+		os.Exit(1)
+	}
+	set, err := loadJwks(cfg.Audit.VerifyJwks)
+	if err != nil {
+		log.Error(ctx, "cannot load checkpoint keys", sl.Err(err))
+		os.Exit(1)
+	}
+
+	pgxPool, err := pgxpool.New(ctx, cfg.Postgres.ConnectionURI)
+	if err != nil {
+		log.Error(ctx, "cannot connect to database", sl.Err(err))
+		os.Exit(1)
+	}
+	defer pgxPool.Close()
+
+	report, err := audit.NewPgChain(
+		log.With(slog.String("component", "audit_chain")),
+		pgxPool,
+		// Проверка не создает чекпоинтов
+		nil,
+		newAuditChainConfig(&cfg.Audit),
+		time.Now,
+	).Verify(ctx, set.KeyFunc)
+	if err != nil {
+		log.Error(ctx, "cannot verify audit chain", sl.Err(err))
+		os.Exit(1)
+	}
+	fmt.Printf("events: %d, checkpoints: %d, unsealed: %d\n", report.Events, report.Checkpoints, report.Unsealed)
+	if report.Broken != nil {
+		fmt.Printf("broken link at %s\n", report.Broken)
+		os.Exit(1)
+	}
+	fmt.Println("audit chain is intact")
+}
+
+// Выводит публичный ключ чекпоинтов вместе с ключами из `AUDIT_VERIFY_JWKS`,
+// чтобы после смены ключа старые чекпоинты оставались проверяемыми
+func ExportJwks(configPath string) {
+	cfg := mustLoadConfig(configPath)
+	log := mustNewLogger(&cfg.Logger)
+	ctx := context.Background()
+
+	checkpointKey, err := loadCheckpointKey(&cfg.Audit)
+	if err != nil {
+		log.Error(ctx, "cannot load checkpoint key", sl.Err(err))
+		os.Exit(1)
+	}
+	key, _, err := checkpointKey.JWK()
+	if err != nil {
+		log.Error(ctx, "cannot export checkpoint key", sl.Err(err))
+		os.Exit(1)
+	}
+	set := jwk.Set{Keys: []jwk.Key{}}
+	if cfg.Audit.VerifyJwks != "" {
+		if set, err = loadJwks(cfg.Audit.VerifyJwks); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Error(ctx, "cannot load checkpoint keys", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+	if !slices.ContainsFunc(set.Keys, func(k jwk.Key) bool { return k.Kid == key.Kid }) {
+		set.Keys = append(set.Keys, key)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(set); err != nil {
+		log.Error(ctx, "cannot write signing keys", sl.Err(err))
+		os.Exit(1)
+	}
+}
+
+func loadJwks(path string) (jwk.Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return jwk.Set{}, err
+	}
+	var set jwk.Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return jwk.Set{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return set, nil
+}
+
+func newAuditChainConfig(cfg *AuditConfig) audit.ChainConfig {
+	return audit.ChainConfig{
+		SealInterval:       cfg.SealInterval,
+		CheckpointEvery:    cfg.CheckpointEvery,
+		CheckpointInterval: cfg.CheckpointInterval,
+	}
+}
+
+func loadSigningKey(cfg *AuthConfig) (*auth.SigningKey, error) {
+	if cfg.SigningAlgorithm == auth.HS512 {
+		return auth.NewSigningKey(cfg.SigningAlgorithm, []byte(cfg.Secret))
+	}
+	if cfg.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("private key path is required for %s", cfg.SigningAlgorithm)
+	}
+	pem, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return auth.NewSigningKey(cfg.SigningAlgorithm, pem)
+}
+
+func loadCheckpointKey(cfg *AuditConfig) (*auth.SigningKey, error) {
+	pem, err := os.ReadFile(cfg.CheckpointKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint key: %w", err)
+	}
+	return auth.NewSigningKey(cfg.CheckpointAlgorithm, pem)
+}
+
+func loadRateLimits(cfg *RateLimitConfig) (auth.RateLimits, error) {
+	var (
+		limits auth.RateLimits
+		err    error
+	)
+	if limits.LoginIp, err = ratelimit.ParseLimit(cfg.LoginIp); err != nil {
+		return limits, fmt.Errorf("login ip: %w", err)
+	}
+	if limits.LoginUser, err = ratelimit.ParseLimit(cfg.LoginUser); err != nil {
+		return limits, fmt.Errorf("login user: %w", err)
+	}
+	if limits.RefreshIp, err = ratelimit.ParseLimit(cfg.RefreshIp); err != nil {
+		return limits, fmt.Errorf("refresh ip: %w", err)
+	}
+	if limits.RefreshUser, err = ratelimit.ParseLimit(cfg.RefreshUser); err != nil {
+		return limits, fmt.Errorf("refresh user: %w", err)
+	}
+	return limits, nil
+}
+
+func newRateLimitStore(log *logger.Logger, pgxPool *pgxpool.Pool, cfg *RateLimitConfig) (ratelimit.Store, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return ratelimit.NewInMemoryStore(time.Now), nil
+	case "postgres":
+		return pgx_adapter.NewRateLimitStore(
+			log.With(slog.String("component", "rate_limit_store")),
+			pgxPool,
+			time.Now,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
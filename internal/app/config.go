@@ -1,10 +1,14 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/x0k/medods-authentication-service/internal/auth"
 )
 
 type LoggerConfig struct {
@@ -19,10 +23,86 @@ type PgConfig struct {
 
 type ServerConfig struct {
 	Address string `yaml:"address" env:"SERVER_ADDRESS" env-default:"0.0.0.0:8080"`
+	// CIDR или адреса прокси, которым разрешено передавать адрес клиента
+	TrustedProxies []string `yaml:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
+	// Заголовок с адресом клиента, который выставляют доверенные прокси:
+	// `Forwarded`, `X-Forwarded-For` или `X-Real-IP`
+	ClientIpHeader string `yaml:"client_ip_header" env:"SERVER_CLIENT_IP_HEADER" env-default:"X-Forwarded-For"`
 }
 
 type AuthConfig struct {
-	Secret string `yaml:"secret" env:"AUTH_SECRET" env-required:"true"`
+	SigningAlgorithm string `yaml:"signing_algorithm" env:"AUTH_SIGNING_ALGORITHM" env-default:"HS512"`
+	// Используется для `HS512`
+	Secret string `yaml:"secret" env:"AUTH_SECRET"`
+	// Используется для `EdDSA`, `RS256` и `ES256`
+	PrivateKeyPath string `yaml:"private_key_path" env:"AUTH_PRIVATE_KEY_PATH"`
+	// 32 байта в base64, шифрует материал ключей подписи в базе данных
+	KeysEncryptionKey string `yaml:"keys_encryption_key" env:"AUTH_KEYS_ENCRYPTION_KEY" env-required:"true"`
+	// Сколько выведенный из оборота ключ продолжает проверять токены
+	KeyRetention time.Duration `yaml:"key_retention" env:"AUTH_KEY_RETENTION" env-default:"720h"`
+	// Период перезагрузки ключей, ротированных другими экземплярами сервиса
+	KeysReloadInterval time.Duration `yaml:"keys_reload_interval" env:"AUTH_KEYS_RELOAD_INTERVAL" env-default:"1m"`
+	AccessTTL          time.Duration `yaml:"access_ttl" env:"AUTH_ACCESS_TTL" env-default:"15m"`
+	RefreshTTL         time.Duration `yaml:"refresh_ttl" env:"AUTH_REFRESH_TTL" env-default:"720h"`
+	// Нулевые значения отключают соответствующие ограничения
+	SessionMaxAge      time.Duration `yaml:"session_max_age" env:"AUTH_SESSION_MAX_AGE" env-default:"2160h"`
+	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout" env:"AUTH_SESSION_IDLE_TIMEOUT" env-default:"168h"`
+	// Формат переменной окружения: `id1:secret1,id2:secret2`
+	IntrospectionClients map[string]string `yaml:"introspection_clients" env:"AUTH_INTROSPECTION_CLIENTS"`
+	// Определение устройства без заголовка `X-Device-Id`: `ip` или `none`
+	DeviceIdFallback string `yaml:"device_id_fallback" env:"AUTH_DEVICE_ID_FALLBACK" env-default:"ip"`
+	// Задаются только в YAML, без правил смена ip-адреса приводит к предупреждению
+	RiskRules []auth.RiskRule `yaml:"risk_rules"`
+	// Базы MaxMind GeoLite2/GeoIP2 (`Country` или `City` и `ASN`) в формате mmdb
+	// для сигналов `country_change` и `asn_change`
+	GeoIpCountryDb string `yaml:"geoip_country_db" env:"AUTH_GEOIP_COUNTRY_DB"`
+	GeoIpAsnDb     string `yaml:"geoip_asn_db" env:"AUTH_GEOIP_ASN_DB"`
+	// Подтверждение обновления пары кодом из письма при решении `step_up`
+	ChallengeTTL         time.Duration `yaml:"challenge_ttl" env:"AUTH_CHALLENGE_TTL" env-default:"10m"`
+	ChallengeMaxAttempts int           `yaml:"challenge_max_attempts" env:"AUTH_CHALLENGE_MAX_ATTEMPTS" env-default:"5"`
+	// Адрес сервиса для ссылки "это был не я" в предупреждениях, например `https://auth.example.com`
+	PublicUrl     string        `yaml:"public_url" env:"AUTH_PUBLIC_URL"`
+	ReportLinkTTL time.Duration `yaml:"report_link_ttl" env:"AUTH_REPORT_LINK_TTL" env-default:"24h"`
+}
+
+type RateLimitConfig struct {
+	// `memory` или `postgres` для нескольких экземпляров сервиса
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"memory"`
+	// Формат: `10/1m`, значение `0` отключает ограничение
+	LoginIp     string `yaml:"login_ip" env:"RATE_LIMIT_LOGIN_IP" env-default:"20/1m"`
+	LoginUser   string `yaml:"login_user" env:"RATE_LIMIT_LOGIN_USER" env-default:"5/1m"`
+	RefreshIp   string `yaml:"refresh_ip" env:"RATE_LIMIT_REFRESH_IP" env-default:"30/1m"`
+	RefreshUser string `yaml:"refresh_user" env:"RATE_LIMIT_REFRESH_USER" env-default:"10/1m"`
+}
+
+type AuditConfig struct {
+	// Как часто новые события добавляются в цепочку хешей
+	SealInterval time.Duration `yaml:"seal_interval" env:"AUDIT_SEAL_INTERVAL" env-default:"5s"`
+	// Подписанный чекпоинт создается через указанное число событий или интервал
+	CheckpointEvery    int64         `yaml:"checkpoint_every" env:"AUDIT_CHECKPOINT_EVERY" env-default:"1000"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"AUDIT_CHECKPOINT_INTERVAL" env-default:"1h"`
+	// Чекпоинты подписываются отдельным асимметричным ключом, который не хранится
+	// в базе данных, иначе доступ к ней позволял бы переподписать журнал
+	CheckpointAlgorithm string `yaml:"checkpoint_algorithm" env:"AUDIT_CHECKPOINT_ALGORITHM" env-default:"EdDSA"`
+	// Приватный ключ в формате PEM
+	CheckpointKeyPath string `yaml:"checkpoint_key_path" env:"AUDIT_CHECKPOINT_KEY_PATH" env-required:"true"`
+	// Файл JWKS с публичными ключами чекпоинтов, без него `verify-audit` не работает
+	VerifyJwks string `yaml:"verify_jwks" env:"AUDIT_VERIFY_JWKS"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	// После исчерпания попыток сообщение остается в таблице со статусом `dead`
+	MaxAttempts int           `yaml:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS" env-default:"8"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env:"OUTBOX_BASE_BACKOFF" env-default:"10s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF" env-default:"1h"`
+	// Сколько сообщение остается захваченным экземпляром сервиса
+	Lease time.Duration `yaml:"lease" env:"OUTBOX_LEASE" env-default:"1m"`
+}
+
+type AdminConfig struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
 }
 
 type SmtpConfig struct {
@@ -32,14 +112,55 @@ type SmtpConfig struct {
 	Username string `yaml:"username" env:"SMTP_USERNAME" env-required:"true"`
 	Password string `yaml:"password" env:"SMTP_PASSWORD" env-required:"true"`
 	TLS      bool   `yaml:"tls" env:"SMTP_TLS" env-default:"true"`
+	// Каталог с шаблонами `<locale>/<name>.txt.tmpl` и `<locale>/<name>.html.tmpl`,
+	// заменяющими встроенные
+	TemplatesDir string `yaml:"templates_dir" env:"SMTP_TEMPLATES_DIR"`
+	// Используется, если для языка клиента нет шаблона
+	DefaultLocale string `yaml:"default_locale" env:"SMTP_DEFAULT_LOCALE" env-default:"en"`
+}
+
+type NotificationsConfig struct {
+	// Каналы доставки сообщений: `email`, `webhook`, `sms`
+	Channels []string `yaml:"channels" env:"NOTIFICATIONS_CHANNELS" env-default:"email"`
+	// Повторы предупреждения в течение окна объединяются в сводку, `0` - без объединения
+	AggregationWindow time.Duration `yaml:"aggregation_window" env:"NOTIFICATIONS_AGGREGATION_WINDOW" env-default:"15m"`
+	FlushInterval     time.Duration `yaml:"flush_interval" env:"NOTIFICATIONS_FLUSH_INTERVAL" env-default:"10s"`
+	FlushBatchSize    int           `yaml:"flush_batch_size" env:"NOTIFICATIONS_FLUSH_BATCH_SIZE" env-default:"100"`
+	// Время после входа, в течение которого можно изменить настройки уведомлений
+	FreshLoginMaxAge time.Duration `yaml:"fresh_login_max_age" env:"NOTIFICATIONS_FRESH_LOGIN_MAX_AGE" env-default:"10m"`
+}
+
+type WebhookConfig struct {
+	Url string `yaml:"url" env:"WEBHOOK_URL"`
+	// Ключ подписи HMAC-SHA256 тела запроса
+	Secret  string        `yaml:"secret" env:"WEBHOOK_SECRET"`
+	Timeout time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+}
+
+type SmsConfig struct {
+	GatewayUrl string `yaml:"gateway_url" env:"SMS_GATEWAY_URL"`
+	// Значение заголовка `Authorization` запросов к шлюзу
+	Authorization string `yaml:"authorization" env:"SMS_GATEWAY_AUTHORIZATION"`
+	ContentType   string `yaml:"content_type" env:"SMS_GATEWAY_CONTENT_TYPE" env-default:"application/json"`
+	// Шаблон тела запроса с полями `.To` и `.Text`
+	BodyTemplate  string        `yaml:"body_template" env:"SMS_GATEWAY_BODY_TEMPLATE"`
+	Timeout       time.Duration `yaml:"timeout" env:"SMS_GATEWAY_TIMEOUT" env-default:"10s"`
+	DefaultLocale string        `yaml:"default_locale" env:"SMS_DEFAULT_LOCALE" env-default:"en"`
 }
 
 type Config struct {
-	Logger   LoggerConfig
-	Postgres PgConfig
-	Server   ServerConfig
-	Auth     AuthConfig
-	Smtp     SmtpConfig
+	Logger        LoggerConfig
+	Postgres      PgConfig
+	Server        ServerConfig
+	Auth          AuthConfig
+	RateLimit     RateLimitConfig `yaml:"rate_limit"`
+	Audit         AuditConfig
+	Outbox        OutboxConfig
+	Admin         AdminConfig
+	Smtp          SmtpConfig
+	Notifications NotificationsConfig
+	Webhook       WebhookConfig
+	Sms           SmsConfig
 }
 
 func mustLoadConfig(configPath string) *Config {
@@ -55,5 +176,54 @@ func mustLoadConfig(configPath string) *Config {
 	if cfgErr != nil {
 		log.Fatalf("cannot read config: %s", cfgErr)
 	}
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
 	return cfg
 }
+
+// Нулевые периоды приводят к панике `time.NewTicker`, а нулевые размеры
+// пакетов - к бесконечному циклу фоновых процессов
+func (c *Config) validate() error {
+	var errs []error
+	positive := func(name string, value int64) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	nonNegative := func(name string, value int64) {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+	positive("AUTH_ACCESS_TTL", int64(c.Auth.AccessTTL))
+	positive("AUTH_REFRESH_TTL", int64(c.Auth.RefreshTTL))
+	positive("AUTH_KEYS_RELOAD_INTERVAL", int64(c.Auth.KeysReloadInterval))
+	positive("AUTH_CHALLENGE_TTL", int64(c.Auth.ChallengeTTL))
+	positive("AUTH_CHALLENGE_MAX_ATTEMPTS", int64(c.Auth.ChallengeMaxAttempts))
+	positive("AUTH_REPORT_LINK_TTL", int64(c.Auth.ReportLinkTTL))
+	nonNegative("AUTH_KEY_RETENTION", int64(c.Auth.KeyRetention))
+	nonNegative("AUTH_SESSION_MAX_AGE", int64(c.Auth.SessionMaxAge))
+	nonNegative("AUTH_SESSION_IDLE_TIMEOUT", int64(c.Auth.SessionIdleTimeout))
+	positive("AUDIT_SEAL_INTERVAL", int64(c.Audit.SealInterval))
+	positive("AUDIT_CHECKPOINT_EVERY", c.Audit.CheckpointEvery)
+	positive("AUDIT_CHECKPOINT_INTERVAL", int64(c.Audit.CheckpointInterval))
+	// Подпись симметричным ключом нельзя проверить без секрета
+	if c.Audit.CheckpointAlgorithm == auth.HS512 {
+		errs = append(errs, fmt.Errorf("AUDIT_CHECKPOINT_ALGORITHM must be asymmetric, got %s", auth.HS512))
+	}
+	positive("OUTBOX_POLL_INTERVAL", int64(c.Outbox.PollInterval))
+	positive("OUTBOX_BATCH_SIZE", int64(c.Outbox.BatchSize))
+	positive("OUTBOX_MAX_ATTEMPTS", int64(c.Outbox.MaxAttempts))
+	positive("OUTBOX_BASE_BACKOFF", int64(c.Outbox.BaseBackoff))
+	positive("OUTBOX_MAX_BACKOFF", int64(c.Outbox.MaxBackoff))
+	positive("OUTBOX_LEASE", int64(c.Outbox.Lease))
+	nonNegative("NOTIFICATIONS_AGGREGATION_WINDOW", int64(c.Notifications.AggregationWindow))
+	// Без агрегации сводки не формируются
+	if c.Notifications.AggregationWindow > 0 {
+		positive("NOTIFICATIONS_FLUSH_INTERVAL", int64(c.Notifications.FlushInterval))
+		positive("NOTIFICATIONS_FLUSH_BATCH_SIZE", int64(c.Notifications.FlushBatchSize))
+	}
+	positive("NOTIFICATIONS_FRESH_LOGIN_MAX_AGE", int64(c.Notifications.FreshLoginMaxAge))
+	return errors.Join(errs...)
+}
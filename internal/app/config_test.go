@@ -0,0 +1,77 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/x0k/medods-authentication-service/internal/auth"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, name := range []string{"PG_CONNECTION_URI", "SMTP_FROM", "SMTP_HOST", "SMTP_USERNAME", "SMTP_PASSWORD", "AUDIT_CHECKPOINT_KEY_PATH", "AUTH_KEYS_ENCRYPTION_KEY"} {
+		t.Setenv(name, "test")
+	}
+	cases := []struct {
+		name    string
+		update  func(c *Config)
+		invalid bool
+	}{
+		{
+			name:   "should accept defaults",
+			update: func(c *Config) {},
+		},
+		{
+			name:    "should reject zero outbox poll interval",
+			update:  func(c *Config) { c.Outbox.PollInterval = 0 },
+			invalid: true,
+		},
+		{
+			name:    "should reject zero outbox batch size",
+			update:  func(c *Config) { c.Outbox.BatchSize = 0 },
+			invalid: true,
+		},
+		{
+			name:    "should reject zero audit seal interval",
+			update:  func(c *Config) { c.Audit.SealInterval = 0 },
+			invalid: true,
+		},
+		{
+			name:    "should reject symmetric checkpoint key",
+			update:  func(c *Config) { c.Audit.CheckpointAlgorithm = auth.HS512 },
+			invalid: true,
+		},
+		{
+			name:    "should reject zero flush interval with aggregation",
+			update:  func(c *Config) { c.Notifications.FlushInterval = 0 },
+			invalid: true,
+		},
+		{
+			name: "should ignore flush settings without aggregation",
+			update: func(c *Config) {
+				c.Notifications.AggregationWindow = 0
+				c.Notifications.FlushInterval = 0
+				c.Notifications.FlushBatchSize = 0
+			},
+		},
+		{
+			name:   "should allow disabled session limits",
+			update: func(c *Config) { c.Auth.SessionMaxAge = 0 },
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			if err := cleanenv.ReadEnv(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			tc.update(&cfg)
+			err := cfg.validate()
+			if tc.invalid && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tc.invalid && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/wneessen/go-mail"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	email_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/email"
+	fanout_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/fanout"
+	sms_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/sms"
+	webhook_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/webhook"
+)
+
+type contactsRepository interface {
+	email_messages_sender.UsersRepository
+	sms_messages_sender.PhonesRepository
+}
+
+func newMessagesSender(
+	log *logger.Logger,
+	cfg *Config,
+	contactsRepo contactsRepository,
+) (*fanout_messages_sender.Sender, error) {
+	channels := make([]fanout_messages_sender.Channel, 0, len(cfg.Notifications.Channels))
+	for _, name := range cfg.Notifications.Channels {
+		var (
+			channel = fanout_messages_sender.Channel{Name: name}
+			err     error
+		)
+		switch name {
+		case messages_sender.EmailChannel:
+			channel.Sender, err = newEmailSender(&cfg.Smtp, contactsRepo)
+		case messages_sender.WebhookChannel:
+			if cfg.Webhook.Url == "" || cfg.Webhook.Secret == "" {
+				return nil, fmt.Errorf("webhook url and secret are required")
+			}
+			channel.Sender = webhook_messages_sender.New(
+				&http.Client{Timeout: cfg.Webhook.Timeout},
+				cfg.Webhook.Url,
+				[]byte(cfg.Webhook.Secret),
+				time.Now,
+			)
+		case messages_sender.SmsChannel:
+			if cfg.Sms.GatewayUrl == "" {
+				return nil, fmt.Errorf("sms gateway url is required")
+			}
+			channel.Sender, err = sms_messages_sender.New(
+				contactsRepo,
+				&http.Client{Timeout: cfg.Sms.Timeout},
+				sms_messages_sender.GatewayConfig{
+					Url:           cfg.Sms.GatewayUrl,
+					Authorization: cfg.Sms.Authorization,
+					ContentType:   cfg.Sms.ContentType,
+					BodyTemplate:  cfg.Sms.BodyTemplate,
+				},
+				cfg.Sms.DefaultLocale,
+			)
+		default:
+			return nil, fmt.Errorf("unknown notifications channel %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s channel: %w", name, err)
+		}
+		channels = append(channels, channel)
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("at least one notifications channel is required")
+	}
+	return fanout_messages_sender.New(
+		log.With(slog.String("component", "messages_sender")),
+		channels,
+	), nil
+}
+
+func newEmailSender(cfg *SmtpConfig, usersRepo email_messages_sender.UsersRepository) (*email_messages_sender.Sender, error) {
+	mailOptions := []mail.Option{
+		mail.WithPort(cfg.Port),
+		mail.WithUsername(cfg.Username),
+		mail.WithPassword(cfg.Password),
+	}
+	if cfg.TLS {
+		mailOptions = append(mailOptions, mail.WithTLSPolicy(mail.TLSMandatory))
+	} else {
+		mailOptions = append(mailOptions, mail.WithTLSPolicy(mail.NoTLS))
+	}
+	mailClient, err := mail.NewClient(
+		cfg.Host,
+		mailOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create mail client: %w", err)
+	}
+	templates, err := email_messages_sender.NewTemplates(cfg.TemplatesDir, cfg.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load email templates: %w", err)
+	}
+	return email_messages_sender.New(
+		usersRepo,
+		mailClient,
+		cfg.From,
+		templates,
+	), nil
+}
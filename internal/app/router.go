@@ -1,38 +1,131 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
+	"github.com/x0k/medods-authentication-service/internal/audit"
 	"github.com/x0k/medods-authentication-service/internal/auth"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/notifications"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 )
 
+type MessagesSender = messages_sender.Sender
+
+// Обработчик запросов вместе с фоновыми процессами, обслуживающими его модули
+type Router struct {
+	http.Handler
+	workers []func(ctx context.Context)
+}
+
+// Запускает фоновые процессы, они завершаются при отмене `ctx`.
+// Возвращенная функция ожидает их завершения.
+func (r *Router) StartWorkers(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	for _, worker := range r.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(ctx)
+		}()
+	}
+	return wg.Wait
+}
+
 func NewRouter(
+	ctx context.Context,
 	log *logger.Logger,
 	pgxPool *pgxpool.Pool,
-	authSecret []byte,
+	authCfg *auth.Config,
+	auditChainCfg audit.ChainConfig,
+	checkpointKey audit.Keys,
+	outboxCfg outbox.Config,
+	notificationsCfg notifications.Config,
+	adminToken string,
+	clientIpResolver *http_adapters.ClientIpResolver,
 	usersRepo auth.UsersRepository,
-	messagesSender auth.MessagesSender,
-) http.Handler {
+	messagesSender MessagesSender,
+) (*Router, error) {
+	auditModule := audit.New(
+		log.With(slog.String("module", "audit")),
+		pgxPool,
+	)
+	outboxModule := outbox.New(
+		log.With(slog.String("module", "outbox")),
+		pgxPool,
+		outboxCfg,
+	)
+	notificationsModule := notifications.New(
+		log.With(slog.String("module", "notifications")),
+		pgxPool,
+		notificationsCfg,
+		messagesSender,
+		outboxModule.Outbox,
+		auditModule.Log,
+	)
+	authModule, err := auth.New(
+		ctx,
+		log.With(slog.String("module", "auth")),
+		pgxPool,
+		authCfg,
+		usersRepo,
+		notificationsModule.Sender,
+		auditModule.Log,
+		outboxModule.Outbox,
+	)
+	if err != nil {
+		return nil, err
+	}
+	auditChain := audit.NewPgChain(
+		log.With(slog.String("component", "audit_chain")),
+		pgxPool,
+		checkpointKey,
+		auditChainCfg,
+		time.Now,
+	)
+	workers := []func(ctx context.Context){
+		auditChain.Run,
+		authModule.ReloadKeys,
+		outboxModule.NewDispatcher(notificationsModule.Sender).Run,
+	}
+	if notificationsModule.Aggregator != nil {
+		workers = append(workers, notificationsModule.Aggregator.Run)
+	}
 	router := http.NewServeMux()
-	router.Handle("/auth/",
-		http.StripPrefix("/auth", auth.New(
-			log.With(slog.String("module", "auth")),
-			pgxPool,
-			authSecret,
-			usersRepo,
-			messagesSender,
-		),
-		))
+	router.Handle("/auth/", http.StripPrefix("/auth", authModule.Router))
+	router.Handle("/auth/me/", http.StripPrefix("/auth", authModule.RequireUser(
+		notificationsModule.Router,
+	)))
+	// Без токена административные маршруты недоступны
+	if adminToken != "" {
+		router.Handle("/admin/", http.StripPrefix("/admin", http_adapters.BearerAuth(
+			adminToken,
+			authModule.AdminRouter,
+		)))
+		router.Handle("/admin/audit", http.StripPrefix("/admin", http_adapters.BearerAuth(
+			adminToken,
+			auditModule.AdminRouter,
+		)))
+	}
 	sLog := log.With(slog.String("component", "http_server"))
-	return http_adapters.Recover(
-		sLog,
-		http_adapters.Logging(
+	return &Router{
+		Handler: http_adapters.Recover(
 			sLog,
-			router,
+			http_adapters.ClientIp(
+				clientIpResolver,
+				http_adapters.Logging(
+					sLog,
+					router,
+				),
+			),
 		),
-	)
+		workers: workers,
+	}, nil
 }
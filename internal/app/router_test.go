@@ -6,13 +6,22 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/google/uuid"
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
 	"github.com/x0k/medods-authentication-service/internal/app"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/auth"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
 	email_messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender/email"
+	"github.com/x0k/medods-authentication-service/internal/notifications"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 	"github.com/x0k/medods-authentication-service/internal/testutils"
 	"github.com/x0k/medods-authentication-service/internal/users"
 )
@@ -27,22 +36,89 @@ func TestRouter(t *testing.T) {
 	})
 	log := logger.New(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	pgxPool := testutils.SetupPgxPool(ctx, log.Logger, t)
-	secret := []byte("secret")
-	usersRepo := users.NewInMemoryRepo()
+	signingKey, err := auth.NewSigningKey(auth.HS512, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointKey, err := auth.GenerateSigningKey(auth.EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usersRepo := users.NewPgRepository(log, pgxPool)
 	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	userEmail := "user@test.com"
 	senderEmail := "<admin@auth.com>"
-	usersRepo.Populate(map[uuid.UUID]string{
-		userId: userEmail,
-	})
+	if _, err := pgxPool.Exec(ctx, "INSERT INTO users (id, email) VALUES ($1, $2)", userId, userEmail); err != nil {
+		t.Fatal(err)
+	}
 	mailClient, mailApiClient := testutils.SetupMailClient(ctx, t)
+	emailTemplates, err := email_messages_sender.NewTemplates("", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
 	emailSender := email_messages_sender.New(
 		usersRepo,
 		mailClient,
 		senderEmail,
+		emailTemplates,
 	)
 
-	router := app.NewRouter(log, pgxPool, secret, usersRepo, emailSender)
+	adminToken := "admin"
+	clientIpResolver, err := http_adapters.NewClientIpResolver(http_adapters.XForwardedForHeader, []string{"127.0.0.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := app.NewRouter(
+		ctx,
+		log,
+		pgxPool,
+		&auth.Config{
+			SigningKey:           signingKey,
+			KeysEncryptionKey:    make([]byte, 32),
+			PublicUrl:            "http://auth.test",
+			ReportLinkTTL:        time.Hour,
+			KeyRetention:         time.Hour,
+			KeysReloadInterval:   time.Minute,
+			AccessTTL:            time.Minute,
+			RefreshTTL:           time.Hour,
+			ChallengeTTL:         time.Minute,
+			ChallengeMaxAttempts: 3,
+			IntrospectionClients: map[string]string{
+				"resource": "secret",
+			},
+		},
+		audit.ChainConfig{
+			SealInterval:       time.Hour,
+			CheckpointEvery:    2,
+			CheckpointInterval: time.Hour,
+		},
+		checkpointKey,
+		outbox.Config{
+			PollInterval: 10 * time.Millisecond,
+			BatchSize:    10,
+			MaxAttempts:  3,
+			BaseBackoff:  10 * time.Millisecond,
+			MaxBackoff:   time.Second,
+			Lease:        time.Minute,
+		},
+		notifications.Config{
+			Channels:         []string{messages_sender.EmailChannel},
+			FreshLoginMaxAge: time.Minute,
+		},
+		adminToken,
+		clientIpResolver,
+		usersRepo,
+		emailSender,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	waitWorkers := router.StartWorkers(workersCtx)
+	defer func() {
+		stopWorkers()
+		waitWorkers()
+	}()
 
 	server := httptest.NewServer(router)
 	defer server.Close()
@@ -54,7 +130,7 @@ func TestRouter(t *testing.T) {
 		Status(http.StatusOK).
 		JSON().Object()
 
-	resp.Keys().ContainsOnly("accessToken", "refreshToken")
+	resp.Keys().ContainsOnly("accessToken", "refreshToken", "expiresIn", "refreshExpiresIn")
 	accessToken := resp.Value("accessToken").String().Raw()
 	refreshToken := resp.Value("refreshToken").String().Raw()
 
@@ -66,10 +142,86 @@ func TestRouter(t *testing.T) {
 		Expect().
 		Status(http.StatusOK).
 		JSON().Object()
-	resp.Keys().ContainsOnly("accessToken", "refreshToken")
+	resp.Keys().ContainsOnly("accessToken", "refreshToken", "expiresIn", "refreshExpiresIn")
 	accessToken = resp.Value("accessToken").String().Raw()
 	refreshToken = resp.Value("refreshToken").String().Raw()
 
+	e.GET("/auth/me/notifications").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	preferences := e.GET("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preferences.Value("events").Array().ContainsOnly("suspicious_refresh", "token_reuse")
+	preferences.Value("channels").Array().ContainsOnly("email")
+	preferences.Value("quietHours").IsNull()
+	preferences.Value("digest").Boolean().IsFalse()
+
+	e.PUT("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		WithJSON(map[string]any{
+			"events":   []string{"token_reuse"},
+			"channels": []string{"sms"},
+		}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.PUT("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		WithJSON(map[string]any{
+			"events":   []string{"suspicious_refresh"},
+			"channels": []string{"email"},
+		}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.PUT("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		WithJSON(map[string]any{
+			"events":   []string{"token_reuse"},
+			"channels": []string{"email"},
+			"quietHours": map[string]string{
+				"start":    "22:00",
+				"end":      "07:00",
+				"timezone": "Europe/Moscow",
+			},
+			"digest": true,
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	preferences = e.GET("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preferences.Value("events").Array().ContainsOnly("token_reuse")
+	preferences.Value("quietHours").Object().Value("start").String().IsEqual("22:00")
+	preferences.Value("digest").Boolean().IsTrue()
+
+	// Остальные проверки ожидают предупреждения без задержки
+	e.PUT("/auth/me/notifications").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		WithJSON(map[string]any{
+			"events":   []string{"suspicious_refresh", "token_reuse"},
+			"channels": []string{"email"},
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/admin/keys/rotate").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	e.POST("/admin/keys/rotate").
+		WithHeader("Authorization", "Bearer "+adminToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("kid").String().NotEqual(signingKey.Id())
+
 	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		r.RemoteAddr = "127.0.0.2:8080"
 		router.ServeHTTP(w, r)
@@ -77,16 +229,246 @@ func TestRouter(t *testing.T) {
 	defer server.Close()
 	e = httpexpect.Default(t, server.URL)
 
+	resp = e.POST("/auth/refresh").
+		WithJSON(map[string]string{
+			"accessToken":  accessToken,
+			"refreshToken": refreshToken,
+		}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Keys().ContainsOnly("accessToken", "refreshToken", "expiresIn", "refreshExpiresIn")
+	accessToken = resp.Value("accessToken").String().Raw()
+	refreshToken = resp.Value("refreshToken").String().Raw()
+
+	e.POST("/auth/introspect").
+		WithFormField("token", accessToken).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	e.POST("/auth/introspect").
+		WithBasicAuth("resource", "secret").
+		WithFormField("token", accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("active", true).
+		HasValue("sub", userId.String())
+
+	e.POST("/auth/revoke").
+		WithFormField("token", refreshToken).
+		WithFormField("token_type_hint", "refresh_token").
+		Expect().
+		Status(http.StatusOK)
+
 	e.POST("/auth/refresh").
 		WithJSON(map[string]string{
 			"accessToken":  accessToken,
 			"refreshToken": refreshToken,
 		}).
 		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/auth/introspect").
+		WithBasicAuth("resource", "secret").
+		WithFormField("token", accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("active", false).
+		HasValue("revoked", true)
+
+	accessToken = e.POST("/auth/login").
+		WithQuery("GUID", userId.String()).
+		WithHeader("User-Agent", "test").
+		Expect().
 		Status(http.StatusOK).
-		JSON().Object().Keys().ContainsOnly("accessToken", "refreshToken")
+		JSON().Object().Value("accessToken").String().Raw()
+
+	sessions := e.GET("/auth/sessions").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array()
+	sessions.Length().IsEqual(1)
+	session := sessions.Value(0).Object()
+	session.HasValue("current", true).HasValue("userAgent", "test")
+	sessionId := session.Value("id").String().Raw()
+
+	e.GET("/admin/users/{id}/sessions", userId).
+		WithHeader("Authorization", "Bearer "+adminToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array().Value(0).Object().
+		HasValue("id", sessionId).
+		HasValue("refreshCount", 0).
+		HasValue("current", false)
+
+	e.DELETE("/auth/sessions/{id}", sessionId).
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusNoContent)
+
+	e.DELETE("/auth/sessions/{id}", sessionId).
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusNotFound)
 
+	e.DELETE("/auth/sessions").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	e.DELETE("/auth/sessions").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusNoContent)
+
+	e.POST("/auth/introspect").
+		WithBasicAuth("resource", "secret").
+		WithFormField("token", accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("active", false).
+		HasValue("revoked", true)
+
+	e.DELETE("/admin/users/{id}/sessions", userId).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	e.DELETE("/admin/users/{id}/sessions", userId).
+		WithHeader("Authorization", "Bearer "+adminToken).
+		Expect().
+		Status(http.StatusNoContent)
+
+	deviceId := "9f1c2f0e-6a0b-4a53-a1a4-0d5e9a1b2c3d"
+	resp = e.POST("/auth/login").
+		WithQuery("GUID", userId.String()).
+		WithJSON(map[string]string{"deviceId": deviceId}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	pair := map[string]string{
+		"accessToken":  resp.Value("accessToken").String().Raw(),
+		"refreshToken": resp.Value("refreshToken").String().Raw(),
+	}
+
+	e.POST("/auth/refresh").
+		WithHeader(auth.DeviceIdHeader, "another-device-identifier").
+		WithJSON(pair).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/auth/refresh").
+		WithHeader(auth.DeviceIdHeader, deviceId).
+		WithJSON(pair).
+		Expect().
+		Status(http.StatusOK)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = "127.0.0.3:8080"
+		router.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	accessToken = httpexpect.Default(t, proxy.URL).POST("/auth/login").
+		WithQuery("GUID", userId.String()).
+		WithHeader("X-Forwarded-For", "203.0.113.7").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("accessToken").String().Raw()
+
+	e.POST("/auth/introspect").
+		WithBasicAuth("resource", "secret").
+		WithFormField("token", accessToken).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("ip", "203.0.113.7")
+
+	e.GET("/auth/.well-known/jwks.json").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("keys").Array().IsEmpty()
+
+	auditPage := e.GET("/admin/audit").
+		WithHeader("Authorization", "Bearer "+adminToken).
+		WithQuery("userId", userId.String()).
+		WithQuery("type", "login").
+		WithQuery("limit", 1).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	auditPage.Value("events").Array().Length().IsEqual(1)
+	nextCursor := auditPage.Value("nextCursor").String().NotEmpty().Raw()
+
+	e.GET("/admin/audit").
+		WithHeader("Authorization", "Bearer "+adminToken).
+		WithQuery("ip", "203.0.113.0/24").
+		WithQuery("format", "csv").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/csv").
+		Body().Contains("203.0.113.7")
+
+	e.GET("/admin/audit").
+		WithHeader("Authorization", "Bearer "+adminToken).
+		WithQuery("userId", userId.String()).
+		WithQuery("type", "login").
+		WithQuery("cursor", nextCursor).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("events").Array().NotEmpty()
+
+	e.GET("/admin/audit").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	var auditEvents int
+	if err := pgxPool.QueryRow(
+		ctx,
+		`SELECT count(*) FROM audit_event WHERE user_id = $1 AND type = 'login' AND outcome = 'success'`,
+		userId,
+	).Scan(&auditEvents); err != nil {
+		t.Fatal(err)
+	}
+	if auditEvents == 0 {
+		t.Error("expected login events in audit log")
+	}
+
+	auditChain := audit.NewPgChain(log, pgxPool, checkpointKey, audit.ChainConfig{
+		CheckpointEvery:    2,
+		CheckpointInterval: time.Hour,
+	}, time.Now)
+	if _, err := auditChain.Seal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	report, err := auditChain.Verify(ctx, checkpointKey.KeyFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken != nil {
+		t.Fatalf("unexpected broken link: %s", report.Broken)
+	}
+	if report.Events < 2 || report.Checkpoints == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if _, err := pgxPool.Exec(ctx, `UPDATE audit_event SET reason = 'tampered' WHERE seq = 1`); err != nil {
+		t.Fatal(err)
+	}
+	report, err = auditChain.Verify(ctx, checkpointKey.KeyFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken == nil || report.Broken.Seq != 1 {
+		t.Fatalf("expected broken link at seq 1, got %+v", report.Broken)
+	}
+
+	// Предупреждение доставляется из очереди сообщений асинхронно
 	messages, err := mailApiClient.ListMailbox(userEmail)
+	for deadline := time.Now().Add(5 * time.Second); err == nil && len(messages) == 0 && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		messages, err = mailApiClient.ListMailbox(userEmail)
+	}
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -96,4 +478,37 @@ func TestRouter(t *testing.T) {
 	if messages[0].From != senderEmail {
 		t.Errorf("expected sender %s, got %s", senderEmail, messages[0].From)
 	}
+
+	message, err := mailApiClient.GetMessage(userEmail, messages[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := reportTokenPattern.FindStringSubmatch(message.Body.Text)
+	if match == nil {
+		t.Fatalf("expected report link in message: %s", message.Body.Text)
+	}
+	reportToken, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.GET("/auth/security/report").
+		WithQuery("token", reportToken).
+		Expect().
+		Status(http.StatusOK).
+		Body().Contains("Sign out this session")
+	e.POST("/auth/security/report").
+		WithFormField("token", reportToken).
+		WithFormField("scope", "all").
+		Expect().
+		Status(http.StatusOK)
+	e.POST("/auth/security/report").
+		WithFormField("token", reportToken).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.GET("/auth/sessions").
+		WithHeader("Authorization", "Bearer "+accessToken).
+		Expect().
+		Status(http.StatusUnauthorized)
 }
+
+var reportTokenPattern = regexp.MustCompile(`/auth/security/report\?token=(\S+)`)
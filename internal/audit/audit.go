@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+type EventType string
+
+const (
+	LoginEvent   EventType = "login"
+	RefreshEvent EventType = "refresh"
+	RevokeEvent  EventType = "revoke"
+	// Повторное использование выведенного из оборота Refresh токена
+	ReuseEvent EventType = "reuse"
+	// Несовпадение устройства, рискованное обновление и т.п.
+	AnomalyEvent EventType = "anomaly"
+	// Пользователь сообщил о подозрительной активности по ссылке из предупреждения
+	ReportEvent EventType = "report"
+	// Изменение настроек уведомлений о безопасности
+	PreferencesEvent EventType = "preferences"
+)
+
+type Outcome string
+
+const (
+	SuccessOutcome Outcome = "success"
+	FailureOutcome Outcome = "failure"
+	DeniedOutcome  Outcome = "denied"
+	// Требуется подтверждение одноразовым кодом
+	ChallengedOutcome Outcome = "challenged"
+)
+
+type Event struct {
+	Id        uuid.UUID
+	Type      EventType
+	Outcome   Outcome
+	UserId    uuid.UUID
+	DeviceId  []byte
+	IpAddress string
+	UserAgent string
+	// Причина исхода или дополнительные сведения
+	Reason    string
+	CreatedAt time.Time
+}
+
+// Запись выполняется в той же единице работы, что и изменение токенов
+type AuditLog[T any] interface {
+	Record(ctx context.Context, uow unit_of_work.UnitOfWork[T], event Event) error
+}
+
+func (t EventType) Valid() bool {
+	switch t {
+	case LoginEvent, RefreshEvent, RevokeEvent, ReuseEvent, AnomalyEvent, ReportEvent, PreferencesEvent:
+		return true
+	}
+	return false
+}
+
+// Нулевые значения полей не ограничивают выборку
+type Filter struct {
+	UserId uuid.UUID
+	Types  []EventType
+	// Отдельный адрес задается префиксом максимальной длины
+	Network netip.Prefix
+	From    time.Time
+	// Не включается в выборку
+	To time.Time
+	// Позиция, после которой продолжается выборка
+	After *Cursor
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+// Реализуется `auth.SigningKey`. Ключ чекпоинтов не должен храниться в базе
+// данных журнала, иначе доступ к ней позволял бы переподписать цепочку.
+type Keys interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// Событие, которому назначено место в цепочке
+type SealedEvent struct {
+	Event
+	Seq  int64
+	Hash []byte
+}
+
+type Checkpoint struct {
+	Seq       int64
+	Hash      []byte
+	Signature string
+	CreatedAt time.Time
+}
+
+// Хеш покрывает содержимое события, его номер и хеш предыдущего события.
+// Время учитывается с точностью до микросекунд, как оно хранится в Postgres.
+func chainHash(prev []byte, seq int64, e Event) []byte {
+	h := sha256.New()
+	writeField := func(b []byte) {
+		_ = binary.Write(h, binary.BigEndian, uint32(len(b)))
+		h.Write(b)
+	}
+	writeInt := func(n int64) {
+		_ = binary.Write(h, binary.BigEndian, n)
+	}
+	writeField(prev)
+	writeInt(seq)
+	writeField(e.Id[:])
+	writeField([]byte(e.Type))
+	writeField([]byte(e.Outcome))
+	writeField(e.UserId[:])
+	writeField(e.DeviceId)
+	writeField([]byte(e.IpAddress))
+	writeField([]byte(e.UserAgent))
+	writeField([]byte(e.Reason))
+	writeInt(e.CreatedAt.UnixMicro())
+	return h.Sum(nil)
+}
+
+func signCheckpoint(keys Keys, seq int64, hash []byte, now time.Time) (string, error) {
+	return keys.Sign(jwt.MapClaims{
+		"seq":  seq,
+		"hash": base64.RawURLEncoding.EncodeToString(hash),
+		"iat":  now.Unix(),
+	})
+}
+
+func verifyCheckpoint(keyFunc jwt.Keyfunc, checkpoint Checkpoint) error {
+	token, err := jwt.Parse(checkpoint.Signature, keyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCheckpoint, err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	seq, ok := claims["seq"].(float64)
+	if !ok || int64(seq) != checkpoint.Seq {
+		return fmt.Errorf("%w: seq mismatch", ErrInvalidCheckpoint)
+	}
+	hash, ok := claims["hash"].(string)
+	if !ok || hash != base64.RawURLEncoding.EncodeToString(checkpoint.Hash) {
+		return fmt.Errorf("%w: hash mismatch", ErrInvalidCheckpoint)
+	}
+	return nil
+}
+
+type Break struct {
+	Seq     int64
+	EventId uuid.UUID
+	Reason  string
+}
+
+func (b Break) String() string {
+	if b.EventId == uuid.Nil {
+		return fmt.Sprintf("seq %d: %s", b.Seq, b.Reason)
+	}
+	return fmt.Sprintf("seq %d (event %s): %s", b.Seq, b.EventId, b.Reason)
+}
+
+type Report struct {
+	Events      int64
+	Checkpoints int
+	// События, еще не добавленные в цепочку
+	Unsealed int64
+	// Первое нарушение цепочки, включая чекпоинты с неизвестным ключом
+	Broken *Break
+}
+
+// Проверяет события в порядке номеров, останавливается на первом нарушении
+type chainVerifier struct {
+	keyFunc     jwt.Keyfunc
+	checkpoints map[int64]Checkpoint
+	report      Report
+	prev        []byte
+	lastSeq     int64
+}
+
+func newChainVerifier(keyFunc jwt.Keyfunc, checkpoints []Checkpoint) *chainVerifier {
+	v := &chainVerifier{
+		keyFunc:     keyFunc,
+		checkpoints: make(map[int64]Checkpoint, len(checkpoints)),
+	}
+	for _, c := range checkpoints {
+		v.checkpoints[c.Seq] = c
+	}
+	return v
+}
+
+func (v *chainVerifier) next(e SealedEvent) bool {
+	if e.Seq != v.lastSeq+1 {
+		v.report.Broken = &Break{
+			Seq:    v.lastSeq + 1,
+			Reason: fmt.Sprintf("event is missing, next event has seq %d", e.Seq),
+		}
+		return false
+	}
+	expected := chainHash(v.prev, e.Seq, e.Event)
+	if string(expected) != string(e.Hash) {
+		v.report.Broken = &Break{Seq: e.Seq, EventId: e.Id, Reason: "hash mismatch"}
+		return false
+	}
+	if c, ok := v.checkpoints[e.Seq]; ok {
+		if string(c.Hash) != string(e.Hash) {
+			v.report.Broken = &Break{Seq: e.Seq, EventId: e.Id, Reason: "checkpoint hash mismatch"}
+			return false
+		}
+		// Подпись неизвестным ключом не отличить от подделки
+		if err := verifyCheckpoint(v.keyFunc, c); err != nil {
+			v.report.Broken = &Break{Seq: e.Seq, EventId: e.Id, Reason: err.Error()}
+			return false
+		}
+		v.report.Checkpoints++
+	}
+	v.prev = e.Hash
+	v.lastSeq = e.Seq
+	v.report.Events++
+	return true
+}
+
+// Чекпоинты после последнего события означают удаление конца цепочки
+func (v *chainVerifier) finish() Report {
+	if v.report.Broken != nil {
+		return v.report
+	}
+	for seq := range v.checkpoints {
+		if seq > v.lastSeq {
+			v.report.Broken = &Break{
+				Seq:    v.lastSeq + 1,
+				Reason: fmt.Sprintf("events up to checkpoint %d are missing", seq),
+			}
+			break
+		}
+	}
+	return v.report
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testKeys struct {
+	kid    string
+	secret []byte
+	known  bool
+}
+
+func (k testKeys) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.secret)
+}
+
+func (k testKeys) KeyFunc(t *jwt.Token) (any, error) {
+	if !k.known {
+		return nil, fmt.Errorf("unknown signing key")
+	}
+	return k.secret, nil
+}
+
+// Строит цепочку так же, как это делает `PgChain.Seal`
+func testChain(t *testing.T, keys Keys, n int, checkpointEvery int64) ([]SealedEvent, []Checkpoint) {
+	t.Helper()
+	var (
+		prev        []byte
+		sealed      []SealedEvent
+		checkpoints []Checkpoint
+	)
+	for i, e := range testEvents(n) {
+		seq := int64(i + 1)
+		prev = chainHash(prev, seq, e)
+		sealed = append(sealed, SealedEvent{Event: e, Seq: seq, Hash: prev})
+		if seq%checkpointEvery == 0 {
+			signature, err := signCheckpoint(keys, seq, prev, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkpoints = append(checkpoints, Checkpoint{Seq: seq, Hash: prev, Signature: signature})
+		}
+	}
+	return sealed, checkpoints
+}
+
+func TestChainVerifier(t *testing.T) {
+	keys := testKeys{kid: "test", secret: []byte("secret"), known: true}
+	cases := []struct {
+		name       string
+		verifyKeys *testKeys
+		tamper     func([]SealedEvent, []Checkpoint) ([]SealedEvent, []Checkpoint)
+		brokenAt   int64
+	}{
+		{
+			name: "should accept intact chain",
+		},
+		{
+			name: "should detect changed event",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				events[2].Reason = "tampered"
+				return events, checkpoints
+			},
+			brokenAt: 3,
+		},
+		{
+			name: "should detect rehashed event",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				events[3].Reason = "tampered"
+				events[3].Hash = chainHash(events[2].Hash, 4, events[3].Event)
+				return events, checkpoints
+			},
+			brokenAt: 5,
+		},
+		{
+			name: "should detect deleted event",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				return append(events[:4], events[5:]...), checkpoints
+			},
+			brokenAt: 5,
+		},
+		{
+			name: "should detect truncated chain",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				return events[:7], checkpoints
+			},
+			brokenAt: 8,
+		},
+		{
+			name: "should detect rewritten tail",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				prev := events[4].Hash
+				for i := 5; i < len(events); i++ {
+					events[i].Reason = "tampered"
+					events[i].Hash = chainHash(prev, events[i].Seq, events[i].Event)
+					prev = events[i].Hash
+				}
+				return events, checkpoints
+			},
+			brokenAt: 6,
+		},
+		{
+			name: "should detect forged checkpoint",
+			tamper: func(events []SealedEvent, checkpoints []Checkpoint) ([]SealedEvent, []Checkpoint) {
+				forger := testKeys{kid: "test", secret: []byte("forged"), known: true}
+				signature, err := signCheckpoint(forger, 3, checkpoints[0].Hash, time.Now())
+				if err != nil {
+					panic(err)
+				}
+				checkpoints[0].Signature = signature
+				return events, checkpoints
+			},
+			brokenAt: 3,
+		},
+		{
+			name:       "should detect checkpoint signed by unknown key",
+			verifyKeys: &testKeys{kid: "test", secret: []byte("secret")},
+			brokenAt:   3,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			events, checkpoints := testChain(t, keys, 10, 3)
+			if tc.tamper != nil {
+				events, checkpoints = tc.tamper(events, checkpoints)
+			}
+			verifyKeys := tc.verifyKeys
+			if verifyKeys == nil {
+				verifyKeys = &keys
+			}
+			v := newChainVerifier(verifyKeys.KeyFunc, checkpoints)
+			for _, e := range events {
+				if !v.next(e) {
+					break
+				}
+			}
+			report := v.finish()
+			if tc.brokenAt == 0 {
+				if report.Broken != nil {
+					t.Fatalf("unexpected broken link: %s", report.Broken)
+				}
+				if report.Events != int64(len(events)) {
+					t.Errorf("expected %d events, got %d", len(events), report.Events)
+				}
+			} else if report.Broken == nil || report.Broken.Seq != tc.brokenAt {
+				t.Fatalf("expected broken link at seq %d, got %+v", tc.brokenAt, report.Broken)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+const NextCursorHeader = "X-Next-Cursor"
+
+type AuditService interface {
+	Search(ctx context.Context, filter Filter, pageSize int) (Page, *shared.DomainError)
+}
+
+type controller struct {
+	log          *logger.Logger
+	auditService AuditService
+}
+
+func newController(log *logger.Logger, auditService AuditService) *controller {
+	return &controller{
+		log:          log,
+		auditService: auditService,
+	}
+}
+
+type eventDTO struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	Outcome   string `json:"outcome"`
+	UserId    string `json:"userId"`
+	DeviceId  string `json:"deviceId,omitempty"`
+	IpAddress string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func newEventDTO(e Event) eventDTO {
+	return eventDTO{
+		Id:        e.Id.String(),
+		Type:      string(e.Type),
+		Outcome:   string(e.Outcome),
+		UserId:    e.UserId.String(),
+		DeviceId:  base64.RawURLEncoding.EncodeToString(e.DeviceId),
+		IpAddress: e.IpAddress,
+		UserAgent: e.UserAgent,
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+type eventsDTO struct {
+	Events     []eventDTO `json:"events"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// Фильтры: `userId`, `type` (можно повторять или перечислять через запятую),
+// `ip` (адрес или CIDR), `from` и `to` (RFC 3339), `limit` и `cursor`.
+// Формат ответа выбирается параметром `format` (`json` или `csv`) или заголовком `Accept`.
+func (c *controller) Events(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, pageSize, err := parseFilter(q)
+	if err != nil {
+		c.badRequest(w, r, err, err.Error())
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		c.badRequest(w, r, fmt.Errorf("%w: unknown format %q", ErrInvalidFilter, format), "unknown format")
+		return
+	}
+	page, dErr := c.auditService.Search(r.Context(), filter, pageSize)
+	if dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	var nextCursor string
+	if page.Next != nil {
+		nextCursor = page.Next.Encode()
+		w.Header().Set(NextCursorHeader, nextCursor)
+	}
+	if format == "csv" {
+		c.csv(w, r, page.Events)
+		return
+	}
+	events := make([]eventDTO, 0, len(page.Events))
+	for _, e := range page.Events {
+		events = append(events, newEventDTO(e))
+	}
+	c.json(w, r, eventsDTO{Events: events, NextCursor: nextCursor}, http.StatusOK)
+}
+
+func parseFilter(q map[string][]string) (Filter, int, error) {
+	get := func(key string) string {
+		if values := q[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	var filter Filter
+	if v := get("userId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return Filter{}, 0, fmt.Errorf("%w: invalid userId", ErrInvalidFilter)
+		}
+		filter.UserId = id
+	}
+	for _, value := range q["type"] {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, EventType(t))
+			}
+		}
+	}
+	if v := get("ip"); v != "" {
+		network, err := parseNetwork(v)
+		if err != nil {
+			return Filter{}, 0, fmt.Errorf("%w: invalid ip", ErrInvalidFilter)
+		}
+		filter.Network = network
+	}
+	for key, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return Filter{}, 0, fmt.Errorf("%w: invalid %s", ErrInvalidFilter, key)
+			}
+			*dst = t
+		}
+	}
+	if v := get("cursor"); v != "" {
+		cursor, err := DecodeCursor(v)
+		if err != nil {
+			return Filter{}, 0, fmt.Errorf("%w: invalid cursor", ErrInvalidFilter)
+		}
+		filter.After = &cursor
+	}
+	var pageSize int
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Filter{}, 0, fmt.Errorf("%w: invalid limit", ErrInvalidFilter)
+		}
+		pageSize = n
+	}
+	return filter, pageSize, nil
+}
+
+func parseNetwork(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+var csvHeader = []string{"id", "type", "outcome", "user_id", "device_id", "ip", "user_agent", "reason", "created_at"}
+
+func (c *controller) csv(w http.ResponseWriter, r *http.Request, events []Event) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		c.log.Error(r.Context(), "failed to write CSV", sl.Err(err))
+		return
+	}
+	for _, e := range events {
+		dto := newEventDTO(e)
+		if err := cw.Write([]string{
+			dto.Id,
+			dto.Type,
+			dto.Outcome,
+			dto.UserId,
+			csvCell(dto.DeviceId),
+			csvCell(dto.IpAddress),
+			csvCell(dto.UserAgent),
+			csvCell(dto.Reason),
+			dto.CreatedAt,
+		}); err != nil {
+			c.log.Error(r.Context(), "failed to write CSV", sl.Err(err))
+			return
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		c.log.Error(r.Context(), "failed to write CSV", sl.Err(err))
+	}
+}
+
+// Табличные редакторы выполняют ячейки, начинающиеся с этих символов, как формулы,
+// поэтому значения, которые передает клиент, экранируются апострофом
+// https://owasp.org/www-community/attacks/CSV_Injection
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (c *controller) domainError(w http.ResponseWriter, r *http.Request, err *shared.DomainError) {
+	if err.Expected {
+		c.badRequest(w, r, err.Err, err.Msg)
+	} else {
+		c.serverError(w, r, err.Err, err.Msg)
+	}
+}
+
+func (c *controller) badRequest(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	http.Error(w, msg, http.StatusBadRequest)
+	c.log.Debug(r.Context(), msg, sl.Err(err))
+}
+
+func (c *controller) serverError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	http.Error(w, msg, http.StatusInternalServerError)
+	c.log.Error(r.Context(), msg, sl.Err(err))
+}
+
+func (c *controller) json(w http.ResponseWriter, r *http.Request, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		c.serverError(w, r, err, "failed to encode JSON")
+	}
+}
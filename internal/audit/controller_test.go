@@ -0,0 +1,27 @@
+package audit
+
+import "testing"
+
+func TestCsvCell(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "should keep plain value", value: "Mozilla/5.0", expected: "Mozilla/5.0"},
+		{name: "should keep empty value", value: "", expected: ""},
+		{name: "should escape formula", value: "=HYPERLINK(\"http://evil\")", expected: "'=HYPERLINK(\"http://evil\")"},
+		{name: "should escape plus", value: "+1", expected: "'+1"},
+		{name: "should escape minus", value: "-1+1", expected: "'-1+1"},
+		{name: "should escape at sign", value: "@SUM(A1)", expected: "'@SUM(A1)"},
+		{name: "should escape tab", value: "\t=1", expected: "'\t=1"},
+		{name: "should keep formula characters inside value", value: "a=b", expected: "a=b"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := csvCell(tc.value); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
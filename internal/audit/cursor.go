@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// События упорядочены от новых к старым, курсор указывает на последнее
+// возвращенное событие
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Id        uuid.UUID `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	if cursor.Id == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,85 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package audit
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+// MockAuditLog is an autogenerated mock type for the AuditLog type
+type MockAuditLog[T any] struct {
+	mock.Mock
+}
+
+type MockAuditLog_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAuditLog[T]) EXPECT() *MockAuditLog_Expecter[T] {
+	return &MockAuditLog_Expecter[T]{mock: &_m.Mock}
+}
+
+// Record provides a mock function with given fields: ctx, uow, event
+func (_m *MockAuditLog[T]) Record(ctx context.Context, uow unit_of_work.UnitOfWork[T], event Event) error {
+	ret := _m.Called(ctx, uow, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Record")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], Event) error); ok {
+		r0 = rf(ctx, uow, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockAuditLog_Record_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Record'
+type MockAuditLog_Record_Call[T any] struct {
+	*mock.Call
+}
+
+// Record is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - event Event
+func (_e *MockAuditLog_Expecter[T]) Record(ctx interface{}, uow interface{}, event interface{}) *MockAuditLog_Record_Call[T] {
+	return &MockAuditLog_Record_Call[T]{Call: _e.mock.On("Record", ctx, uow, event)}
+}
+
+func (_c *MockAuditLog_Record_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], event Event)) *MockAuditLog_Record_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(Event))
+	})
+	return _c
+}
+
+func (_c *MockAuditLog_Record_Call[T]) Return(_a0 error) *MockAuditLog_Record_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAuditLog_Record_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], Event) error) *MockAuditLog_Record_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockAuditLog creates a new instance of MockAuditLog. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAuditLog[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAuditLog[T] {
+	mock := &MockAuditLog[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,96 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package audit
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockEventsRepository is an autogenerated mock type for the EventsRepository type
+type MockEventsRepository struct {
+	mock.Mock
+}
+
+type MockEventsRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockEventsRepository) EXPECT() *MockEventsRepository_Expecter {
+	return &MockEventsRepository_Expecter{mock: &_m.Mock}
+}
+
+// Events provides a mock function with given fields: ctx, filter, limit
+func (_m *MockEventsRepository) Events(ctx context.Context, filter Filter, limit int) ([]Event, error) {
+	ret := _m.Called(ctx, filter, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Events")
+	}
+
+	var r0 []Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, Filter, int) ([]Event, error)); ok {
+		return rf(ctx, filter, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, Filter, int) []Event); ok {
+		r0 = rf(ctx, filter, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, Filter, int) error); ok {
+		r1 = rf(ctx, filter, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockEventsRepository_Events_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Events'
+type MockEventsRepository_Events_Call struct {
+	*mock.Call
+}
+
+// Events is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter Filter
+//   - limit int
+func (_e *MockEventsRepository_Expecter) Events(ctx interface{}, filter interface{}, limit interface{}) *MockEventsRepository_Events_Call {
+	return &MockEventsRepository_Events_Call{Call: _e.mock.On("Events", ctx, filter, limit)}
+}
+
+func (_c *MockEventsRepository_Events_Call) Run(run func(ctx context.Context, filter Filter, limit int)) *MockEventsRepository_Events_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Filter), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockEventsRepository_Events_Call) Return(_a0 []Event, _a1 error) *MockEventsRepository_Events_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockEventsRepository_Events_Call) RunAndReturn(run func(context.Context, Filter, int) ([]Event, error)) *MockEventsRepository_Events_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockEventsRepository creates a new instance of MockEventsRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockEventsRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockEventsRepository {
+	mock := &MockEventsRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
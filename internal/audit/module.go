@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+)
+
+type Module struct {
+	Log         *PgAuditLog
+	AdminRouter *http.ServeMux
+}
+
+func New(log *logger.Logger, pgxPool *pgxpool.Pool) *Module {
+	auditLog := NewPgAuditLog(
+		log.With(slog.String("component", "audit_log")),
+		pgxPool,
+	)
+	service := newService(
+		log.With(slog.String("component", "service")),
+		auditLog,
+	)
+	controller := newController(
+		log.With(slog.String("component", "controller")),
+		service,
+	)
+	return &Module{
+		Log:         auditLog,
+		AdminRouter: newAdminRouter(controller),
+	}
+}
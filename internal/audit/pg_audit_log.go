@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+type PgAuditLog struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func NewPgAuditLog(log *logger.Logger, pool *pgxpool.Pool) *PgAuditLog {
+	return &PgAuditLog{
+		log:  log,
+		pool: pool,
+	}
+}
+
+// Столбец `ip` дублирует адрес для поиска по подсети и пуст для
+// адресов, которые не удалось разобрать
+const recordEventQuery = `INSERT INTO audit_event (id, type, outcome, user_id, device_id, ip_address, ip, user_agent, reason, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+func (l *PgAuditLog) Record(ctx context.Context, uow unit_of_work.UnitOfWork[pgx.Tx], event Event) error {
+	if event.Id == uuid.Nil {
+		event.Id = uuid.New()
+	}
+	var ip *netip.Addr
+	if addr, err := netip.ParseAddr(event.IpAddress); err == nil {
+		ip = &addr
+	}
+	args := []any{
+		event.Id,
+		event.Type,
+		event.Outcome,
+		event.UserId,
+		event.DeviceId,
+		event.IpAddress,
+		ip,
+		event.UserAgent,
+		event.Reason,
+		event.CreatedAt,
+	}
+	l.log.Debug(ctx, "executing query", slog.String("query", recordEventQuery), slog.Any("args", args[:4]))
+	_, err := uow.Tx().Exec(ctx, recordEventQuery, args...)
+	return err
+}
+
+const eventsQuery = `SELECT id, type, outcome, user_id, device_id, ip_address, user_agent, reason, created_at
+FROM audit_event`
+
+func (l *PgAuditLog) Events(ctx context.Context, filter Filter, limit int) ([]Event, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.UserId != uuid.Nil {
+		conditions = append(conditions, "user_id = "+arg(filter.UserId))
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, 0, len(filter.Types))
+		for _, t := range filter.Types {
+			types = append(types, string(t))
+		}
+		conditions = append(conditions, "type = ANY("+arg(types)+")")
+	}
+	if filter.Network.IsValid() {
+		conditions = append(conditions, "ip <<= "+arg(filter.Network))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To))
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"(created_at, id) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.Id),
+		))
+	}
+	var query strings.Builder
+	query.WriteString(eventsQuery)
+	if len(conditions) > 0 {
+		query.WriteString("\nWHERE ")
+		query.WriteString(strings.Join(conditions, " AND "))
+	}
+	query.WriteString("\nORDER BY created_at DESC, id DESC\nLIMIT ")
+	query.WriteString(arg(limit))
+	q := query.String()
+	l.log.Debug(ctx, "executing query", slog.String("query", q), slog.Any("args", args))
+	rows, err := l.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanEvent)
+}
+
+func scanEvent(row pgx.CollectableRow) (Event, error) {
+	var event Event
+	err := row.Scan(
+		&event.Id,
+		&event.Type,
+		&event.Outcome,
+		&event.UserId,
+		&event.DeviceId,
+		&event.IpAddress,
+		&event.UserAgent,
+		&event.Reason,
+		&event.CreatedAt,
+	)
+	return event, err
+}
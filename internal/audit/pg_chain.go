@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+)
+
+const sealBatchSize = 500
+
+type ChainConfig struct {
+	// Как часто новые события добавляются в цепочку
+	SealInterval time.Duration
+	// Чекпоинт создается через указанное число событий
+	CheckpointEvery int64
+	// или по истечении интервала, если с прошлого чекпоинта были события
+	CheckpointInterval time.Duration
+}
+
+// События записываются в транзакциях бизнес-операций без номера и хеша,
+// номера назначаются отдельно, чтобы не создавать конкуренции за конец цепочки.
+type PgChain struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+	keys Keys
+	cfg  ChainConfig
+	now  func() time.Time
+}
+
+func NewPgChain(
+	log *logger.Logger,
+	pool *pgxpool.Pool,
+	keys Keys,
+	cfg ChainConfig,
+	now func() time.Time,
+) *PgChain {
+	return &PgChain{
+		log:  log,
+		pool: pool,
+		keys: keys,
+		cfg:  cfg,
+		now:  now,
+	}
+}
+
+func (c *PgChain) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.SealInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			sealed, err := c.Seal(ctx)
+			if err != nil {
+				c.log.Error(ctx, "failed to seal audit events", sl.Err(err))
+				break
+			}
+			if sealed < sealBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Блокировка исключает параллельное продолжение цепочки несколькими экземплярами
+const lockChainQuery = `SELECT pg_advisory_xact_lock(hashtext('audit_chain'))`
+
+const chainHeadQuery = `SELECT seq, hash FROM audit_event WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`
+
+const lastCheckpointQuery = `SELECT seq, created_at FROM audit_checkpoint ORDER BY seq DESC LIMIT 1`
+
+const unsealedEventsQuery = `SELECT id, type, outcome, user_id, device_id, ip_address, user_agent, reason, created_at
+FROM audit_event
+WHERE seq IS NULL
+ORDER BY created_at, id
+LIMIT $1`
+
+const sealEventQuery = `UPDATE audit_event SET seq = $2, hash = $3 WHERE id = $1`
+
+const insertCheckpointQuery = `INSERT INTO audit_checkpoint (seq, hash, signature, created_at) VALUES ($1, $2, $3, $4)`
+
+// Добавляет в цепочку очередную порцию событий и возвращает их количество
+func (c *PgChain) Seal(ctx context.Context) (int, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			c.log.Error(ctx, "failed to rollback transaction", sl.Err(err))
+		}
+	}()
+	if _, err := tx.Exec(ctx, lockChainQuery); err != nil {
+		return 0, err
+	}
+	var (
+		seq  int64
+		prev []byte
+	)
+	c.log.Debug(ctx, "executing query", slog.String("query", chainHeadQuery))
+	if err := tx.QueryRow(ctx, chainHeadQuery).Scan(&seq, &prev); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	var (
+		checkpointSeq int64
+		checkpointAt  time.Time
+	)
+	c.log.Debug(ctx, "executing query", slog.String("query", lastCheckpointQuery))
+	if err := tx.QueryRow(ctx, lastCheckpointQuery).Scan(&checkpointSeq, &checkpointAt); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	c.log.Debug(ctx, "executing query", slog.String("query", unsealedEventsQuery))
+	rows, err := tx.Query(ctx, unsealedEventsQuery, sealBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	events, err := pgx.CollectRows(rows, scanEvent)
+	if err != nil {
+		return 0, err
+	}
+	now := c.now()
+	checkpoint := func() error {
+		signature, err := signCheckpoint(c.keys, seq, prev, now)
+		if err != nil {
+			return err
+		}
+		c.log.Debug(ctx, "executing query", slog.String("query", insertCheckpointQuery), slog.Int64("seq", seq))
+		if _, err := tx.Exec(ctx, insertCheckpointQuery, seq, prev, signature, now); err != nil {
+			return err
+		}
+		checkpointSeq = seq
+		checkpointAt = now
+		return nil
+	}
+	for _, e := range events {
+		seq++
+		prev = chainHash(prev, seq, e)
+		c.log.Debug(ctx, "executing query", slog.String("query", sealEventQuery), slog.Int64("seq", seq))
+		if _, err := tx.Exec(ctx, sealEventQuery, e.Id, seq, prev); err != nil {
+			return 0, err
+		}
+		if seq-checkpointSeq >= c.cfg.CheckpointEvery {
+			if err := checkpoint(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if seq > checkpointSeq && now.Sub(checkpointAt) >= c.cfg.CheckpointInterval {
+		if err := checkpoint(); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit(ctx)
+}
+
+const checkpointsQuery = `SELECT seq, hash, signature, created_at FROM audit_checkpoint ORDER BY seq`
+
+const sealedEventsQuery = `SELECT id, type, outcome, user_id, device_id, ip_address, user_agent, reason, created_at, seq, hash
+FROM audit_event
+WHERE seq IS NOT NULL
+ORDER BY seq`
+
+const unsealedEventsCountQuery = `SELECT count(*) FROM audit_event WHERE seq IS NULL`
+
+// Проходит цепочку целиком на согласованном снимке данных.
+// Ключи проверки чекпоинтов не должны браться из проверяемой базы данных.
+func (c *PgChain) Verify(ctx context.Context, keyFunc jwt.Keyfunc) (Report, error) {
+	tx, err := c.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			c.log.Error(ctx, "failed to rollback transaction", sl.Err(err))
+		}
+	}()
+	c.log.Debug(ctx, "executing query", slog.String("query", checkpointsQuery))
+	rows, err := tx.Query(ctx, checkpointsQuery)
+	if err != nil {
+		return Report{}, err
+	}
+	checkpoints, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Checkpoint, error) {
+		var checkpoint Checkpoint
+		err := row.Scan(&checkpoint.Seq, &checkpoint.Hash, &checkpoint.Signature, &checkpoint.CreatedAt)
+		return checkpoint, err
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	verifier := newChainVerifier(keyFunc, checkpoints)
+	c.log.Debug(ctx, "executing query", slog.String("query", sealedEventsQuery))
+	rows, err = tx.Query(ctx, sealedEventsQuery)
+	if err != nil {
+		return Report{}, err
+	}
+	for rows.Next() {
+		var e SealedEvent
+		if err := rows.Scan(
+			&e.Id,
+			&e.Type,
+			&e.Outcome,
+			&e.UserId,
+			&e.DeviceId,
+			&e.IpAddress,
+			&e.UserAgent,
+			&e.Reason,
+			&e.CreatedAt,
+			&e.Seq,
+			&e.Hash,
+		); err != nil {
+			rows.Close()
+			return Report{}, err
+		}
+		if !verifier.next(e) {
+			break
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Report{}, err
+	}
+	report := verifier.finish()
+	c.log.Debug(ctx, "executing query", slog.String("query", unsealedEventsCountQuery))
+	if err := tx.QueryRow(ctx, unsealedEventsCountQuery).Scan(&report.Unsealed); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
@@ -0,0 +1,13 @@
+package audit
+
+import "net/http"
+
+type AdminController interface {
+	Events(w http.ResponseWriter, r *http.Request)
+}
+
+func newAdminRouter(adminController AdminController) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /audit", adminController.Events)
+	return mux
+}
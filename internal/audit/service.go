@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrFailedToSearchEvents = errors.New("failed to search events")
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+type EventsRepository interface {
+	Events(ctx context.Context, filter Filter, limit int) ([]Event, error)
+}
+
+type Page struct {
+	Events []Event
+	// Отсутствует на последней странице
+	Next *Cursor
+}
+
+type service struct {
+	log        *logger.Logger
+	eventsRepo EventsRepository
+}
+
+func newService(log *logger.Logger, eventsRepo EventsRepository) *service {
+	return &service{
+		log:        log,
+		eventsRepo: eventsRepo,
+	}
+}
+
+func (s *service) Search(ctx context.Context, filter Filter, pageSize int) (Page, *shared.DomainError) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		return Page{}, shared.NewDomainError(
+			fmt.Errorf("%w: page size %d exceeds %d", ErrFailedToSearchEvents, pageSize, MaxPageSize),
+			fmt.Sprintf("limit must not exceed %d", MaxPageSize),
+		)
+	}
+	for _, t := range filter.Types {
+		if !t.Valid() {
+			return Page{}, shared.NewDomainError(
+				fmt.Errorf("%w: unknown event type %q", ErrFailedToSearchEvents, t),
+				"unknown event type",
+			)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return Page{}, shared.NewDomainError(
+			fmt.Errorf("%w: empty time range", ErrFailedToSearchEvents),
+			"`from` must be before `to`",
+		)
+	}
+	// Лишнее событие сообщает о наличии следующей страницы
+	events, err := s.eventsRepo.Events(ctx, filter, pageSize+1)
+	if err != nil {
+		return Page{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToSearchEvents, err),
+			"failed to search events",
+		)
+	}
+	page := Page{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		last := page.Events[pageSize-1]
+		page.Next = &Cursor{CreatedAt: last.CreatedAt, Id: last.Id}
+	}
+	return page, nil
+}
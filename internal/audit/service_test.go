@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+)
+
+func newTestService(t *testing.T, setup func(*MockEventsRepository)) *service {
+	var buf bytes.Buffer
+	log := logger.New(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+	eventsRepo := NewMockEventsRepository(t)
+	if setup != nil {
+		setup(eventsRepo)
+	}
+	return newService(log, eventsRepo)
+}
+
+func testEvents(n int) []Event {
+	now := time.Now()
+	events := make([]Event, 0, n)
+	for i := range n {
+		events = append(events, Event{
+			Id:        uuid.New(),
+			Type:      LoginEvent,
+			Outcome:   SuccessOutcome,
+			CreatedAt: now.Add(-time.Duration(i) * time.Second),
+		})
+	}
+	return events
+}
+
+func TestServiceSearch(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   Filter
+		pageSize int
+		events   []Event
+		expected int
+		next     bool
+		err      bool
+	}{
+		{
+			name:     "should use default page size",
+			events:   testEvents(3),
+			expected: 3,
+		},
+		{
+			name:     "should return cursor if there are more events",
+			pageSize: 2,
+			events:   testEvents(3),
+			expected: 2,
+			next:     true,
+		},
+		{
+			name:     "should reject too large page",
+			pageSize: MaxPageSize + 1,
+			err:      true,
+		},
+		{
+			name:   "should reject unknown event type",
+			filter: Filter{Types: []EventType{"unknown"}},
+			err:    true,
+		},
+		{
+			name: "should reject empty time range",
+			filter: Filter{
+				From: time.Now(),
+				To:   time.Now().Add(-time.Hour),
+			},
+			err: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service := newTestService(t, func(m *MockEventsRepository) {
+				if c.err {
+					return
+				}
+				limit := c.pageSize
+				if limit == 0 {
+					limit = DefaultPageSize
+				}
+				m.EXPECT().Events(mock.Anything, c.filter, limit+1).Return(c.events, nil)
+			})
+			page, err := service.Search(context.Background(), c.filter, c.pageSize)
+			if err != nil {
+				if !c.err || !err.Expected || !errors.Is(err.Err, ErrFailedToSearchEvents) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if c.err {
+				t.Fatal("expected error")
+			}
+			if len(page.Events) != c.expected {
+				t.Fatalf("expected %d events, got %d", c.expected, len(page.Events))
+			}
+			if (page.Next != nil) != c.next {
+				t.Fatalf("unexpected next cursor: %v", page.Next)
+			}
+			if page.Next != nil {
+				last := page.Events[len(page.Events)-1]
+				if page.Next.Id != last.Id || !page.Next.CreatedAt.Equal(last.CreatedAt) {
+					t.Fatalf("cursor must point to the last event")
+				}
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Now().UTC(), Id: uuid.New()}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Id != cursor.Id || !decoded.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Fatalf("expected %v, got %v", cursor, decoded)
+	}
+	for _, invalid := range []string{"not base64!", "e30"} {
+		if _, err := DecodeCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("expected invalid cursor error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	userId := uuid.New()
+	filter, pageSize, err := parseFilter(map[string][]string{
+		"userId": {userId.String()},
+		"type":   {"login,refresh", "reuse"},
+		"ip":     {"203.0.113.7"},
+		"from":   {"2024-01-01T00:00:00Z"},
+		"limit":  {"10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.UserId != userId || len(filter.Types) != 3 || pageSize != 10 {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+	if filter.Network.String() != "203.0.113.7/32" {
+		t.Fatalf("unexpected network: %s", filter.Network)
+	}
+	network, _, err := parseFilter(map[string][]string{"ip": {"10.1.2.3/8"}})
+	if err != nil || network.Network.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected network: %s, %v", network.Network, err)
+	}
+	for _, q := range []map[string][]string{
+		{"userId": {"nope"}},
+		{"ip": {"nope"}},
+		{"to": {"yesterday"}},
+		{"limit": {"-1"}},
+		{"cursor": {"nope"}},
+	} {
+		if _, _, err := parseFilter(q); !errors.Is(err, ErrInvalidFilter) {
+			t.Fatalf("expected invalid filter error for %v, got %v", q, err)
+		}
+	}
+}
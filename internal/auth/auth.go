@@ -1,36 +1,167 @@
 package auth
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	pgx_adapter "github.com/x0k/medods-authentication-service/internal/adapters/pgx"
+	"github.com/x0k/medods-authentication-service/internal/audit"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 )
 
+const (
+	unknownKeyReloadInterval = 10 * time.Second
+	keysReloadTimeout        = 5 * time.Second
+)
+
+type Config struct {
+	// Ключ из конфигурации, при его изменении выполняется ротация
+	SigningKey *SigningKey
+	// Ключ шифрования материала ключей подписи в базе данных (32 байта)
+	KeysEncryptionKey []byte
+	// Сколько выведенный из оборота ключ продолжает проверять токены
+	KeyRetention time.Duration
+	// Период перезагрузки ключей, ротированных другими экземплярами
+	KeysReloadInterval time.Duration
+	AccessTTL          time.Duration
+	RefreshTTL         time.Duration
+	// Нулевые значения отключают соответствующие ограничения
+	SessionMaxAge      time.Duration
+	SessionIdleTimeout time.Duration
+	// Учетные данные сервисов для интроспекции токенов (id -> секрет)
+	IntrospectionClients map[string]string
+	// Как определять устройство без `X-Device-Id`: `ip` или `none`
+	DeviceIdFallback string
+	// Правила оценки риска при обновлении пары, по умолчанию `DefaultRiskRules`
+	RiskRules []RiskRule
+	// Необязателен, без него недоступны сигналы `asn_change` и `country_change`
+	GeoLocator GeoLocator
+	// Подтверждение обновления пары одноразовым кодом
+	ChallengeTTL         time.Duration
+	ChallengeMaxAttempts int
+	// Публичный адрес сервиса для ссылок в сообщениях, без него
+	// предупреждения не содержат ссылку "это был не я"
+	PublicUrl     string
+	ReportLinkTTL time.Duration
+	// Ограничения частоты запросов `/login` и `/refresh`
+	RateLimits RateLimits
+	// Необязателен, по умолчанию счетчики хранятся в памяти
+	RateLimitStore ratelimit.Store
+}
+
+type Module struct {
+	Router      *http.ServeMux
+	AdminRouter *http.ServeMux
+	Keyring     *Keyring
+	// Middleware для маршрутов других модулей, доступных по Access токену
+	RequireUser func(http.Handler) http.Handler
+	// Периодически перезагружает ключи, ротированные другими экземплярами
+	ReloadKeys func(ctx context.Context)
+}
+
 func New(
+	ctx context.Context,
 	log *logger.Logger,
 	pgxPool *pgxpool.Pool,
-	secret []byte,
+	cfg *Config,
 	usersRepo UsersRepository,
 	sender MessagesSender,
-) *http.ServeMux {
+	auditLog audit.AuditLog[pgx.Tx],
+	outbox outbox.Outbox[pgx.Tx],
+) (*Module, error) {
+	uowFactory := pgx_adapter.NewUnitOfWorkFactory(pgxPool)
+	keysCipher, err := NewKeysCipher(cfg.KeysEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	keyring := NewKeyring(time.Now)
+	keysService := newKeysService(
+		log.With(slog.String("component", "keys_service")),
+		keyring,
+		newSigningKeysRepository(
+			log.With(slog.String("component", "signing_keys_repository")),
+			pgxPool,
+		),
+		keysCipher,
+		uowFactory,
+		cfg.SigningKey.Algorithm(),
+		cfg.KeyRetention,
+		time.Now,
+	)
+	if err := keysService.Load(ctx, cfg.SigningKey); err != nil {
+		return nil, err
+	}
+	keyring.ReloadOnUnknownKey(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), keysReloadTimeout)
+		defer cancel()
+		if err := keysService.Reload(ctx); err != nil {
+			log.Error(ctx, "failed to reload signing keys", sl.Err(err))
+			return err
+		}
+		return nil
+	}, unknownKeyReloadInterval)
+	deviceIdentifier, err := newDeviceIdentifier(cfg.DeviceIdFallback)
+	if err != nil {
+		return nil, err
+	}
+	riskPolicy, err := newRiskPolicy(cfg.RiskRules, cfg.GeoLocator)
+	if err != nil {
+		return nil, err
+	}
 	refreshTokensRepository := newRefreshTokensRepository(
 		log.With(slog.String("component", "refresh_tokens_repository")),
 		pgxPool,
 	)
 	service := newService(
 		log.With(slog.String("component", "service")),
-		secret,
+		cfg,
+		keyring,
 		usersRepo,
 		refreshTokensRepository,
+		newChallengesRepository(
+			log.With(slog.String("component", "challenges_repository")),
+		),
+		newReportTokensRepository(
+			log.With(slog.String("component", "report_tokens_repository")),
+		),
+		auditLog,
+		outbox,
 		sender,
-		pgx_adapter.NewUnitOfWorkFactory(pgxPool),
+		uowFactory,
+		deviceIdentifier,
+		riskPolicy,
+		time.Now,
 	)
 	controller := newController(
 		log.With(slog.String("component", "controller")),
 		service,
+		keyring,
+		keysService,
+	)
+	rateLimitStore := cfg.RateLimitStore
+	if rateLimitStore == nil {
+		rateLimitStore = ratelimit.NewInMemoryStore(time.Now)
+	}
+	limiter := newRateLimiter(
+		log.With(slog.String("component", "rate_limiter")),
+		rateLimitStore,
+		cfg.RateLimits,
+		keyring,
 	)
-	return newRouter(controller)
+	return &Module{
+		Router:      newRouter(controller, cfg.IntrospectionClients, limiter),
+		AdminRouter: newAdminRouter(controller),
+		Keyring:     keyring,
+		RequireUser: controller.RequireUser,
+		ReloadKeys: func(ctx context.Context) {
+			keysService.Run(ctx, cfg.KeysReloadInterval)
+		},
+	}, nil
 }
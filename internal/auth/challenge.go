@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrFailedToVerifyRefresh = errors.New("failed to verify refresh")
+var ErrChallengeExpired = errors.New("challenge expired")
+var ErrTooManyAttempts = errors.New("too many attempts")
+var ErrInvalidVerificationCode = errors.New("invalid verification code")
+
+const verificationCodeDigits = 6
+
+// Подтверждение обновления пары одноразовым кодом.
+// У пары не больше одного подтверждения.
+type Challenge struct {
+	Id     uuid.UUID
+	UserId uuid.UUID
+	// Подтверждение действует только для пары, обновление которой его потребовало
+	AccessTokenHash []byte
+	CodeHash        []byte
+	// Попытки считаются для пары и сохраняются при замене подтверждения
+	Attempts  int
+	ExpiresAt time.Time
+	// Запись хранится, пока пара может быть обновлена
+	PairExpiresAt time.Time
+}
+
+type ChallengesRepository[T any] interface {
+	// Заменяет подтверждение пары, если оно уже есть
+	CreateChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], challenge Challenge) error
+	// Блокирует запись до конца транзакции
+	Challenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) (Challenge, error)
+	// Возвращает подтверждение пары, блокируя запись до конца транзакции
+	PairChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], accessTokenHash []byte) (Challenge, error)
+	IncrementChallengeAttempts(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) error
+	DeleteChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) error
+}
+
+// Возвращается из `Refresh` вместо новой пары, если требуется подтверждение
+type ChallengeRequiredError struct {
+	Id        uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (e *ChallengeRequiredError) Error() string {
+	return fmt.Sprintf("challenge %s required", e.Id)
+}
+
+// Пока подтверждение пары действует, возвращается оно же без нового кода.
+// Пара с исчерпанными попытками не может быть подтверждена (`ErrTooManyAttempts`).
+func (s *service[T]) createChallenge(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[T],
+	userId uuid.UUID,
+	accessTokenHash []byte,
+	pairExpiresAt time.Time,
+) (*ChallengeRequiredError, string, error) {
+	existing, err := s.challengesRepo.PairChallenge(ctx, uow, accessTokenHash)
+	if err != nil && !errors.Is(err, shared.ErrNotFound) {
+		return nil, "", fmt.Errorf("get challenge: %w", err)
+	}
+	if err == nil {
+		if existing.Attempts >= s.cfg.ChallengeMaxAttempts {
+			return nil, "", ErrTooManyAttempts
+		}
+		if s.now().Before(existing.ExpiresAt) {
+			return &ChallengeRequiredError{
+				Id:        existing.Id,
+				ExpiresAt: existing.ExpiresAt,
+			}, "", nil
+		}
+	}
+	code, err := generateVerificationCode()
+	if err != nil {
+		return nil, "", fmt.Errorf("generate code: %w", err)
+	}
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", fmt.Errorf("hash code: %w", err)
+	}
+	challenge := Challenge{
+		Id:              uuid.New(),
+		UserId:          userId,
+		AccessTokenHash: accessTokenHash,
+		CodeHash:        codeHash,
+		ExpiresAt:       s.now().Add(s.cfg.ChallengeTTL),
+		PairExpiresAt:   pairExpiresAt,
+	}
+	if err := s.challengesRepo.CreateChallenge(ctx, uow, challenge); err != nil {
+		return nil, "", fmt.Errorf("save challenge: %w", err)
+	}
+	return &ChallengeRequiredError{
+		Id:        challenge.Id,
+		ExpiresAt: challenge.ExpiresAt,
+	}, code, nil
+}
+
+// Проверяет код и завершает обновление пары, потребовавшее подтверждения.
+// Неудачные попытки сохраняются, после исчерпания лимита пару нельзя подтвердить.
+func (s *service[T]) VerifyRefresh(
+	ctx context.Context,
+	challengeId uuid.UUID,
+	code string,
+	accessToken string,
+	refreshToken string,
+	client Client,
+) (Tokens, *shared.DomainError) {
+	if err := s.verifyChallenge(ctx, challengeId, code, accessToken, client); err != nil {
+		return Tokens{}, err
+	}
+	return s.refresh(ctx, accessToken, refreshToken, client, true)
+}
+
+func (s *service[T]) verifyChallenge(
+	ctx context.Context,
+	challengeId uuid.UUID,
+	code string,
+	accessToken string,
+	client Client,
+) *shared.DomainError {
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	challenge, err := s.challengesRepo.Challenge(ctx, uow, challengeId)
+	if errors.Is(err, shared.ErrNotFound) {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToVerifyRefresh, err),
+			"invalid challenge",
+		)
+	}
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: get challenge: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	accessTokenHash := sha256.Sum256([]byte(accessToken))
+	if !bytes.Equal(challenge.AccessTokenHash, accessTokenHash[:]) {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: tokens mismatch", ErrFailedToVerifyRefresh),
+			"invalid challenge",
+		)
+	}
+	var reason error
+	if !s.now().Before(challenge.ExpiresAt) {
+		reason = ErrChallengeExpired
+	} else if challenge.Attempts >= s.cfg.ChallengeMaxAttempts {
+		reason = ErrTooManyAttempts
+	}
+	// Запись сохраняется, чтобы новое подтверждение пары не сбросило счетчик попыток
+	if reason != nil {
+		if err := s.recordChallengeFailure(ctx, uow, challenge.UserId, client, reason); err != nil {
+			return err
+		}
+		if err := uow.Commit(ctx); err != nil {
+			return shared.NewUnexpectedError(
+				fmt.Errorf("%w: commit unit of work: %s", ErrFailedToVerifyRefresh, err),
+				"failed to verify code",
+			)
+		}
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToVerifyRefresh, reason),
+			reason.Error(),
+		)
+	}
+	err = bcrypt.CompareHashAndPassword(challenge.CodeHash, []byte(code))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		s.log.Warn(
+			ctx,
+			"invalid verification code",
+			slog.String("user_id", challenge.UserId.String()),
+			slog.String("challenge_id", challengeId.String()),
+		)
+		if err := s.challengesRepo.IncrementChallengeAttempts(ctx, uow, challengeId); err != nil {
+			return shared.NewUnexpectedError(
+				fmt.Errorf("%w: increment attempts: %s", ErrFailedToVerifyRefresh, err),
+				"failed to verify code",
+			)
+		}
+		if err := s.recordChallengeFailure(ctx, uow, challenge.UserId, client, ErrInvalidVerificationCode); err != nil {
+			return err
+		}
+		if err := uow.Commit(ctx); err != nil {
+			return shared.NewUnexpectedError(
+				fmt.Errorf("%w: commit unit of work: %s", ErrFailedToVerifyRefresh, err),
+				"failed to verify code",
+			)
+		}
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToVerifyRefresh, ErrInvalidVerificationCode),
+			"invalid verification code",
+		)
+	}
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: compare code: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	if err := s.challengesRepo.DeleteChallenge(ctx, uow, challengeId); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: delete challenge: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	return nil
+}
+
+func (s *service[T]) recordChallengeFailure(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[T],
+	userId uuid.UUID,
+	client Client,
+	reason error,
+) *shared.DomainError {
+	if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+		audit.RefreshEvent, audit.FailureOutcome, userId, nil, client, reason.Error(),
+	)); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToVerifyRefresh, err),
+			"failed to verify code",
+		)
+	}
+	return nil
+}
+
+// Код не сохраняется в очереди сообщений в открытом виде,
+// при ошибке доставки пользователь повторяет обновление
+func (s *service[T]) sendVerificationCode(ctx context.Context, userId uuid.UUID, code string) {
+	if err := s.sender.SendVerificationCode(ctx, userId, code); err != nil {
+		s.log.Error(
+			ctx,
+			"failed to send verification code",
+			slog.String("user_id", userId.String()),
+			sl.Err(err),
+		)
+	}
+}
+
+func generateVerificationCode() (string, error) {
+	limit := big.NewInt(1)
+	for range verificationCodeDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type challengesRepository struct {
+	log *logger.Logger
+}
+
+func newChallengesRepository(log *logger.Logger) *challengesRepository {
+	return &challengesRepository{
+		log: log,
+	}
+}
+
+const purgeExpiredChallengesQuery = `DELETE FROM refresh_challenge WHERE pair_expires_at < now()`
+
+// Замена подтверждения сохраняет счетчик попыток пары
+const createChallengeQuery = `INSERT INTO refresh_challenge
+(id, user_id, access_token_hash, code_hash, expires_at, pair_expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (access_token_hash) DO UPDATE SET
+	id = EXCLUDED.id,
+	code_hash = EXCLUDED.code_hash,
+	expires_at = EXCLUDED.expires_at`
+
+func (r *challengesRepository) CreateChallenge(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	challenge Challenge,
+) error {
+	r.log.Debug(ctx, "executing query", slog.String("query", purgeExpiredChallengesQuery))
+	if _, err := uow.Tx().Exec(ctx, purgeExpiredChallengesQuery); err != nil {
+		return err
+	}
+	args := []any{
+		challenge.Id,
+		challenge.UserId,
+		challenge.AccessTokenHash,
+		challenge.CodeHash,
+		challenge.ExpiresAt,
+		challenge.PairExpiresAt,
+	}
+	r.log.Debug(ctx, "executing query", slog.String("query", createChallengeQuery), slog.Any("args", args[:2]))
+	_, err := uow.Tx().Exec(ctx, createChallengeQuery, args...)
+	return err
+}
+
+const challengeQuery = `SELECT user_id, access_token_hash, code_hash, attempts, expires_at, pair_expires_at
+FROM refresh_challenge WHERE id = $1
+FOR UPDATE`
+
+func (r *challengesRepository) Challenge(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	id uuid.UUID,
+) (Challenge, error) {
+	args := []any{id}
+	r.log.Debug(ctx, "executing query", slog.String("query", challengeQuery), slog.Any("args", args))
+	challenge := Challenge{Id: id}
+	err := uow.Tx().QueryRow(ctx, challengeQuery, args...).Scan(
+		&challenge.UserId,
+		&challenge.AccessTokenHash,
+		&challenge.CodeHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.PairExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Challenge{}, shared.ErrNotFound
+	}
+	return challenge, err
+}
+
+const pairChallengeQuery = `SELECT id, user_id, code_hash, attempts, expires_at, pair_expires_at
+FROM refresh_challenge WHERE access_token_hash = $1
+FOR UPDATE`
+
+func (r *challengesRepository) PairChallenge(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	accessTokenHash []byte,
+) (Challenge, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", pairChallengeQuery))
+	challenge := Challenge{AccessTokenHash: accessTokenHash}
+	err := uow.Tx().QueryRow(ctx, pairChallengeQuery, accessTokenHash).Scan(
+		&challenge.Id,
+		&challenge.UserId,
+		&challenge.CodeHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.PairExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Challenge{}, shared.ErrNotFound
+	}
+	return challenge, err
+}
+
+const incrementChallengeAttemptsQuery = `UPDATE refresh_challenge SET attempts = attempts + 1 WHERE id = $1`
+
+func (r *challengesRepository) IncrementChallengeAttempts(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	id uuid.UUID,
+) error {
+	args := []any{id}
+	r.log.Debug(ctx, "executing query", slog.String("query", incrementChallengeAttemptsQuery), slog.Any("args", args))
+	_, err := uow.Tx().Exec(ctx, incrementChallengeAttemptsQuery, args...)
+	return err
+}
+
+const deleteChallengeQuery = `DELETE FROM refresh_challenge WHERE id = $1`
+
+func (r *challengesRepository) DeleteChallenge(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	id uuid.UUID,
+) error {
+	args := []any{id}
+	r.log.Debug(ctx, "executing query", slog.String("query", deleteChallengeQuery), slog.Any("args", args))
+	_, err := uow.Tx().Exec(ctx, deleteChallengeQuery, args...)
+	return err
+}
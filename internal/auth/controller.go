@@ -5,46 +5,103 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
 	"github.com/x0k/medods-authentication-service/internal/lib/httpx"
+	"github.com/x0k/medods-authentication-service/internal/lib/jwk"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
 	"github.com/x0k/medods-authentication-service/internal/shared"
 )
 
 var ErrInvalidGUID = errors.New("invalid GUID")
+var ErrInvalidToken = errors.New("invalid token")
 
 type AuthService interface {
-	IssueTokens(ctx context.Context, userId uuid.UUID, ipAddress string) (string, string, *shared.DomainError)
-	Refresh(ctx context.Context, accessToken string, refreshToken string, ipAddress string) (string, string, *shared.DomainError)
+	IssueTokens(ctx context.Context, userId uuid.UUID, client Client) (Tokens, *shared.DomainError)
+	Refresh(ctx context.Context, accessToken string, refreshToken string, client Client) (Tokens, *shared.DomainError)
+	VerifyRefresh(
+		ctx context.Context,
+		challengeId uuid.UUID,
+		code string,
+		accessToken string,
+		refreshToken string,
+		client Client,
+	) (Tokens, *shared.DomainError)
+	Revoke(ctx context.Context, token string, tokenTypeHint string, client Client) *shared.DomainError
+	Introspect(ctx context.Context, token string) (Introspection, *shared.DomainError)
+	Authenticate(ctx context.Context, accessToken string) (Principal, *shared.DomainError)
+	RevokeSessions(ctx context.Context, userId uuid.UUID, client Client) *shared.DomainError
+	Sessions(ctx context.Context, principal Principal) ([]Session, *shared.DomainError)
+	RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID, client Client) *shared.DomainError
+	ReportLink(ctx context.Context, token string) (ReportLink, *shared.DomainError)
+	Report(ctx context.Context, token string, scope ReportScope, client Client) *shared.DomainError
+}
+
+type KeySet interface {
+	JWKS() (jwk.Set, error)
+}
+
+type KeysService interface {
+	Rotate(ctx context.Context) (*SigningKey, *shared.DomainError)
 }
 
 type controller struct {
 	log         *logger.Logger
 	authService AuthService
+	keySet      KeySet
+	keysService KeysService
 	decoder     *httpx.JsonBodyDecoder
 }
 
 func newController(
 	log *logger.Logger,
 	authService AuthService,
+	keySet KeySet,
+	keysService KeysService,
 ) *controller {
 	return &controller{
 		log:         log,
 		authService: authService,
+		keySet:      keySet,
+		keysService: keysService,
 		decoder: &httpx.JsonBodyDecoder{
-			MaxBytes:              1024,
+			MaxBytes:              maxJsonBodySize,
 			DisallowUnknownFields: true,
 		},
 	}
 }
 
+type tokensPairDTO struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
 type tokensDTO struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	// Время жизни токенов в секундах
+	ExpiresIn        int64 `json:"expiresIn"`
+	RefreshExpiresIn int64 `json:"refreshExpiresIn"`
+}
+
+func newTokensDTO(tokens Tokens) tokensDTO {
+	return tokensDTO{
+		AccessToken:      tokens.AccessToken,
+		RefreshToken:     tokens.RefreshToken,
+		ExpiresIn:        int64(tokens.ExpiresIn.Seconds()),
+		RefreshExpiresIn: int64(tokens.RefreshExpiresIn.Seconds()),
+	}
+}
+
+type loginDTO struct {
+	DeviceId string `json:"deviceId"`
 }
 
 // Первый маршрут выдает пару Access, Refresh токенов для пользователя
@@ -61,32 +118,373 @@ func (c *controller) Login(w http.ResponseWriter, r *http.Request) {
 		c.badRequest(w, r, err, "failed to parse GUID")
 		return
 	}
-	accessToken, refreshToken, dErr := c.authService.IssueTokens(r.Context(), userId, r.RemoteAddr)
+	client := c.client(r)
+	// Тело запроса необязательно
+	if r.ContentLength != 0 {
+		login, httpErr := httpx.JSONBody[loginDTO](c.decoder, w, r)
+		if httpErr != nil {
+			http.Error(w, httpErr.Text, httpErr.Status)
+			c.log.Debug(r.Context(), "failed to decode JSON", sl.Err(httpErr))
+			return
+		}
+		if client.DeviceId == "" {
+			client.DeviceId = login.DeviceId
+		}
+	}
+	tokens, dErr := c.authService.IssueTokens(r.Context(), userId, client)
 	if dErr != nil {
 		c.domainError(w, r, dErr)
 		return
 	}
-	c.json(w, r, tokensDTO{accessToken, refreshToken}, http.StatusOK)
+	c.json(w, r, newTokensDTO(tokens), http.StatusOK)
 }
 
 func (c *controller) Refresh(w http.ResponseWriter, r *http.Request) {
-	tokens, httpErr := httpx.JSONBody[tokensDTO](c.decoder, w, r)
+	pair, httpErr := httpx.JSONBody[tokensPairDTO](c.decoder, w, r)
+	if httpErr != nil {
+		http.Error(w, httpErr.Text, httpErr.Status)
+		c.log.Debug(r.Context(), "failed to decode JSON", sl.Err(httpErr))
+		return
+	}
+	tokens, err := c.authService.Refresh(
+		r.Context(),
+		pair.AccessToken,
+		pair.RefreshToken,
+		c.client(r),
+	)
+	if err != nil {
+		var challenge *ChallengeRequiredError
+		if errors.As(err.Err, &challenge) {
+			w.Header().Set("WWW-Authenticate", `OTP realm="refresh"`)
+			c.json(w, r, challengeDTO{
+				ChallengeId: challenge.Id.String(),
+				ExpiresIn:   int64(time.Until(challenge.ExpiresAt).Seconds()),
+			}, http.StatusUnauthorized)
+			return
+		}
+		c.domainError(w, r, err)
+		return
+	}
+	c.json(w, r, newTokensDTO(tokens), http.StatusOK)
+}
+
+type challengeDTO struct {
+	ChallengeId string `json:"challengeId"`
+	// Время жизни подтверждения в секундах
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type verifyRefreshDTO struct {
+	ChallengeId  string `json:"challengeId"`
+	Code         string `json:"code"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Завершает обновление пары, потребовавшее подтверждения кодом из письма
+func (c *controller) VerifyRefresh(w http.ResponseWriter, r *http.Request) {
+	body, httpErr := httpx.JSONBody[verifyRefreshDTO](c.decoder, w, r)
 	if httpErr != nil {
 		http.Error(w, httpErr.Text, httpErr.Status)
 		c.log.Debug(r.Context(), "failed to decode JSON", sl.Err(httpErr))
 		return
 	}
-	accessToken, refreshToken, err := c.authService.Refresh(
+	challengeId, err := uuid.Parse(body.ChallengeId)
+	if err != nil {
+		c.badRequest(w, r, err, "failed to parse challenge id")
+		return
+	}
+	tokens, dErr := c.authService.VerifyRefresh(
 		r.Context(),
-		tokens.AccessToken,
-		tokens.RefreshToken,
-		r.RemoteAddr,
+		challengeId,
+		body.Code,
+		body.AccessToken,
+		body.RefreshToken,
+		c.client(r),
 	)
+	if dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	c.json(w, r, newTokensDTO(tokens), http.StatusOK)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7009#section-2.1
+func (c *controller) Revoke(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 8*1024)
+	if err := r.ParseForm(); err != nil {
+		c.badRequest(w, r, err, "failed to parse form")
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		c.badRequest(w, r, ErrInvalidToken, "token is required")
+		return
+	}
+	if err := c.authService.Revoke(r.Context(), token, r.PostForm.Get("token_type_hint"), c.client(r)); err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type introspectionDTO struct {
+	Active bool `json:"active"`
+	// Расширение: отличает отозванный токен от истекшего или невалидного
+	Revoked   bool   `json:"revoked,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Ip        string `json:"ip,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+}
+
+func newIntrospectionDTO(i Introspection) introspectionDTO {
+	if !i.Active {
+		return introspectionDTO{Revoked: i.Revoked}
+	}
+	dto := introspectionDTO{
+		Active:    true,
+		TokenType: "Bearer",
+		Sub:       i.UserId.String(),
+		Ip:        i.IpAddress,
+		Jti:       i.TokenId.String(),
+		Exp:       i.ExpiresAt.Unix(),
+	}
+	if !i.IssuedAt.IsZero() {
+		dto.Iat = i.IssuedAt.Unix()
+	}
+	return dto
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7662#section-2.1
+// Клиент аутентифицируется до вызова обработчика
+func (c *controller) Introspect(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 8*1024)
+	if err := r.ParseForm(); err != nil {
+		c.badRequest(w, r, err, "failed to parse form")
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		c.badRequest(w, r, ErrInvalidToken, "token is required")
+		return
+	}
+	introspection, err := c.authService.Introspect(r.Context(), token)
 	if err != nil {
 		c.domainError(w, r, err)
 		return
 	}
-	c.json(w, r, tokensDTO{accessToken, refreshToken}, http.StatusOK)
+	w.Header().Set("Cache-Control", "no-store")
+	c.json(w, r, newIntrospectionDTO(introspection), http.StatusOK)
+}
+
+// Выход на всех устройствах текущего пользователя
+func (c *controller) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if err := c.authService.RevokeSessions(r.Context(), principal.UserId, c.client(r)); err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sessionDTO struct {
+	Id              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	FirstSeenAt     time.Time `json:"firstSeenAt"`
+	LastRefreshedAt time.Time `json:"lastRefreshedAt"`
+	LastIp          string    `json:"lastIp"`
+	UserAgent       string    `json:"userAgent"`
+	RefreshCount    int64     `json:"refreshCount"`
+	Current         bool      `json:"current"`
+}
+
+func newSessionsDTO(sessions []Session) []sessionDTO {
+	dto := make([]sessionDTO, 0, len(sessions))
+	for _, s := range sessions {
+		dto = append(dto, sessionDTO{
+			Id:              s.Id.String(),
+			CreatedAt:       s.CreatedAt,
+			FirstSeenAt:     s.FirstSeenAt,
+			LastRefreshedAt: s.LastRefreshedAt,
+			LastIp:          s.LastIpAddress,
+			UserAgent:       s.UserAgent,
+			RefreshCount:    s.RefreshCount,
+			Current:         s.Current,
+		})
+	}
+	return dto
+}
+
+func (c *controller) Sessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := c.authService.Sessions(r.Context(), principal)
+	if err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	c.json(w, r, newSessionsDTO(sessions), http.StatusOK)
+}
+
+func (c *controller) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
+	sessionId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		c.badRequest(w, r, err, "failed to parse session id")
+		return
+	}
+	if err := c.authService.RevokeSession(r.Context(), principal.UserId, sessionId, c.client(r)); err != nil {
+		if errors.Is(err.Err, shared.ErrNotFound) {
+			http.Error(w, err.Msg, http.StatusNotFound)
+			return
+		}
+		c.domainError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Для службы поддержки: устройства пользователя с метаданными
+func (c *controller) UserSessions(w http.ResponseWriter, r *http.Request) {
+	userId, err := c.parseGUID(r.PathValue("id"))
+	if err != nil {
+		c.badRequest(w, r, err, "failed to parse user id")
+		return
+	}
+	sessions, dErr := c.authService.Sessions(r.Context(), Principal{UserId: userId})
+	if dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	c.json(w, r, newSessionsDTO(sessions), http.StatusOK)
+}
+
+func (c *controller) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userId, err := c.parseGUID(r.PathValue("id"))
+	if err != nil {
+		c.badRequest(w, r, err, "failed to parse user id")
+		return
+	}
+	if err := c.authService.RevokeSessions(r.Context(), userId, c.client(r)); err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Публичные ключи для проверки Access токенов сторонними сервисами
+func (c *controller) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := c.keySet.JWKS()
+	if err != nil {
+		c.serverError(w, r, err, "failed to get public keys")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	c.json(w, r, set, http.StatusOK)
+}
+
+type signingKeyDTO struct {
+	Id        string `json:"kid"`
+	Algorithm string `json:"alg"`
+}
+
+func (c *controller) RotateKeys(w http.ResponseWriter, r *http.Request) {
+	key, err := c.keysService.Rotate(r.Context())
+	if err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	c.json(w, r, signingKeyDTO{key.Id(), key.Algorithm()}, http.StatusOK)
+}
+
+var securityReportPage = template.Must(template.New("security_report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Secure your account</title></head>
+<body>
+{{- if .Done}}
+  <p>Done. {{if .All}}All sessions were signed out.{{else}}The session was signed out.{{end}}</p>
+{{- else}}
+  <p>If you don't recognize this activity, sign out of the affected session or of all sessions.</p>
+  <form method="post">
+    <input type="hidden" name="token" value="{{.Token}}">
+    {{- if .Device}}
+    <button type="submit" name="scope" value="device">Sign out this session</button>
+    {{- end}}
+    <button type="submit" name="scope" value="all">Sign out everywhere</button>
+  </form>
+{{- end}}
+</body>
+</html>
+`))
+
+type securityReportPageData struct {
+	Token  string
+	Device bool
+	Done   bool
+	All    bool
+}
+
+// Переход по ссылке только показывает подтверждение, т.к. почтовые сервисы
+// могут открывать ссылки из писем автоматически
+func (c *controller) SecurityReport(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	link, dErr := c.authService.ReportLink(r.Context(), token)
+	if dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	c.securityReportPage(w, r, securityReportPageData{
+		Token:  token,
+		Device: link.FamilyId != uuid.Nil,
+	})
+}
+
+func (c *controller) SubmitSecurityReport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := r.ParseForm(); err != nil {
+		c.badRequest(w, r, err, "failed to parse form")
+		return
+	}
+	scope := ReportScope(r.Form.Get("scope"))
+	switch scope {
+	case "":
+		scope = ReportDeviceScope
+	case ReportDeviceScope, ReportAllScope:
+	default:
+		c.badRequest(w, r, fmt.Errorf("unknown scope %q", scope), "scope must be `device` or `all`")
+		return
+	}
+	if dErr := c.authService.Report(r.Context(), r.Form.Get("token"), scope, c.client(r)); dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	c.securityReportPage(w, r, securityReportPageData{
+		Done: true,
+		All:  scope == ReportAllScope,
+	})
+}
+
+func (c *controller) securityReportPage(w http.ResponseWriter, r *http.Request, data securityReportPageData) {
+	// Токен передается в адресе страницы
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; form-action 'self'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := securityReportPage.Execute(w, data); err != nil {
+		c.log.Error(r.Context(), "failed to render security report page", sl.Err(err))
+	}
 }
 
 func (c *controller) parseGUID(guid string) (uuid.UUID, error) {
@@ -100,6 +498,59 @@ func (c *controller) parseGUID(guid string) (uuid.UUID, error) {
 	return id, nil
 }
 
+func (c *controller) client(r *http.Request) Client {
+	return Client{
+		IpAddress: http_adapters.ClientIpAddress(r),
+		UserAgent: r.UserAgent(),
+		DeviceId:  r.Header.Get(DeviceIdHeader),
+		Locale:    preferredLocale(r.Header.Get("Accept-Language")),
+	}
+}
+
+// Первый язык из `Accept-Language`, веса не учитываются
+func preferredLocale(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	locale, _, _ := strings.Cut(first, ";")
+	locale = strings.TrimSpace(locale)
+	if locale == "*" {
+		return ""
+	}
+	return locale
+}
+
+// Пропускает запросы с действительным Access токеном, идентификатор
+// пользователя доступен через `http_adapters.UserId`, время входа -
+// через `http_adapters.AuthTime`
+func (c *controller) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := c.authenticate(w, r)
+		if !ok {
+			return
+		}
+		ctx := http_adapters.WithUserId(r.Context(), principal.UserId)
+		ctx = http_adapters.WithAuthTime(ctx, principal.AuthTime)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (c *controller) authenticate(w http.ResponseWriter, r *http.Request) (Principal, bool) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		c.unauthorized(w, r, ErrInvalidToken, "access token is required")
+		return Principal{}, false
+	}
+	principal, err := c.authService.Authenticate(r.Context(), accessToken)
+	if err != nil {
+		if err.Expected {
+			c.unauthorized(w, r, err.Err, err.Msg)
+		} else {
+			c.serverError(w, r, err.Err, err.Msg)
+		}
+		return Principal{}, false
+	}
+	return principal, true
+}
+
 func (c *controller) domainError(w http.ResponseWriter, r *http.Request, err *shared.DomainError) {
 	if err.Expected {
 		c.badRequest(w, r, err.Err, err.Msg)
@@ -113,6 +564,12 @@ func (c *controller) badRequest(w http.ResponseWriter, r *http.Request, err erro
 	c.log.Debug(r.Context(), msg, sl.Err(err))
 }
 
+func (c *controller) unauthorized(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, msg, http.StatusUnauthorized)
+	c.log.Debug(r.Context(), msg, sl.Err(err))
+}
+
 func (c *controller) serverError(w http.ResponseWriter, r *http.Request, err error, msg string) {
 	http.Error(w, msg, http.StatusInternalServerError)
 	c.log.Error(r.Context(), msg, sl.Err(err))
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const DeviceIdHeader = "X-Device-Id"
+
+const (
+	// Без идентификатора от клиента устройство определяется по ip-адресу
+	IpDeviceIdFallback = "ip"
+	// Идентификатор устройства обязателен
+	NoDeviceIdFallback = "none"
+)
+
+const (
+	minDeviceIdLength = 16
+	maxDeviceIdLength = 128
+)
+
+var ErrInvalidDeviceId = errors.New("invalid device id")
+var ErrDeviceIdRequired = errors.New("device id required")
+var ErrUnknownDeviceIdFallback = errors.New("unknown device id fallback")
+
+type DeviceIdentifier interface {
+	// Второе значение сообщает, что идентификатор предоставлен клиентом
+	// и должен быть привязан к токенам
+	Identify(client Client) (DeviceId, bool, error)
+}
+
+type ipDeviceIdentifier struct{}
+
+func (ipDeviceIdentifier) Identify(client Client) (DeviceId, bool, error) {
+	return sha256.Sum256([]byte(client.IpAddress)), false, nil
+}
+
+type clientDeviceIdentifier struct {
+	fallback DeviceIdentifier
+}
+
+func (d clientDeviceIdentifier) Identify(client Client) (DeviceId, bool, error) {
+	if client.DeviceId == "" {
+		if d.fallback == nil {
+			return DeviceId{}, false, ErrDeviceIdRequired
+		}
+		return d.fallback.Identify(client)
+	}
+	if err := validateDeviceId(client.DeviceId); err != nil {
+		return DeviceId{}, false, err
+	}
+	// Префикс исключает совпадение с идентификаторами из ip-адресов
+	return sha256.Sum256([]byte("device:" + client.DeviceId)), true, nil
+}
+
+func newDeviceIdentifier(fallback string) (DeviceIdentifier, error) {
+	switch fallback {
+	case "", IpDeviceIdFallback:
+		return clientDeviceIdentifier{fallback: ipDeviceIdentifier{}}, nil
+	case NoDeviceIdFallback:
+		return clientDeviceIdentifier{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDeviceIdFallback, fallback)
+	}
+}
+
+// Допускаются UUID и другие случайные строки из печатных символов
+func validateDeviceId(deviceId string) error {
+	if len(deviceId) < minDeviceIdLength || len(deviceId) > maxDeviceIdLength {
+		return fmt.Errorf(
+			"%w: length must be between %d and %d",
+			ErrInvalidDeviceId, minDeviceIdLength, maxDeviceIdLength,
+		)
+	}
+	for _, c := range deviceId {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == ':') {
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidDeviceId, c)
+		}
+	}
+	return nil
+}
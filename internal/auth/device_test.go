@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+func TestDeviceIdentifier(t *testing.T) {
+	ipFallback, err := newDeviceIdentifier(IpDeviceIdFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noFallback, err := newDeviceIdentifier(NoDeviceIdFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipAddress := "127.0.0.1"
+	ipDeviceId := sha256.Sum256([]byte(ipAddress))
+
+	cases := []struct {
+		name       string
+		identifier DeviceIdentifier
+		client     Client
+		bound      bool
+		deviceId   *DeviceId
+		err        error
+	}{
+		{
+			name:       "should fall back to ip address",
+			identifier: ipFallback,
+			client:     Client{IpAddress: ipAddress},
+			deviceId:   &ipDeviceId,
+		},
+		{
+			name:       "should use client device id",
+			identifier: ipFallback,
+			client:     Client{IpAddress: ipAddress, DeviceId: "9f1c2f0e-6a0b-4a53-a1a4-0d5e9a1b2c3d"},
+			bound:      true,
+		},
+		{
+			name:       "should require device id without fallback",
+			identifier: noFallback,
+			client:     Client{IpAddress: ipAddress},
+			err:        ErrDeviceIdRequired,
+		},
+		{
+			name:       "should reject short device id",
+			identifier: ipFallback,
+			client:     Client{IpAddress: ipAddress, DeviceId: "short"},
+			err:        ErrInvalidDeviceId,
+		},
+		{
+			name:       "should reject device id with unexpected characters",
+			identifier: ipFallback,
+			client:     Client{IpAddress: ipAddress, DeviceId: "device id with spaces"},
+			err:        ErrInvalidDeviceId,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			deviceId, bound, err := c.identifier.Identify(c.client)
+			if err != nil {
+				if c.err == nil || !errors.Is(err, c.err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if c.err != nil {
+				t.Fatalf("expected error %v", c.err)
+			}
+			if bound != c.bound {
+				t.Errorf("expected bound %v, got %v", c.bound, bound)
+			}
+			if c.deviceId != nil && deviceId != *c.deviceId {
+				t.Errorf("unexpected device id %x", deviceId)
+			}
+		})
+	}
+
+	if _, err := newDeviceIdentifier("unknown"); !errors.Is(err, ErrUnknownDeviceIdFallback) {
+		t.Errorf("expected unknown fallback error, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Определяет местоположение по базам MaxMind GeoLite2/GeoIP2:
+// страну по `Country` или `City`, автономную систему по `ASN`.
+// Любая из баз может отсутствовать, тогда соответствующее поле остается пустым.
+type MaxMindGeoLocator struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+func OpenMaxMindGeoLocator(countryDbPath string, asnDbPath string) (*MaxMindGeoLocator, error) {
+	if countryDbPath == "" && asnDbPath == "" {
+		return nil, errors.New("at least one geo database is required")
+	}
+	l := &MaxMindGeoLocator{}
+	if countryDbPath != "" {
+		reader, err := maxminddb.Open(countryDbPath)
+		if err != nil {
+			return nil, fmt.Errorf("open country database: %w", err)
+		}
+		l.country = reader
+	}
+	if asnDbPath != "" {
+		reader, err := maxminddb.Open(asnDbPath)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("open asn database: %w", err), l.Close())
+		}
+		l.asn = reader
+	}
+	return l, nil
+}
+
+type maxMindCountryRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type maxMindAsnRecord struct {
+	AutonomousSystemNumber uint32 `maxminddb:"autonomous_system_number"`
+}
+
+// Адреса, которых нет в базах (например, частные), имеют пустое местоположение
+func (l *MaxMindGeoLocator) Locate(ctx context.Context, ipAddress string) (Location, error) {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return Location{}, nil
+	}
+	var location Location
+	if l.country != nil {
+		var record maxMindCountryRecord
+		if err := l.country.Lookup(ip, &record); err != nil {
+			return Location{}, fmt.Errorf("lookup country: %w", err)
+		}
+		location.Country = record.Country.IsoCode
+	}
+	if l.asn != nil {
+		var record maxMindAsnRecord
+		if err := l.asn.Lookup(ip, &record); err != nil {
+			return Location{}, fmt.Errorf("lookup asn: %w", err)
+		}
+		location.Asn = record.AutonomousSystemNumber
+	}
+	return location, nil
+}
+
+func (l *MaxMindGeoLocator) Capabilities() GeoCapabilities {
+	return GeoCapabilities{
+		Country: l.country != nil,
+		Asn:     l.asn != nil,
+	}
+}
+
+func (l *MaxMindGeoLocator) Close() error {
+	var errs []error
+	if l.country != nil {
+		errs = append(errs, l.country.Close())
+	}
+	if l.asn != nil {
+		errs = append(errs, l.asn.Close())
+	}
+	return errors.Join(errs...)
+}
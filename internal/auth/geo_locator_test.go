@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMaxMindGeoLocator(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.mmdb")
+	cases := []struct {
+		name      string
+		countryDb string
+		asnDb     string
+	}{
+		{
+			name: "should require a database",
+		},
+		{
+			name:      "should fail on missing country database",
+			countryDb: missing,
+		},
+		{
+			name:  "should fail on missing asn database",
+			asnDb: missing,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := OpenMaxMindGeoLocator(c.countryDb, c.asnDb); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/x0k/medods-authentication-service/internal/lib/jwk"
+)
+
+var ErrNoActiveKey = errors.New("no active signing key")
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type retiredKey struct {
+	key       *SigningKey
+	expiresAt time.Time
+}
+
+type keyringEntry struct {
+	key *SigningKey
+	// Нулевое значение для активного ключа
+	expiresAt time.Time
+}
+
+// Активный ключ подписывает новые токены, выведенные из оборота ключи
+// продолжают проверять ранее выданные токены пока не истечет их срок.
+type Keyring struct {
+	mu      sync.RWMutex
+	now     func() time.Time
+	active  *SigningKey
+	entries map[string]keyringEntry
+
+	reloadMu       sync.Mutex
+	reload         func() error
+	reloadInterval time.Duration
+	reloadedAt     time.Time
+}
+
+func NewKeyring(now func() time.Time) *Keyring {
+	return &Keyring{
+		now:     now,
+		entries: make(map[string]keyringEntry),
+	}
+}
+
+func (k *Keyring) reset(active *SigningKey, retired []retiredKey) {
+	entries := make(map[string]keyringEntry, len(retired)+1)
+	for _, r := range retired {
+		entries[r.key.id] = keyringEntry{key: r.key, expiresAt: r.expiresAt}
+	}
+	if active != nil {
+		entries[active.id] = keyringEntry{key: active}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = active
+	k.entries = entries
+}
+
+func (k *Keyring) Active() (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.active == nil {
+		return nil, ErrNoActiveKey
+	}
+	return k.active, nil
+}
+
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := k.Active()
+	if err != nil {
+		return "", err
+	}
+	return key.Sign(claims)
+}
+
+// Ключ, ротированный другим экземпляром сервиса, неизвестен до перезагрузки.
+// При неизвестном `kid` ключи перезагружаются не чаще раза в `interval`,
+// чтобы токены с поддельным `kid` не нагружали хранилище.
+func (k *Keyring) ReloadOnUnknownKey(reload func() error, interval time.Duration) {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+	k.reload = reload
+	k.reloadInterval = interval
+}
+
+func (k *Keyring) KeyFunc(t *jwt.Token) (any, error) {
+	key, err := k.keyFunc(t)
+	if errors.Is(err, ErrUnknownKey) && k.reloadUnknown() {
+		return k.keyFunc(t)
+	}
+	return key, err
+}
+
+func (k *Keyring) reloadUnknown() bool {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+	if k.reload == nil {
+		return false
+	}
+	now := k.now()
+	if !k.reloadedAt.IsZero() && now.Sub(k.reloadedAt) < k.reloadInterval {
+		return false
+	}
+	k.reloadedAt = now
+	return k.reload() == nil
+}
+
+func (k *Keyring) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	// Токены выданные до появления идентификаторов ключей
+	if kid == "" {
+		set := jwt.VerificationKeySet{}
+		for _, e := range k.entries {
+			if e.valid(now) && e.key.method.Alg() == t.Method.Alg() {
+				set.Keys = append(set.Keys, e.key.verifyKey)
+			}
+		}
+		if len(set.Keys) == 0 {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
+		}
+		return set, nil
+	}
+	e, ok := k.entries[kid]
+	if !ok || !e.valid(now) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return e.key.KeyFunc(t)
+}
+
+func (k *Keyring) JWKS() (jwk.Set, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	set := jwk.Set{Keys: []jwk.Key{}}
+	for _, e := range k.entries {
+		if !e.valid(now) {
+			continue
+		}
+		key, ok, err := e.key.JWK()
+		if err != nil {
+			return set, err
+		}
+		if ok {
+			set.Keys = append(set.Keys, key)
+		}
+	}
+	slices.SortFunc(set.Keys, func(a, b jwk.Key) int {
+		return strings.Compare(a.Kid, b.Kid)
+	})
+	return set, nil
+}
+
+func (e keyringEntry) valid(now time.Time) bool {
+	return e.expiresAt.IsZero() || now.Before(e.expiresAt)
+}
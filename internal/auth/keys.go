@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/x0k/medods-authentication-service/internal/lib/jwk"
+)
+
+const (
+	HS512 = "HS512"
+	EdDSA = "EdDSA"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+var ErrUnsupportedSigningAlgorithm = errors.New("unsupported signing algorithm")
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+type SigningKey struct {
+	id        string
+	material  []byte
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// Для `HS512` в качестве материала ключа используется секрет,
+// для остальных алгоритмов - приватный ключ в формате PEM.
+func NewSigningKey(algorithm string, material []byte) (*SigningKey, error) {
+	if len(material) == 0 {
+		return nil, fmt.Errorf("%w: empty key material", ErrInvalidSigningKey)
+	}
+	key := &SigningKey{material: material}
+	switch algorithm {
+	case HS512:
+		key.method = jwt.SigningMethodHS512
+		key.signKey = material
+		key.verifyKey = material
+	case EdDSA:
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(material)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSigningKey, err)
+		}
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected key type %T", ErrInvalidSigningKey, privateKey)
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.signKey = edKey
+		key.verifyKey = edKey.Public()
+	case RS256:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(material)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSigningKey, err)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.signKey = privateKey
+		key.verifyKey = &privateKey.PublicKey
+	case ES256:
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(material)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSigningKey, err)
+		}
+		if privateKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: expected P-256 curve, got %s", ErrInvalidSigningKey, privateKey.Curve.Params().Name)
+		}
+		key.method = jwt.SigningMethodES256
+		key.signKey = privateKey
+		key.verifyKey = &privateKey.PublicKey
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSigningAlgorithm, algorithm)
+	}
+	id, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.id = id
+	return key, nil
+}
+
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var privateKey crypto.PrivateKey
+	var err error
+	switch algorithm {
+	case HS512:
+		secret := make([]byte, 64)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewSigningKey(algorithm, secret)
+	case EdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case RS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSigningAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(algorithm, pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}))
+}
+
+func (k *SigningKey) Id() string {
+	return k.id
+}
+
+func (k *SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+func (k *SigningKey) Sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(k.method, claims)
+	t.Header["kid"] = k.id
+	return t.SignedString(k.signKey)
+}
+
+func (k *SigningKey) KeyFunc(t *jwt.Token) (any, error) {
+	if t.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
+	}
+	return k.verifyKey, nil
+}
+
+func (k *SigningKey) publicKey() (crypto.PublicKey, bool) {
+	if _, isSecret := k.verifyKey.([]byte); isSecret {
+		return nil, false
+	}
+	return k.verifyKey, true
+}
+
+// Публичный ключ для проверки подписей вне сервиса, симметричные ключи не публикуются
+func (k *SigningKey) JWK() (jwk.Key, bool, error) {
+	publicKey, ok := k.publicKey()
+	if !ok {
+		return jwk.Key{}, false, nil
+	}
+	key, err := jwk.New(k.id, k.method.Alg(), publicKey)
+	return key, true, err
+}
+
+// Идентификатор ключа вычисляется из публичной части ключа
+// (или секрета), поэтому один и тот же ключ из конфигурации
+// всегда получает один и тот же `kid`.
+func (k *SigningKey) thumbprint() (string, error) {
+	data := k.material
+	if publicKey, ok := k.publicKey(); ok {
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrInvalidSigningKey, err)
+		}
+		data = der
+	}
+	hash := sha256.Sum256(append([]byte(k.method.Alg()+":"), data...))
+	return base64.RawURLEncoding.EncodeToString(hash[:12]), nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidKeysEncryptionKey = errors.New("keys encryption key must be 32 bytes")
+
+// Шифрует материал ключей подписи перед сохранением в базе данных (AES-256-GCM).
+// Идентификатор ключа используется как связанные данные, поэтому
+// материал одного ключа нельзя подставить в запись другого.
+type KeysCipher struct {
+	aead cipher.AEAD
+}
+
+func NewKeysCipher(key []byte) (*KeysCipher, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKeysEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeysCipher{aead: aead}, nil
+}
+
+func (c *KeysCipher) seal(kid string, material []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(material)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, material, []byte(kid)), nil
+}
+
+func (c *KeysCipher) open(kid string, sealed []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("sealed material is too short")
+	}
+	return c.aead.Open(nil, sealed[:size], sealed[size:], []byte(kid))
+}
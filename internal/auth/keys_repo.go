@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+type signingKeysRepository struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func newSigningKeysRepository(log *logger.Logger, pool *pgxpool.Pool) *signingKeysRepository {
+	return &signingKeysRepository{
+		log:  log,
+		pool: pool,
+	}
+}
+
+const signingKeysQuery = `SELECT id, algorithm, material, encrypted, retired_at, expires_at FROM signing_key
+WHERE expires_at IS NULL OR expires_at > now()`
+
+func (r *signingKeysRepository) SigningKeys(ctx context.Context) ([]SigningKeyRecord, error) {
+	return r.signingKeys(ctx, signingKeysQuery)
+}
+
+const allSigningKeysQuery = `SELECT id, algorithm, material, encrypted, retired_at, expires_at FROM signing_key`
+
+func (r *signingKeysRepository) AllSigningKeys(ctx context.Context) ([]SigningKeyRecord, error) {
+	return r.signingKeys(ctx, allSigningKeysQuery)
+}
+
+func (r *signingKeysRepository) signingKeys(ctx context.Context, query string) ([]SigningKeyRecord, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", query))
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (SigningKeyRecord, error) {
+		var record SigningKeyRecord
+		err := row.Scan(
+			&record.Id,
+			&record.Algorithm,
+			&record.Material,
+			&record.Encrypted,
+			&record.RetiredAt,
+			&record.ExpiresAt,
+		)
+		return record, err
+	})
+}
+
+const retireSigningKeysQuery = `UPDATE signing_key SET retired_at = now(), expires_at = $2
+WHERE retired_at IS NULL AND id <> $1`
+
+// Ключ уже мог быть добавлен другим экземпляром сервиса с той же конфигурацией
+const insertSigningKeyQuery = `INSERT INTO signing_key (id, algorithm, material, encrypted)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET retired_at = NULL, expires_at = NULL`
+
+func (r *signingKeysRepository) ActivateSigningKey(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	key SigningKeyRecord,
+	retiredKeysExpiresAt time.Time,
+) error {
+	args := []any{key.Id, retiredKeysExpiresAt}
+	r.log.Debug(ctx, "executing query", slog.String("query", retireSigningKeysQuery), slog.Any("args", args))
+	if _, err := uow.Tx().Exec(ctx, retireSigningKeysQuery, args...); err != nil {
+		return err
+	}
+	args = []any{key.Id, key.Algorithm, key.Material, key.Encrypted}
+	r.log.Debug(ctx, "executing query", slog.String("query", insertSigningKeyQuery), slog.Any("args", args[:2]))
+	_, err := uow.Tx().Exec(ctx, insertSigningKeyQuery, args...)
+	return err
+}
+
+const encryptSigningKeyQuery = `UPDATE signing_key SET material = $2, encrypted = true
+WHERE id = $1 AND NOT encrypted`
+
+func (r *signingKeysRepository) EncryptSigningKey(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	id string,
+	material []byte,
+) error {
+	r.log.Debug(ctx, "executing query", slog.String("query", encryptSigningKeyQuery), slog.String("kid", id))
+	_, err := uow.Tx().Exec(ctx, encryptSigningKeyQuery, id, material)
+	return err
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrFailedToRotateKeys = errors.New("failed to rotate keys")
+
+type SigningKeyRecord struct {
+	Id        string
+	Algorithm string
+	Material  []byte
+	// Записи, сохраненные до включения шифрования, хранят материал открыто
+	Encrypted bool
+	RetiredAt *time.Time
+	ExpiresAt *time.Time
+}
+
+type SigningKeysRepository[T any] interface {
+	SigningKeys(ctx context.Context) ([]SigningKeyRecord, error)
+	// Включая истекшие ключи
+	AllSigningKeys(ctx context.Context) ([]SigningKeyRecord, error)
+	ActivateSigningKey(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		key SigningKeyRecord,
+		retiredKeysExpiresAt time.Time,
+	) error
+	EncryptSigningKey(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		id string,
+		material []byte,
+	) error
+}
+
+type keysService[T any] struct {
+	log        *logger.Logger
+	keyring    *Keyring
+	repo       SigningKeysRepository[T]
+	cipher     *KeysCipher
+	uowFactory unit_of_work.Factory[T]
+	algorithm  string
+	retention  time.Duration
+	now        func() time.Time
+}
+
+func newKeysService[T any](
+	log *logger.Logger,
+	keyring *Keyring,
+	repo SigningKeysRepository[T],
+	cipher *KeysCipher,
+	uowFactory unit_of_work.Factory[T],
+	algorithm string,
+	retention time.Duration,
+	now func() time.Time,
+) *keysService[T] {
+	return &keysService[T]{
+		log:        log,
+		keyring:    keyring,
+		repo:       repo,
+		cipher:     cipher,
+		uowFactory: uowFactory,
+		algorithm:  algorithm,
+		retention:  retention,
+		now:        now,
+	}
+}
+
+// Ключ из конфигурации становится активным только если он никогда не сохранялся,
+// иначе перезапуск сервиса отменял бы ротацию выполненную через API.
+// Истекшие ключи тоже учитываются, чтобы не вернуть в оборот выведенный ключ.
+func (s *keysService[T]) Load(ctx context.Context, configured *SigningKey) error {
+	records, err := s.repo.AllSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	if err := s.encryptLegacy(ctx, records); err != nil {
+		return fmt.Errorf("failed to encrypt signing keys: %w", err)
+	}
+	known := slices.ContainsFunc(records, func(r SigningKeyRecord) bool {
+		return r.Id == configured.id
+	})
+	if !known {
+		s.log.Info(ctx, "rotating signing key from config", slog.String("kid", configured.id))
+		return s.activate(ctx, configured)
+	}
+	return s.Reload(ctx)
+}
+
+func (s *keysService[T]) Reload(ctx context.Context) error {
+	records, err := s.repo.SigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	return s.reset(records)
+}
+
+// Подхватывает ключи, ротированные другими экземплярами сервиса
+func (s *keysService[T]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Reload(ctx); err != nil {
+			s.log.Error(ctx, "failed to reload signing keys", sl.Err(err))
+		}
+	}
+}
+
+func (s *keysService[T]) Rotate(ctx context.Context) (*SigningKey, *shared.DomainError) {
+	key, err := GenerateSigningKey(s.algorithm)
+	if err != nil {
+		return nil, shared.NewUnexpectedError(
+			fmt.Errorf("%w: generate key: %s", ErrFailedToRotateKeys, err),
+			"failed to generate signing key",
+		)
+	}
+	if err := s.activate(ctx, key); err != nil {
+		return nil, shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToRotateKeys, err),
+			"failed to persist signing key",
+		)
+	}
+	s.log.Info(ctx, "signing key rotated", slog.String("kid", key.id))
+	return key, nil
+}
+
+func (s *keysService[T]) activate(ctx context.Context, key *SigningKey) error {
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return fmt.Errorf("create unit of work: %w", err)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	material, err := s.cipher.seal(key.id, key.material)
+	if err != nil {
+		return fmt.Errorf("encrypt signing key: %w", err)
+	}
+	if err := s.repo.ActivateSigningKey(ctx, uow, SigningKeyRecord{
+		Id:        key.id,
+		Algorithm: key.Algorithm(),
+		Material:  material,
+		Encrypted: true,
+	}, s.now().Add(s.retention)); err != nil {
+		return fmt.Errorf("activate signing key: %w", err)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return fmt.Errorf("commit unit of work: %w", err)
+	}
+	records, err := s.repo.SigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("reload signing keys: %w", err)
+	}
+	return s.reset(records)
+}
+
+// Шифрует ключи, сохраненные до включения шифрования
+func (s *keysService[T]) encryptLegacy(ctx context.Context, records []SigningKeyRecord) error {
+	legacy := slices.DeleteFunc(slices.Clone(records), func(r SigningKeyRecord) bool {
+		return r.Encrypted
+	})
+	if len(legacy) == 0 {
+		return nil
+	}
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return fmt.Errorf("create unit of work: %w", err)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	for _, r := range legacy {
+		material, err := s.cipher.seal(r.Id, r.Material)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", r.Id, err)
+		}
+		if err := s.repo.EncryptSigningKey(ctx, uow, r.Id, material); err != nil {
+			return fmt.Errorf("signing key %q: %w", r.Id, err)
+		}
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return fmt.Errorf("commit unit of work: %w", err)
+	}
+	s.log.Info(ctx, "signing keys encrypted", slog.Int("count", len(legacy)))
+	return nil
+}
+
+func (s *keysService[T]) reset(records []SigningKeyRecord) error {
+	var active *SigningKey
+	retired := make([]retiredKey, 0, len(records))
+	for _, r := range records {
+		material := r.Material
+		if r.Encrypted {
+			var err error
+			if material, err = s.cipher.open(r.Id, r.Material); err != nil {
+				return fmt.Errorf("signing key %q: decrypt: %w", r.Id, err)
+			}
+		}
+		key, err := NewSigningKey(r.Algorithm, material)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", r.Id, err)
+		}
+		if r.RetiredAt == nil {
+			active = key
+			continue
+		}
+		var expiresAt time.Time
+		if r.ExpiresAt != nil {
+			expiresAt = *r.ExpiresAt
+		}
+		retired = append(retired, retiredKey{key: key, expiresAt: expiresAt})
+	}
+	if active == nil {
+		return ErrNoActiveKey
+	}
+	s.keyring.reset(active, retired)
+	return nil
+}
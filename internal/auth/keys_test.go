@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+func privateKeyPEM(t *testing.T, key crypto.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestSigningKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		algorithm string
+		material  []byte
+		public    bool
+		err       error
+	}{
+		{
+			name:      "should use secret for HS512",
+			algorithm: HS512,
+			material:  []byte("secret"),
+		},
+		{
+			name:      "should load EdDSA private key",
+			algorithm: EdDSA,
+			material:  privateKeyPEM(t, edKey),
+			public:    true,
+		},
+		{
+			name:      "should load RS256 private key",
+			algorithm: RS256,
+			material:  privateKeyPEM(t, rsaKey),
+			public:    true,
+		},
+		{
+			name:      "should load ES256 private key",
+			algorithm: ES256,
+			material:  privateKeyPEM(t, ecKey),
+			public:    true,
+		},
+		{
+			name:      "should reject ES256 key with wrong curve",
+			algorithm: ES256,
+			material:  privateKeyPEM(t, ecKey384),
+			err:       ErrInvalidSigningKey,
+		},
+		{
+			name:      "should reject key of another type",
+			algorithm: RS256,
+			material:  privateKeyPEM(t, edKey),
+			err:       ErrInvalidSigningKey,
+		},
+		{
+			name:      "should reject empty key material",
+			algorithm: HS512,
+			err:       ErrInvalidSigningKey,
+		},
+		{
+			name:      "should reject unknown algorithm",
+			algorithm: "none",
+			material:  []byte("secret"),
+			err:       ErrUnsupportedSigningAlgorithm,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key, err := NewSigningKey(c.algorithm, c.material)
+			if err != nil {
+				if c.err == nil || !errors.Is(err, c.err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if c.err != nil {
+				t.Fatalf("expected error %v", c.err)
+			}
+			token, err := key.Sign(jwt.MapClaims{"sub": "test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := jwt.Parse(token, key.KeyFunc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Method.Alg() != c.algorithm {
+				t.Errorf("expected algorithm %s, got %s", c.algorithm, parsed.Method.Alg())
+			}
+			if parsed.Header["kid"] != key.Id() {
+				t.Errorf("expected kid %s, got %v", key.Id(), parsed.Header["kid"])
+			}
+			if _, public, err := key.JWK(); err != nil || public != c.public {
+				t.Errorf("unexpected public key export: %v, %v", public, err)
+			}
+			same, err := NewSigningKey(c.algorithm, c.material)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same.Id() != key.Id() {
+				t.Errorf("expected stable kid %s, got %s", key.Id(), same.Id())
+			}
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Now()
+	keyring := NewKeyring(func() time.Time { return now })
+	oldKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := oldKey.Sign(jwt.MapClaims{"sub": "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredToken, err := expiredKey.Sign(jwt.MapClaims{"sub": "expired"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring.reset(activeKey, []retiredKey{
+		{key: oldKey, expiresAt: now.Add(time.Hour)},
+		{key: expiredKey, expiresAt: now.Add(-time.Hour)},
+	})
+
+	token, err := keyring.Sign(jwt.MapClaims{"sub": "active"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(token, keyring.KeyFunc); err != nil {
+		t.Errorf("active key: %s", err)
+	}
+	if _, err := jwt.Parse(oldToken, keyring.KeyFunc); err != nil {
+		t.Errorf("retired key: %s", err)
+	}
+	if _, err := jwt.Parse(expiredToken, keyring.KeyFunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected unknown key error for expired key, got %v", err)
+	}
+
+	withoutKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "legacy"})
+	legacyToken, err := withoutKid.SignedString(oldKey.signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(legacyToken, keyring.KeyFunc); err != nil {
+		t.Errorf("token without kid: %s", err)
+	}
+
+	set, err := keyring.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 2 {
+		t.Errorf("expected 2 public keys, got %d", len(set.Keys))
+	}
+}
+
+func TestKeysServiceLoad(t *testing.T) {
+	ctx := context.Background()
+	log := logger.New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	configured, err := NewSigningKey(HS512, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := NewSigningKey(HS512, []byte("previous secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retiredAt := now.Add(-time.Minute)
+	expiresAt := now.Add(time.Hour)
+	cipher, err := NewKeysCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seal := func(key *SigningKey) []byte {
+		material, err := cipher.seal(key.Id(), key.material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return material
+	}
+
+	t.Run("should keep persisted state for known key", func(t *testing.T) {
+		repo := NewMockSigningKeysRepository[any](t)
+		records := []SigningKeyRecord{
+			{Id: previous.Id(), Algorithm: HS512, Material: seal(previous), Encrypted: true},
+			{Id: configured.Id(), Algorithm: HS512, Material: seal(configured), Encrypted: true, RetiredAt: &retiredAt, ExpiresAt: &expiresAt},
+		}
+		repo.EXPECT().AllSigningKeys(mock.Anything).Return(records, nil)
+		repo.EXPECT().SigningKeys(mock.Anything).Return(records, nil)
+		keyring := NewKeyring(time.Now)
+		service := newKeysService(log, keyring, repo, cipher, unit_of_work.NewMockFactory[any](t).Execute, HS512, time.Hour, time.Now)
+		if err := service.Load(ctx, configured); err != nil {
+			t.Fatal(err)
+		}
+		active, err := keyring.Active()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.Id() != previous.Id() {
+			t.Errorf("expected active key %s, got %s", previous.Id(), active.Id())
+		}
+	})
+
+	t.Run("should activate unknown key from config", func(t *testing.T) {
+		repo := NewMockSigningKeysRepository[any](t)
+		uowFactory := unit_of_work.NewMockFactory[any](t)
+		uow := unit_of_work.NewMockUnitOfWork[any](t)
+		uowFactory.EXPECT().Execute(mock.Anything).Return(uow, nil)
+		uow.EXPECT().Commit(mock.Anything).Return(nil)
+		uow.EXPECT().Rollback(mock.Anything).Return(nil)
+		repo.EXPECT().AllSigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: previous.Id(), Algorithm: HS512, Material: seal(previous), Encrypted: true},
+		}, nil)
+		repo.EXPECT().
+			ActivateSigningKey(mock.Anything, uow, mock.MatchedBy(func(r SigningKeyRecord) bool {
+				material, err := cipher.open(configured.Id(), r.Material)
+				return err == nil && r.Encrypted && bytes.Equal(material, configured.material)
+			}), now.Add(time.Hour)).
+			Return(nil)
+		repo.EXPECT().SigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: previous.Id(), Algorithm: HS512, Material: seal(previous), Encrypted: true, RetiredAt: &now, ExpiresAt: &expiresAt},
+			{Id: configured.Id(), Algorithm: HS512, Material: seal(configured), Encrypted: true},
+		}, nil)
+		keyring := NewKeyring(time.Now)
+		service := newKeysService(log, keyring, repo, cipher, uowFactory.Execute, HS512, time.Hour, func() time.Time { return now })
+		if err := service.Load(ctx, configured); err != nil {
+			t.Fatal(err)
+		}
+		active, err := keyring.Active()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.Id() != configured.Id() {
+			t.Errorf("expected active key %s, got %s", configured.Id(), active.Id())
+		}
+	})
+
+	t.Run("should not reactivate expired key from config after restart", func(t *testing.T) {
+		repo := NewMockSigningKeysRepository[any](t)
+		expiredAt := now.Add(-time.Minute)
+		retiredAt := expiredAt.Add(-time.Hour)
+		repo.EXPECT().AllSigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: previous.Id(), Algorithm: HS512, Material: seal(previous), Encrypted: true},
+			{Id: configured.Id(), Algorithm: HS512, Material: seal(configured), Encrypted: true, RetiredAt: &retiredAt, ExpiresAt: &expiredAt},
+		}, nil)
+		repo.EXPECT().SigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: previous.Id(), Algorithm: HS512, Material: seal(previous), Encrypted: true},
+		}, nil)
+		keyring := NewKeyring(time.Now)
+		// Фабрика без ожиданий: активация ключа провалит тест
+		service := newKeysService(log, keyring, repo, cipher, unit_of_work.NewMockFactory[any](t).Execute, HS512, time.Hour, func() time.Time { return now })
+		if err := service.Load(ctx, configured); err != nil {
+			t.Fatal(err)
+		}
+		active, err := keyring.Active()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.Id() != previous.Id() {
+			t.Errorf("expected active key %s, got %s", previous.Id(), active.Id())
+		}
+	})
+
+	t.Run("should encrypt plaintext keys", func(t *testing.T) {
+		repo := NewMockSigningKeysRepository[any](t)
+		uowFactory := unit_of_work.NewMockFactory[any](t)
+		uow := unit_of_work.NewMockUnitOfWork[any](t)
+		uowFactory.EXPECT().Execute(mock.Anything).Return(uow, nil)
+		uow.EXPECT().Commit(mock.Anything).Return(nil)
+		uow.EXPECT().Rollback(mock.Anything).Return(nil)
+		repo.EXPECT().AllSigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: configured.Id(), Algorithm: HS512, Material: configured.material},
+		}, nil)
+		repo.EXPECT().
+			EncryptSigningKey(mock.Anything, uow, configured.Id(), mock.MatchedBy(func(sealed []byte) bool {
+				material, err := cipher.open(configured.Id(), sealed)
+				return err == nil && bytes.Equal(material, configured.material)
+			})).
+			Return(nil)
+		repo.EXPECT().SigningKeys(mock.Anything).Return([]SigningKeyRecord{
+			{Id: configured.Id(), Algorithm: HS512, Material: seal(configured), Encrypted: true},
+		}, nil)
+		keyring := NewKeyring(time.Now)
+		service := newKeysService(log, keyring, repo, cipher, uowFactory.Execute, HS512, time.Hour, func() time.Time { return now })
+		if err := service.Load(ctx, configured); err != nil {
+			t.Fatal(err)
+		}
+		active, err := keyring.Active()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.Id() != configured.Id() {
+			t.Errorf("expected active key %s, got %s", configured.Id(), active.Id())
+		}
+	})
+}
+
+func TestKeyringReloadOnUnknownKey(t *testing.T) {
+	now := time.Now()
+	keyring := NewKeyring(func() time.Time { return now })
+	oldKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring.reset(oldKey, nil)
+	reloads := 0
+	keyring.ReloadOnUnknownKey(func() error {
+		reloads++
+		// Ключ ротирован другим экземпляром
+		keyring.reset(rotatedKey, []retiredKey{{key: oldKey, expiresAt: now.Add(time.Hour)}})
+		return nil
+	}, time.Minute)
+	forgedToken, err := forgedKey.Sign(jwt.MapClaims{"sub": "forged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedToken, err := rotatedKey.Sign(jwt.MapClaims{"sub": "rotated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jwt.Parse(rotatedToken, keyring.KeyFunc); err != nil {
+		t.Errorf("rotated key: %s", err)
+	}
+	if _, err := jwt.Parse(forgedToken, keyring.KeyFunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+	if reloads != 1 {
+		t.Errorf("expected reloads to be throttled, got %d", reloads)
+	}
+	now = now.Add(time.Minute)
+	if _, err := jwt.Parse(forgedToken, keyring.KeyFunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+	if reloads != 2 {
+		t.Errorf("expected reload after interval, got %d", reloads)
+	}
+}
+
+func TestKeysCipher(t *testing.T) {
+	cipher, err := NewKeysCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := cipher.seal("kid", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("sealed material contains plaintext")
+	}
+	material, err := cipher.open("kid", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(material) != "secret" {
+		t.Errorf("expected %q, got %q", "secret", material)
+	}
+	if _, err := cipher.open("other kid", sealed); err == nil {
+		t.Error("expected material to be bound to key id")
+	}
+	if _, err := NewKeysCipher([]byte("short")); !errors.Is(err, ErrInvalidKeysEncryptionKey) {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
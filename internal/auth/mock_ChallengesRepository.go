@@ -0,0 +1,298 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package auth
+
+import (
+	context "context"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+// MockChallengesRepository is an autogenerated mock type for the ChallengesRepository type
+type MockChallengesRepository[T any] struct {
+	mock.Mock
+}
+
+type MockChallengesRepository_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockChallengesRepository[T]) EXPECT() *MockChallengesRepository_Expecter[T] {
+	return &MockChallengesRepository_Expecter[T]{mock: &_m.Mock}
+}
+
+// Challenge provides a mock function with given fields: ctx, uow, id
+func (_m *MockChallengesRepository[T]) Challenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) (Challenge, error) {
+	ret := _m.Called(ctx, uow, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Challenge")
+	}
+
+	var r0 Challenge
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (Challenge, error)); ok {
+		return rf(ctx, uow, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) Challenge); ok {
+		r0 = rf(ctx, uow, id)
+	} else {
+		r0 = ret.Get(0).(Challenge)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error); ok {
+		r1 = rf(ctx, uow, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockChallengesRepository_Challenge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Challenge'
+type MockChallengesRepository_Challenge_Call[T any] struct {
+	*mock.Call
+}
+
+// Challenge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - id uuid.UUID
+func (_e *MockChallengesRepository_Expecter[T]) Challenge(ctx interface{}, uow interface{}, id interface{}) *MockChallengesRepository_Challenge_Call[T] {
+	return &MockChallengesRepository_Challenge_Call[T]{Call: _e.mock.On("Challenge", ctx, uow, id)}
+}
+
+func (_c *MockChallengesRepository_Challenge_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID)) *MockChallengesRepository_Challenge_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockChallengesRepository_Challenge_Call[T]) Return(_a0 Challenge, _a1 error) *MockChallengesRepository_Challenge_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockChallengesRepository_Challenge_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (Challenge, error)) *MockChallengesRepository_Challenge_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateChallenge provides a mock function with given fields: ctx, uow, challenge
+func (_m *MockChallengesRepository[T]) CreateChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], challenge Challenge) error {
+	ret := _m.Called(ctx, uow, challenge)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateChallenge")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], Challenge) error); ok {
+		r0 = rf(ctx, uow, challenge)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockChallengesRepository_CreateChallenge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateChallenge'
+type MockChallengesRepository_CreateChallenge_Call[T any] struct {
+	*mock.Call
+}
+
+// CreateChallenge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - challenge Challenge
+func (_e *MockChallengesRepository_Expecter[T]) CreateChallenge(ctx interface{}, uow interface{}, challenge interface{}) *MockChallengesRepository_CreateChallenge_Call[T] {
+	return &MockChallengesRepository_CreateChallenge_Call[T]{Call: _e.mock.On("CreateChallenge", ctx, uow, challenge)}
+}
+
+func (_c *MockChallengesRepository_CreateChallenge_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], challenge Challenge)) *MockChallengesRepository_CreateChallenge_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(Challenge))
+	})
+	return _c
+}
+
+func (_c *MockChallengesRepository_CreateChallenge_Call[T]) Return(_a0 error) *MockChallengesRepository_CreateChallenge_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockChallengesRepository_CreateChallenge_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], Challenge) error) *MockChallengesRepository_CreateChallenge_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteChallenge provides a mock function with given fields: ctx, uow, id
+func (_m *MockChallengesRepository[T]) DeleteChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) error {
+	ret := _m.Called(ctx, uow, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteChallenge")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error); ok {
+		r0 = rf(ctx, uow, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockChallengesRepository_DeleteChallenge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteChallenge'
+type MockChallengesRepository_DeleteChallenge_Call[T any] struct {
+	*mock.Call
+}
+
+// DeleteChallenge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - id uuid.UUID
+func (_e *MockChallengesRepository_Expecter[T]) DeleteChallenge(ctx interface{}, uow interface{}, id interface{}) *MockChallengesRepository_DeleteChallenge_Call[T] {
+	return &MockChallengesRepository_DeleteChallenge_Call[T]{Call: _e.mock.On("DeleteChallenge", ctx, uow, id)}
+}
+
+func (_c *MockChallengesRepository_DeleteChallenge_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID)) *MockChallengesRepository_DeleteChallenge_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockChallengesRepository_DeleteChallenge_Call[T]) Return(_a0 error) *MockChallengesRepository_DeleteChallenge_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockChallengesRepository_DeleteChallenge_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error) *MockChallengesRepository_DeleteChallenge_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IncrementChallengeAttempts provides a mock function with given fields: ctx, uow, id
+func (_m *MockChallengesRepository[T]) IncrementChallengeAttempts(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID) error {
+	ret := _m.Called(ctx, uow, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementChallengeAttempts")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error); ok {
+		r0 = rf(ctx, uow, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockChallengesRepository_IncrementChallengeAttempts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IncrementChallengeAttempts'
+type MockChallengesRepository_IncrementChallengeAttempts_Call[T any] struct {
+	*mock.Call
+}
+
+// IncrementChallengeAttempts is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - id uuid.UUID
+func (_e *MockChallengesRepository_Expecter[T]) IncrementChallengeAttempts(ctx interface{}, uow interface{}, id interface{}) *MockChallengesRepository_IncrementChallengeAttempts_Call[T] {
+	return &MockChallengesRepository_IncrementChallengeAttempts_Call[T]{Call: _e.mock.On("IncrementChallengeAttempts", ctx, uow, id)}
+}
+
+func (_c *MockChallengesRepository_IncrementChallengeAttempts_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID)) *MockChallengesRepository_IncrementChallengeAttempts_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockChallengesRepository_IncrementChallengeAttempts_Call[T]) Return(_a0 error) *MockChallengesRepository_IncrementChallengeAttempts_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockChallengesRepository_IncrementChallengeAttempts_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error) *MockChallengesRepository_IncrementChallengeAttempts_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PairChallenge provides a mock function with given fields: ctx, uow, accessTokenHash
+func (_m *MockChallengesRepository[T]) PairChallenge(ctx context.Context, uow unit_of_work.UnitOfWork[T], accessTokenHash []byte) (Challenge, error) {
+	ret := _m.Called(ctx, uow, accessTokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PairChallenge")
+	}
+
+	var r0 Challenge
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], []byte) (Challenge, error)); ok {
+		return rf(ctx, uow, accessTokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], []byte) Challenge); ok {
+		r0 = rf(ctx, uow, accessTokenHash)
+	} else {
+		r0 = ret.Get(0).(Challenge)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], []byte) error); ok {
+		r1 = rf(ctx, uow, accessTokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockChallengesRepository_PairChallenge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PairChallenge'
+type MockChallengesRepository_PairChallenge_Call[T any] struct {
+	*mock.Call
+}
+
+// PairChallenge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - accessTokenHash []byte
+func (_e *MockChallengesRepository_Expecter[T]) PairChallenge(ctx interface{}, uow interface{}, accessTokenHash interface{}) *MockChallengesRepository_PairChallenge_Call[T] {
+	return &MockChallengesRepository_PairChallenge_Call[T]{Call: _e.mock.On("PairChallenge", ctx, uow, accessTokenHash)}
+}
+
+func (_c *MockChallengesRepository_PairChallenge_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], accessTokenHash []byte)) *MockChallengesRepository_PairChallenge_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].([]byte))
+	})
+	return _c
+}
+
+func (_c *MockChallengesRepository_PairChallenge_Call[T]) Return(_a0 Challenge, _a1 error) *MockChallengesRepository_PairChallenge_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockChallengesRepository_PairChallenge_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], []byte) (Challenge, error)) *MockChallengesRepository_PairChallenge_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockChallengesRepository creates a new instance of MockChallengesRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockChallengesRepository[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockChallengesRepository[T] {
+	mock := &MockChallengesRepository[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -22,17 +22,17 @@ func (_m *MockMessagesSender) EXPECT() *MockMessagesSender_Expecter {
 	return &MockMessagesSender_Expecter{mock: &_m.Mock}
 }
 
-// SendWarning provides a mock function with given fields: ctx, userId, message
-func (_m *MockMessagesSender) SendWarning(ctx context.Context, userId uuid.UUID, message string) error {
-	ret := _m.Called(ctx, userId, message)
+// SendVerificationCode provides a mock function with given fields: ctx, userId, code
+func (_m *MockMessagesSender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	ret := _m.Called(ctx, userId, code)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SendWarning")
+		panic("no return value specified for SendVerificationCode")
 	}
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
-		r0 = rf(ctx, userId, message)
+		r0 = rf(ctx, userId, code)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -40,32 +40,32 @@ func (_m *MockMessagesSender) SendWarning(ctx context.Context, userId uuid.UUID,
 	return r0
 }
 
-// MockMessagesSender_SendWarning_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendWarning'
-type MockMessagesSender_SendWarning_Call struct {
+// MockMessagesSender_SendVerificationCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendVerificationCode'
+type MockMessagesSender_SendVerificationCode_Call struct {
 	*mock.Call
 }
 
-// SendWarning is a helper method to define mock.On call
+// SendVerificationCode is a helper method to define mock.On call
 //   - ctx context.Context
 //   - userId uuid.UUID
-//   - message string
-func (_e *MockMessagesSender_Expecter) SendWarning(ctx interface{}, userId interface{}, message interface{}) *MockMessagesSender_SendWarning_Call {
-	return &MockMessagesSender_SendWarning_Call{Call: _e.mock.On("SendWarning", ctx, userId, message)}
+//   - code string
+func (_e *MockMessagesSender_Expecter) SendVerificationCode(ctx interface{}, userId interface{}, code interface{}) *MockMessagesSender_SendVerificationCode_Call {
+	return &MockMessagesSender_SendVerificationCode_Call{Call: _e.mock.On("SendVerificationCode", ctx, userId, code)}
 }
 
-func (_c *MockMessagesSender_SendWarning_Call) Run(run func(ctx context.Context, userId uuid.UUID, message string)) *MockMessagesSender_SendWarning_Call {
+func (_c *MockMessagesSender_SendVerificationCode_Call) Run(run func(ctx context.Context, userId uuid.UUID, code string)) *MockMessagesSender_SendVerificationCode_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(string))
 	})
 	return _c
 }
 
-func (_c *MockMessagesSender_SendWarning_Call) Return(_a0 error) *MockMessagesSender_SendWarning_Call {
+func (_c *MockMessagesSender_SendVerificationCode_Call) Return(_a0 error) *MockMessagesSender_SendVerificationCode_Call {
 	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockMessagesSender_SendWarning_Call) RunAndReturn(run func(context.Context, uuid.UUID, string) error) *MockMessagesSender_SendWarning_Call {
+func (_c *MockMessagesSender_SendVerificationCode_Call) RunAndReturn(run func(context.Context, uuid.UUID, string) error) *MockMessagesSender_SendVerificationCode_Call {
 	_c.Call.Return(run)
 	return _c
 }
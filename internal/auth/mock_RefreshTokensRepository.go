@@ -4,10 +4,13 @@ package auth
 
 import (
 	context "context"
+	time "time"
 
-	uuid "github.com/google/uuid"
 	mock "github.com/stretchr/testify/mock"
+
 	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+
+	uuid "github.com/google/uuid"
 )
 
 // MockRefreshTokensRepository is an autogenerated mock type for the RefreshTokensRepository type
@@ -23,27 +26,414 @@ func (_m *MockRefreshTokensRepository[T]) EXPECT() *MockRefreshTokensRepository_
 	return &MockRefreshTokensRepository_Expecter[T]{mock: &_m.Mock}
 }
 
-// TokenHash provides a mock function with given fields: ctx, uow, userId, deviceId
-func (_m *MockRefreshTokensRepository[T]) TokenHash(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId) ([]byte, error) {
-	ret := _m.Called(ctx, uow, userId, deviceId)
+// DeleteTokenFamily provides a mock function with given fields: ctx, uow, userId, familyId
+func (_m *MockRefreshTokensRepository[T]) DeleteTokenFamily(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, familyId uuid.UUID) (int64, error) {
+	ret := _m.Called(ctx, uow, userId, familyId)
 
 	if len(ret) == 0 {
-		panic("no return value specified for TokenHash")
+		panic("no return value specified for DeleteTokenFamily")
 	}
 
-	var r0 []byte
+	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) ([]byte, error)); ok {
-		return rf(ctx, uow, userId, deviceId)
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, uuid.UUID) (int64, error)); ok {
+		return rf(ctx, uow, userId, familyId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, uuid.UUID) int64); ok {
+		r0 = rf(ctx, uow, userId, familyId)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, uow, userId, familyId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_DeleteTokenFamily_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteTokenFamily'
+type MockRefreshTokensRepository_DeleteTokenFamily_Call[T any] struct {
+	*mock.Call
+}
+
+// DeleteTokenFamily is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - userId uuid.UUID
+//   - familyId uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) DeleteTokenFamily(ctx interface{}, uow interface{}, userId interface{}, familyId interface{}) *MockRefreshTokensRepository_DeleteTokenFamily_Call[T] {
+	return &MockRefreshTokensRepository_DeleteTokenFamily_Call[T]{Call: _e.mock.On("DeleteTokenFamily", ctx, uow, userId, familyId)}
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenFamily_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, familyId uuid.UUID)) *MockRefreshTokensRepository_DeleteTokenFamily_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenFamily_Call[T]) Return(_a0 int64, _a1 error) *MockRefreshTokensRepository_DeleteTokenFamily_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenFamily_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, uuid.UUID) (int64, error)) *MockRefreshTokensRepository_DeleteTokenFamily_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteTokenHash provides a mock function with given fields: ctx, uow, userId, deviceId
+func (_m *MockRefreshTokensRepository[T]) DeleteTokenHash(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId) error {
+	ret := _m.Called(ctx, uow, userId, deviceId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTokenHash")
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) []byte); ok {
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) error); ok {
 		r0 = rf(ctx, uow, userId, deviceId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_DeleteTokenHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteTokenHash'
+type MockRefreshTokensRepository_DeleteTokenHash_Call[T any] struct {
+	*mock.Call
+}
+
+// DeleteTokenHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - userId uuid.UUID
+//   - deviceId DeviceId
+func (_e *MockRefreshTokensRepository_Expecter[T]) DeleteTokenHash(ctx interface{}, uow interface{}, userId interface{}, deviceId interface{}) *MockRefreshTokensRepository_DeleteTokenHash_Call[T] {
+	return &MockRefreshTokensRepository_DeleteTokenHash_Call[T]{Call: _e.mock.On("DeleteTokenHash", ctx, uow, userId, deviceId)}
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenHash_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId)) *MockRefreshTokensRepository_DeleteTokenHash_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(DeviceId))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenHash_Call[T]) Return(_a0 error) *MockRefreshTokensRepository_DeleteTokenHash_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteTokenHash_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) error) *MockRefreshTokensRepository_DeleteTokenHash_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteUserTokens provides a mock function with given fields: ctx, uow, userId
+func (_m *MockRefreshTokensRepository[T]) DeleteUserTokens(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID) (int64, error) {
+	ret := _m.Called(ctx, uow, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserTokens")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (int64, error)); ok {
+		return rf(ctx, uow, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) int64); ok {
+		r0 = rf(ctx, uow, userId)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error); ok {
+		r1 = rf(ctx, uow, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_DeleteUserTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUserTokens'
+type MockRefreshTokensRepository_DeleteUserTokens_Call[T any] struct {
+	*mock.Call
+}
+
+// DeleteUserTokens is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - userId uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) DeleteUserTokens(ctx interface{}, uow interface{}, userId interface{}) *MockRefreshTokensRepository_DeleteUserTokens_Call[T] {
+	return &MockRefreshTokensRepository_DeleteUserTokens_Call[T]{Call: _e.mock.On("DeleteUserTokens", ctx, uow, userId)}
+}
+
+func (_c *MockRefreshTokensRepository_DeleteUserTokens_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID)) *MockRefreshTokensRepository_DeleteUserTokens_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteUserTokens_Call[T]) Return(_a0 int64, _a1 error) *MockRefreshTokensRepository_DeleteUserTokens_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_DeleteUserTokens_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (int64, error)) *MockRefreshTokensRepository_DeleteUserTokens_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IncrementTokenVersion provides a mock function with given fields: ctx, uow, userId
+func (_m *MockRefreshTokensRepository[T]) IncrementTokenVersion(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID) (int64, error) {
+	ret := _m.Called(ctx, uow, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementTokenVersion")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (int64, error)); ok {
+		return rf(ctx, uow, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) int64); ok {
+		r0 = rf(ctx, uow, userId)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) error); ok {
+		r1 = rf(ctx, uow, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_IncrementTokenVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IncrementTokenVersion'
+type MockRefreshTokensRepository_IncrementTokenVersion_Call[T any] struct {
+	*mock.Call
+}
+
+// IncrementTokenVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - userId uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) IncrementTokenVersion(ctx interface{}, uow interface{}, userId interface{}) *MockRefreshTokensRepository_IncrementTokenVersion_Call[T] {
+	return &MockRefreshTokensRepository_IncrementTokenVersion_Call[T]{Call: _e.mock.On("IncrementTokenVersion", ctx, uow, userId)}
+}
+
+func (_c *MockRefreshTokensRepository_IncrementTokenVersion_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID)) *MockRefreshTokensRepository_IncrementTokenVersion_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_IncrementTokenVersion_Call[T]) Return(_a0 int64, _a1 error) *MockRefreshTokensRepository_IncrementTokenVersion_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_IncrementTokenVersion_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID) (int64, error)) *MockRefreshTokensRepository_IncrementTokenVersion_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IsAccessTokenRevoked provides a mock function with given fields: ctx, jti
+func (_m *MockRefreshTokensRepository[T]) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	ret := _m.Called(ctx, jti)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsAccessTokenRevoked")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (bool, error)); ok {
+		return rf(ctx, jti)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) bool); ok {
+		r0 = rf(ctx, jti)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, jti)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_IsAccessTokenRevoked_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IsAccessTokenRevoked'
+type MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T any] struct {
+	*mock.Call
+}
+
+// IsAccessTokenRevoked is a helper method to define mock.On call
+//   - ctx context.Context
+//   - jti uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) IsAccessTokenRevoked(ctx interface{}, jti interface{}) *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T] {
+	return &MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T]{Call: _e.mock.On("IsAccessTokenRevoked", ctx, jti)}
+}
+
+func (_c *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T]) Run(run func(ctx context.Context, jti uuid.UUID)) *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T]) Return(_a0 bool, _a1 error) *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T]) RunAndReturn(run func(context.Context, uuid.UUID) (bool, error)) *MockRefreshTokensRepository_IsAccessTokenRevoked_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeAccessToken provides a mock function with given fields: ctx, uow, jti, expiresAt
+func (_m *MockRefreshTokensRepository[T]) RevokeAccessToken(ctx context.Context, uow unit_of_work.UnitOfWork[T], jti uuid.UUID, expiresAt time.Time) error {
+	ret := _m.Called(ctx, uow, jti, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAccessToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, uow, jti, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_RevokeAccessToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeAccessToken'
+type MockRefreshTokensRepository_RevokeAccessToken_Call[T any] struct {
+	*mock.Call
+}
+
+// RevokeAccessToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - jti uuid.UUID
+//   - expiresAt time.Time
+func (_e *MockRefreshTokensRepository_Expecter[T]) RevokeAccessToken(ctx interface{}, uow interface{}, jti interface{}, expiresAt interface{}) *MockRefreshTokensRepository_RevokeAccessToken_Call[T] {
+	return &MockRefreshTokensRepository_RevokeAccessToken_Call[T]{Call: _e.mock.On("RevokeAccessToken", ctx, uow, jti, expiresAt)}
+}
+
+func (_c *MockRefreshTokensRepository_RevokeAccessToken_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], jti uuid.UUID, expiresAt time.Time)) *MockRefreshTokensRepository_RevokeAccessToken_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeAccessToken_Call[T]) Return(_a0 error) *MockRefreshTokensRepository_RevokeAccessToken_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeAccessToken_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) error) *MockRefreshTokensRepository_RevokeAccessToken_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Sessions provides a mock function with given fields: ctx, userId
+func (_m *MockRefreshTokensRepository[T]) Sessions(ctx context.Context, userId uuid.UUID) ([]SessionRecord, error) {
+	ret := _m.Called(ctx, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sessions")
+	}
+
+	var r0 []SessionRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]SessionRecord, error)); ok {
+		return rf(ctx, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []SessionRecord); ok {
+		r0 = rf(ctx, userId)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).([]SessionRecord)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_Sessions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sessions'
+type MockRefreshTokensRepository_Sessions_Call[T any] struct {
+	*mock.Call
+}
+
+// Sessions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) Sessions(ctx interface{}, userId interface{}) *MockRefreshTokensRepository_Sessions_Call[T] {
+	return &MockRefreshTokensRepository_Sessions_Call[T]{Call: _e.mock.On("Sessions", ctx, userId)}
+}
+
+func (_c *MockRefreshTokensRepository_Sessions_Call[T]) Run(run func(ctx context.Context, userId uuid.UUID)) *MockRefreshTokensRepository_Sessions_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Sessions_Call[T]) Return(_a0 []SessionRecord, _a1 error) *MockRefreshTokensRepository_Sessions_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Sessions_Call[T]) RunAndReturn(run func(context.Context, uuid.UUID) ([]SessionRecord, error)) *MockRefreshTokensRepository_Sessions_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TokenRecord provides a mock function with given fields: ctx, uow, userId, deviceId
+func (_m *MockRefreshTokensRepository[T]) TokenRecord(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId) (TokenRecord, error) {
+	ret := _m.Called(ctx, uow, userId, deviceId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TokenRecord")
+	}
+
+	var r0 TokenRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) (TokenRecord, error)); ok {
+		return rf(ctx, uow, userId, deviceId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) TokenRecord); ok {
+		r0 = rf(ctx, uow, userId, deviceId)
+	} else {
+		r0 = ret.Get(0).(TokenRecord)
+	}
+
 	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) error); ok {
 		r1 = rf(ctx, uow, userId, deviceId)
 	} else {
@@ -53,48 +443,105 @@ func (_m *MockRefreshTokensRepository[T]) TokenHash(ctx context.Context, uow uni
 	return r0, r1
 }
 
-// MockRefreshTokensRepository_TokenHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TokenHash'
-type MockRefreshTokensRepository_TokenHash_Call[T any] struct {
+// MockRefreshTokensRepository_TokenRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TokenRecord'
+type MockRefreshTokensRepository_TokenRecord_Call[T any] struct {
 	*mock.Call
 }
 
-// TokenHash is a helper method to define mock.On call
+// TokenRecord is a helper method to define mock.On call
 //   - ctx context.Context
 //   - uow unit_of_work.UnitOfWork[T]
 //   - userId uuid.UUID
 //   - deviceId DeviceId
-func (_e *MockRefreshTokensRepository_Expecter[T]) TokenHash(ctx interface{}, uow interface{}, userId interface{}, deviceId interface{}) *MockRefreshTokensRepository_TokenHash_Call[T] {
-	return &MockRefreshTokensRepository_TokenHash_Call[T]{Call: _e.mock.On("TokenHash", ctx, uow, userId, deviceId)}
+func (_e *MockRefreshTokensRepository_Expecter[T]) TokenRecord(ctx interface{}, uow interface{}, userId interface{}, deviceId interface{}) *MockRefreshTokensRepository_TokenRecord_Call[T] {
+	return &MockRefreshTokensRepository_TokenRecord_Call[T]{Call: _e.mock.On("TokenRecord", ctx, uow, userId, deviceId)}
 }
 
-func (_c *MockRefreshTokensRepository_TokenHash_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId)) *MockRefreshTokensRepository_TokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_TokenRecord_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId)) *MockRefreshTokensRepository_TokenRecord_Call[T] {
 	_c.Call.Run(func(args mock.Arguments) {
 		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(DeviceId))
 	})
 	return _c
 }
 
-func (_c *MockRefreshTokensRepository_TokenHash_Call[T]) Return(_a0 []byte, _a1 error) *MockRefreshTokensRepository_TokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_TokenRecord_Call[T]) Return(_a0 TokenRecord, _a1 error) *MockRefreshTokensRepository_TokenRecord_Call[T] {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockRefreshTokensRepository_TokenHash_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) ([]byte, error)) *MockRefreshTokensRepository_TokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_TokenRecord_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId) (TokenRecord, error)) *MockRefreshTokensRepository_TokenRecord_Call[T] {
 	_c.Call.Return(run)
 	return _c
 }
 
-// UpdateTokenHash provides a mock function with given fields: ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash
-func (_m *MockRefreshTokensRepository[T]) UpdateTokenHash(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, oldDeviceId DeviceId, newDeviceId DeviceId, tokenHash []byte) error {
-	ret := _m.Called(ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash)
+// TokenVersion provides a mock function with given fields: ctx, userId
+func (_m *MockRefreshTokensRepository[T]) TokenVersion(ctx context.Context, userId uuid.UUID) (int64, error) {
+	ret := _m.Called(ctx, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TokenVersion")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (int64, error)); ok {
+		return rf(ctx, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) int64); ok {
+		r0 = rf(ctx, userId)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_TokenVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TokenVersion'
+type MockRefreshTokensRepository_TokenVersion_Call[T any] struct {
+	*mock.Call
+}
+
+// TokenVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+func (_e *MockRefreshTokensRepository_Expecter[T]) TokenVersion(ctx interface{}, userId interface{}) *MockRefreshTokensRepository_TokenVersion_Call[T] {
+	return &MockRefreshTokensRepository_TokenVersion_Call[T]{Call: _e.mock.On("TokenVersion", ctx, userId)}
+}
+
+func (_c *MockRefreshTokensRepository_TokenVersion_Call[T]) Run(run func(ctx context.Context, userId uuid.UUID)) *MockRefreshTokensRepository_TokenVersion_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_TokenVersion_Call[T]) Return(_a0 int64, _a1 error) *MockRefreshTokensRepository_TokenVersion_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_TokenVersion_Call[T]) RunAndReturn(run func(context.Context, uuid.UUID) (int64, error)) *MockRefreshTokensRepository_TokenVersion_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateTokenHash provides a mock function with given fields: ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash, expiresAt, usedAt, client
+func (_m *MockRefreshTokensRepository[T]) UpdateTokenHash(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, oldDeviceId DeviceId, newDeviceId DeviceId, tokenHash []byte, expiresAt time.Time, usedAt time.Time, client Client) error {
+	ret := _m.Called(ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash, expiresAt, usedAt, client)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateTokenHash")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, DeviceId, []byte) error); ok {
-		r0 = rf(ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash)
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, DeviceId, []byte, time.Time, time.Time, Client) error); ok {
+		r0 = rf(ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash, expiresAt, usedAt, client)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -114,13 +561,16 @@ type MockRefreshTokensRepository_UpdateTokenHash_Call[T any] struct {
 //   - oldDeviceId DeviceId
 //   - newDeviceId DeviceId
 //   - tokenHash []byte
-func (_e *MockRefreshTokensRepository_Expecter[T]) UpdateTokenHash(ctx interface{}, uow interface{}, userId interface{}, oldDeviceId interface{}, newDeviceId interface{}, tokenHash interface{}) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
-	return &MockRefreshTokensRepository_UpdateTokenHash_Call[T]{Call: _e.mock.On("UpdateTokenHash", ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash)}
+//   - expiresAt time.Time
+//   - usedAt time.Time
+//   - client Client
+func (_e *MockRefreshTokensRepository_Expecter[T]) UpdateTokenHash(ctx interface{}, uow interface{}, userId interface{}, oldDeviceId interface{}, newDeviceId interface{}, tokenHash interface{}, expiresAt interface{}, usedAt interface{}, client interface{}) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
+	return &MockRefreshTokensRepository_UpdateTokenHash_Call[T]{Call: _e.mock.On("UpdateTokenHash", ctx, uow, userId, oldDeviceId, newDeviceId, tokenHash, expiresAt, usedAt, client)}
 }
 
-func (_c *MockRefreshTokensRepository_UpdateTokenHash_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, oldDeviceId DeviceId, newDeviceId DeviceId, tokenHash []byte)) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_UpdateTokenHash_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, oldDeviceId DeviceId, newDeviceId DeviceId, tokenHash []byte, expiresAt time.Time, usedAt time.Time, client Client)) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(DeviceId), args[4].(DeviceId), args[5].([]byte))
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(DeviceId), args[4].(DeviceId), args[5].([]byte), args[6].(time.Time), args[7].(time.Time), args[8].(Client))
 	})
 	return _c
 }
@@ -130,22 +580,22 @@ func (_c *MockRefreshTokensRepository_UpdateTokenHash_Call[T]) Return(_a0 error)
 	return _c
 }
 
-func (_c *MockRefreshTokensRepository_UpdateTokenHash_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, DeviceId, []byte) error) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_UpdateTokenHash_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, DeviceId, []byte, time.Time, time.Time, Client) error) *MockRefreshTokensRepository_UpdateTokenHash_Call[T] {
 	_c.Call.Return(run)
 	return _c
 }
 
-// UpsertTokenHash provides a mock function with given fields: ctx, userId, deviceId, tokenHash
-func (_m *MockRefreshTokensRepository[T]) UpsertTokenHash(ctx context.Context, userId uuid.UUID, deviceId DeviceId, tokenHash []byte) error {
-	ret := _m.Called(ctx, userId, deviceId, tokenHash)
+// UpsertTokenHash provides a mock function with given fields: ctx, uow, userId, deviceId, tokenHash, expiresAt, sessionStartedAt, familyId, client
+func (_m *MockRefreshTokensRepository[T]) UpsertTokenHash(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId, tokenHash []byte, expiresAt time.Time, sessionStartedAt time.Time, familyId uuid.UUID, client Client) error {
+	ret := _m.Called(ctx, uow, userId, deviceId, tokenHash, expiresAt, sessionStartedAt, familyId, client)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpsertTokenHash")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, DeviceId, []byte) error); ok {
-		r0 = rf(ctx, userId, deviceId, tokenHash)
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, []byte, time.Time, time.Time, uuid.UUID, Client) error); ok {
+		r0 = rf(ctx, uow, userId, deviceId, tokenHash, expiresAt, sessionStartedAt, familyId, client)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -160,16 +610,21 @@ type MockRefreshTokensRepository_UpsertTokenHash_Call[T any] struct {
 
 // UpsertTokenHash is a helper method to define mock.On call
 //   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
 //   - userId uuid.UUID
 //   - deviceId DeviceId
 //   - tokenHash []byte
-func (_e *MockRefreshTokensRepository_Expecter[T]) UpsertTokenHash(ctx interface{}, userId interface{}, deviceId interface{}, tokenHash interface{}) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
-	return &MockRefreshTokensRepository_UpsertTokenHash_Call[T]{Call: _e.mock.On("UpsertTokenHash", ctx, userId, deviceId, tokenHash)}
+//   - expiresAt time.Time
+//   - sessionStartedAt time.Time
+//   - familyId uuid.UUID
+//   - client Client
+func (_e *MockRefreshTokensRepository_Expecter[T]) UpsertTokenHash(ctx interface{}, uow interface{}, userId interface{}, deviceId interface{}, tokenHash interface{}, expiresAt interface{}, sessionStartedAt interface{}, familyId interface{}, client interface{}) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
+	return &MockRefreshTokensRepository_UpsertTokenHash_Call[T]{Call: _e.mock.On("UpsertTokenHash", ctx, uow, userId, deviceId, tokenHash, expiresAt, sessionStartedAt, familyId, client)}
 }
 
-func (_c *MockRefreshTokensRepository_UpsertTokenHash_Call[T]) Run(run func(ctx context.Context, userId uuid.UUID, deviceId DeviceId, tokenHash []byte)) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_UpsertTokenHash_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, deviceId DeviceId, tokenHash []byte, expiresAt time.Time, sessionStartedAt time.Time, familyId uuid.UUID, client Client)) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(DeviceId), args[3].([]byte))
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(DeviceId), args[4].([]byte), args[5].(time.Time), args[6].(time.Time), args[7].(uuid.UUID), args[8].(Client))
 	})
 	return _c
 }
@@ -179,7 +634,7 @@ func (_c *MockRefreshTokensRepository_UpsertTokenHash_Call[T]) Return(_a0 error)
 	return _c
 }
 
-func (_c *MockRefreshTokensRepository_UpsertTokenHash_Call[T]) RunAndReturn(run func(context.Context, uuid.UUID, DeviceId, []byte) error) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
+func (_c *MockRefreshTokensRepository_UpsertTokenHash_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, DeviceId, []byte, time.Time, time.Time, uuid.UUID, Client) error) *MockRefreshTokensRepository_UpsertTokenHash_Call[T] {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,100 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package auth
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockReportTokensRepository is an autogenerated mock type for the ReportTokensRepository type
+type MockReportTokensRepository[T any] struct {
+	mock.Mock
+}
+
+type MockReportTokensRepository_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockReportTokensRepository[T]) EXPECT() *MockReportTokensRepository_Expecter[T] {
+	return &MockReportTokensRepository_Expecter[T]{mock: &_m.Mock}
+}
+
+// UseReportToken provides a mock function with given fields: ctx, uow, id, expiresAt
+func (_m *MockReportTokensRepository[T]) UseReportToken(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID, expiresAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, uow, id, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseReportToken")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) (bool, error)); ok {
+		return rf(ctx, uow, id, expiresAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) bool); ok {
+		r0 = rf(ctx, uow, id, expiresAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) error); ok {
+		r1 = rf(ctx, uow, id, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockReportTokensRepository_UseReportToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UseReportToken'
+type MockReportTokensRepository_UseReportToken_Call[T any] struct {
+	*mock.Call
+}
+
+// UseReportToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - id uuid.UUID
+//   - expiresAt time.Time
+func (_e *MockReportTokensRepository_Expecter[T]) UseReportToken(ctx interface{}, uow interface{}, id interface{}, expiresAt interface{}) *MockReportTokensRepository_UseReportToken_Call[T] {
+	return &MockReportTokensRepository_UseReportToken_Call[T]{Call: _e.mock.On("UseReportToken", ctx, uow, id, expiresAt)}
+}
+
+func (_c *MockReportTokensRepository_UseReportToken_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID, expiresAt time.Time)) *MockReportTokensRepository_UseReportToken_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockReportTokensRepository_UseReportToken_Call[T]) Return(_a0 bool, _a1 error) *MockReportTokensRepository_UseReportToken_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockReportTokensRepository_UseReportToken_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, time.Time) (bool, error)) *MockReportTokensRepository_UseReportToken_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockReportTokensRepository creates a new instance of MockReportTokensRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockReportTokensRepository[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockReportTokensRepository[T] {
+	mock := &MockReportTokensRepository[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
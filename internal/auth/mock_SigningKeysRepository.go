@@ -0,0 +1,253 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package auth
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+// MockSigningKeysRepository is an autogenerated mock type for the SigningKeysRepository type
+type MockSigningKeysRepository[T any] struct {
+	mock.Mock
+}
+
+type MockSigningKeysRepository_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSigningKeysRepository[T]) EXPECT() *MockSigningKeysRepository_Expecter[T] {
+	return &MockSigningKeysRepository_Expecter[T]{mock: &_m.Mock}
+}
+
+// ActivateSigningKey provides a mock function with given fields: ctx, uow, key, retiredKeysExpiresAt
+func (_m *MockSigningKeysRepository[T]) ActivateSigningKey(ctx context.Context, uow unit_of_work.UnitOfWork[T], key SigningKeyRecord, retiredKeysExpiresAt time.Time) error {
+	ret := _m.Called(ctx, uow, key, retiredKeysExpiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ActivateSigningKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], SigningKeyRecord, time.Time) error); ok {
+		r0 = rf(ctx, uow, key, retiredKeysExpiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSigningKeysRepository_ActivateSigningKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ActivateSigningKey'
+type MockSigningKeysRepository_ActivateSigningKey_Call[T any] struct {
+	*mock.Call
+}
+
+// ActivateSigningKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - key SigningKeyRecord
+//   - retiredKeysExpiresAt time.Time
+func (_e *MockSigningKeysRepository_Expecter[T]) ActivateSigningKey(ctx interface{}, uow interface{}, key interface{}, retiredKeysExpiresAt interface{}) *MockSigningKeysRepository_ActivateSigningKey_Call[T] {
+	return &MockSigningKeysRepository_ActivateSigningKey_Call[T]{Call: _e.mock.On("ActivateSigningKey", ctx, uow, key, retiredKeysExpiresAt)}
+}
+
+func (_c *MockSigningKeysRepository_ActivateSigningKey_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], key SigningKeyRecord, retiredKeysExpiresAt time.Time)) *MockSigningKeysRepository_ActivateSigningKey_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(SigningKeyRecord), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_ActivateSigningKey_Call[T]) Return(_a0 error) *MockSigningKeysRepository_ActivateSigningKey_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_ActivateSigningKey_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], SigningKeyRecord, time.Time) error) *MockSigningKeysRepository_ActivateSigningKey_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// AllSigningKeys provides a mock function with given fields: ctx
+func (_m *MockSigningKeysRepository[T]) AllSigningKeys(ctx context.Context) ([]SigningKeyRecord, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AllSigningKeys")
+	}
+
+	var r0 []SigningKeyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]SigningKeyRecord, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []SigningKeyRecord); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]SigningKeyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockSigningKeysRepository_AllSigningKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AllSigningKeys'
+type MockSigningKeysRepository_AllSigningKeys_Call[T any] struct {
+	*mock.Call
+}
+
+// AllSigningKeys is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockSigningKeysRepository_Expecter[T]) AllSigningKeys(ctx interface{}) *MockSigningKeysRepository_AllSigningKeys_Call[T] {
+	return &MockSigningKeysRepository_AllSigningKeys_Call[T]{Call: _e.mock.On("AllSigningKeys", ctx)}
+}
+
+func (_c *MockSigningKeysRepository_AllSigningKeys_Call[T]) Run(run func(ctx context.Context)) *MockSigningKeysRepository_AllSigningKeys_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_AllSigningKeys_Call[T]) Return(_a0 []SigningKeyRecord, _a1 error) *MockSigningKeysRepository_AllSigningKeys_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_AllSigningKeys_Call[T]) RunAndReturn(run func(context.Context) ([]SigningKeyRecord, error)) *MockSigningKeysRepository_AllSigningKeys_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// EncryptSigningKey provides a mock function with given fields: ctx, uow, id, material
+func (_m *MockSigningKeysRepository[T]) EncryptSigningKey(ctx context.Context, uow unit_of_work.UnitOfWork[T], id string, material []byte) error {
+	ret := _m.Called(ctx, uow, id, material)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EncryptSigningKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], string, []byte) error); ok {
+		r0 = rf(ctx, uow, id, material)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSigningKeysRepository_EncryptSigningKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EncryptSigningKey'
+type MockSigningKeysRepository_EncryptSigningKey_Call[T any] struct {
+	*mock.Call
+}
+
+// EncryptSigningKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - id string
+//   - material []byte
+func (_e *MockSigningKeysRepository_Expecter[T]) EncryptSigningKey(ctx interface{}, uow interface{}, id interface{}, material interface{}) *MockSigningKeysRepository_EncryptSigningKey_Call[T] {
+	return &MockSigningKeysRepository_EncryptSigningKey_Call[T]{Call: _e.mock.On("EncryptSigningKey", ctx, uow, id, material)}
+}
+
+func (_c *MockSigningKeysRepository_EncryptSigningKey_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], id string, material []byte)) *MockSigningKeysRepository_EncryptSigningKey_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(string), args[3].([]byte))
+	})
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_EncryptSigningKey_Call[T]) Return(_a0 error) *MockSigningKeysRepository_EncryptSigningKey_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_EncryptSigningKey_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], string, []byte) error) *MockSigningKeysRepository_EncryptSigningKey_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SigningKeys provides a mock function with given fields: ctx
+func (_m *MockSigningKeysRepository[T]) SigningKeys(ctx context.Context) ([]SigningKeyRecord, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SigningKeys")
+	}
+
+	var r0 []SigningKeyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]SigningKeyRecord, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []SigningKeyRecord); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]SigningKeyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockSigningKeysRepository_SigningKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SigningKeys'
+type MockSigningKeysRepository_SigningKeys_Call[T any] struct {
+	*mock.Call
+}
+
+// SigningKeys is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockSigningKeysRepository_Expecter[T]) SigningKeys(ctx interface{}) *MockSigningKeysRepository_SigningKeys_Call[T] {
+	return &MockSigningKeysRepository_SigningKeys_Call[T]{Call: _e.mock.On("SigningKeys", ctx)}
+}
+
+func (_c *MockSigningKeysRepository_SigningKeys_Call[T]) Run(run func(ctx context.Context)) *MockSigningKeysRepository_SigningKeys_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_SigningKeys_Call[T]) Return(_a0 []SigningKeyRecord, _a1 error) *MockSigningKeysRepository_SigningKeys_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockSigningKeysRepository_SigningKeys_Call[T]) RunAndReturn(run func(context.Context) ([]SigningKeyRecord, error)) *MockSigningKeysRepository_SigningKeys_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSigningKeysRepository creates a new instance of MockSigningKeysRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSigningKeysRepository[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSigningKeysRepository[T] {
+	mock := &MockSigningKeysRepository[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
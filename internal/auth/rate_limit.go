@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/ratelimit"
+)
+
+// Нулевые ограничения отключены
+type RateLimits struct {
+	LoginIp     ratelimit.Limit
+	LoginUser   ratelimit.Limit
+	RefreshIp   ratelimit.Limit
+	RefreshUser ratelimit.Limit
+}
+
+// Ограничение размера JSON тела запросов, общее для контроллера и ограничителя
+const maxJsonBodySize = 1024
+
+type rateLimiter struct {
+	log     *logger.Logger
+	store   ratelimit.Store
+	limits  RateLimits
+	keyring *Keyring
+}
+
+func newRateLimiter(
+	log *logger.Logger,
+	store ratelimit.Store,
+	limits RateLimits,
+	keyring *Keyring,
+) *rateLimiter {
+	return &rateLimiter{
+		log:     log,
+		store:   store,
+		limits:  limits,
+		keyring: keyring,
+	}
+}
+
+func (l *rateLimiter) login(next http.HandlerFunc) http.Handler {
+	return http_adapters.RateLimit(l.log, l.store, "login", []http_adapters.RateLimitRule{
+		{Limit: l.limits.LoginIp, Key: http_adapters.IpRateLimitKey},
+		{Limit: l.limits.LoginUser, Key: loginUserRateLimitKey},
+	}, next)
+}
+
+func (l *rateLimiter) refresh(next http.HandlerFunc) http.Handler {
+	return http_adapters.RateLimit(l.log, l.store, "refresh", []http_adapters.RateLimitRule{
+		{Limit: l.limits.RefreshIp, Key: http_adapters.IpRateLimitKey},
+		{Limit: l.limits.RefreshUser, Key: l.refreshUserRateLimitKey},
+	}, next)
+}
+
+// Запросы, в которых не удалось определить пользователя, расходуют
+// общую для ip-адреса корзину с пользовательским ограничением
+func unidentifiedUserRateLimitKey(r *http.Request) string {
+	return "unidentified:" + http_adapters.ClientIpAddress(r)
+}
+
+func loginUserRateLimitKey(r *http.Request) string {
+	userId, err := uuid.Parse(r.URL.Query().Get("GUID"))
+	if err != nil {
+		return unidentifiedUserRateLimitKey(r)
+	}
+	return "user:" + userId.String()
+}
+
+// Пользователь определяется по подписанному Access токену, поэтому
+// поддельные токены не расходуют ограничения настоящего пользователя
+func (l *rateLimiter) refreshUserRateLimitKey(r *http.Request) string {
+	// Тело большего размера все равно будет отклонено контроллером
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxJsonBodySize+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxJsonBodySize {
+		return unidentifiedUserRateLimitKey(r)
+	}
+	var pair tokensPairDTO
+	if err := json.Unmarshal(body, &pair); err != nil {
+		return unidentifiedUserRateLimitKey(r)
+	}
+	token, err := jwt.Parse(pair.AccessToken, l.keyring.KeyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return unidentifiedUserRateLimitKey(r)
+	}
+	sub, err := token.Claims.GetSubject()
+	if err != nil || sub == "" {
+		return unidentifiedUserRateLimitKey(r)
+	}
+	return "user:" + sub
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRefreshUserRateLimitKey(t *testing.T) {
+	keyring := NewKeyring(time.Now)
+	key, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring.reset(key, nil)
+	forgedKey, err := GenerateSigningKey(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := keyring.Sign(jwt.MapClaims{"sub": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedToken, err := forgedKey.Sign(jwt.MapClaims{"sub": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newRateLimiter(nil, nil, RateLimits{}, keyring)
+	cases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "should use subject of signed access token",
+			body:     `{"accessToken":"` + accessToken + `","refreshToken":"refresh"}`,
+			expected: "user:user",
+		},
+		{
+			name:     "should read access token after long fields",
+			body:     `{"refreshToken":"` + strings.Repeat("a", 700) + `","accessToken":"` + accessToken + `"}`,
+			expected: "user:user",
+		},
+		{
+			name:     "should use unidentified bucket for forged token",
+			body:     `{"accessToken":"` + forgedToken + `"}`,
+			expected: "unidentified:192.0.2.1",
+		},
+		{
+			name:     "should use unidentified bucket for oversized body",
+			body:     `{"accessToken":"` + accessToken + `","refreshToken":"` + strings.Repeat("a", maxJsonBodySize) + `"}`,
+			expected: "unidentified:192.0.2.1",
+		},
+		{
+			name:     "should use unidentified bucket for malformed body",
+			body:     `{`,
+			expected: "unidentified:192.0.2.1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/refresh", strings.NewReader(tc.body))
+			if actual := l.refreshUserRateLimitKey(r); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(body, []byte(tc.body)) {
+				t.Error("request body was not restored")
+			}
+		})
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
 	"github.com/x0k/medods-authentication-service/internal/shared"
 )
 
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const foreignKeyViolation = "23503"
+
 type refreshTokensRepository struct {
 	log  *logger.Logger
 	pool *pgxpool.Pool
@@ -25,45 +30,62 @@ func newRefreshTokensRepository(log *logger.Logger, pool *pgxpool.Pool) *refresh
 	}
 }
 
-const saveTokeHashQuery = `INSERT INTO refresh_token (user_id, device_id, token_hash)
-VALUES ($1, $2, $3)
-ON CONFLICT (user_id, device_id) DO UPDATE SET token_hash = $3`
+// Время создания и количество обновлений относятся к устройству и
+// сохраняются при повторном входе
+const saveTokeHashQuery = `INSERT INTO refresh_token (user_id, device_id, token_hash, expires_at, session_started_at, last_used_at, family_id, last_ip, user_agent, created_at)
+VALUES ($1, $2, $3, $4, $5, $5, $6, $7, $8, $5)
+ON CONFLICT (user_id, device_id) DO UPDATE
+SET token_hash = $3, expires_at = $4, session_started_at = $5, last_used_at = $5, family_id = $6, last_ip = $7, user_agent = $8`
 
 func (r *refreshTokensRepository) UpsertTokenHash(
 	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
 	userId uuid.UUID,
 	deviceId DeviceId,
 	tokenHash []byte,
+	expiresAt time.Time,
+	sessionStartedAt time.Time,
+	familyId uuid.UUID,
+	client Client,
 ) error {
-	args := []any{userId, deviceId[:], tokenHash}
+	args := []any{userId, deviceId[:], tokenHash, expiresAt, sessionStartedAt, familyId, client.IpAddress, client.UserAgent}
 	r.log.Debug(ctx, "executing query", slog.String("query", saveTokeHashQuery), slog.Any("args", args))
-	_, err := r.pool.Exec(ctx, saveTokeHashQuery, args...)
+	_, err := uow.Tx().Exec(ctx, saveTokeHashQuery, args...)
 	return err
 }
 
-const tokenHashesQuery = `SELECT token_hash FROM refresh_token WHERE user_id = $1 AND device_id = $2`
+const tokenRecordQuery = `SELECT token_hash, expires_at, session_started_at, last_used_at, user_agent
+FROM refresh_token WHERE user_id = $1 AND device_id = $2`
 
-func (r *refreshTokensRepository) TokenHash(
+func (r *refreshTokensRepository) TokenRecord(
 	ctx context.Context,
 	uow unit_of_work.UnitOfWork[pgx.Tx],
 	userId uuid.UUID,
 	deviceId DeviceId,
-) ([]byte, error) {
+) (TokenRecord, error) {
 	args := []any{userId, deviceId[:]}
-	r.log.Debug(ctx, "executing query", slog.String("query", tokenHashesQuery), slog.Any("args", args))
-	row := uow.Tx().QueryRow(ctx, tokenHashesQuery, args...)
-	var tokenHash []byte
-	err := row.Scan(&tokenHash)
+	r.log.Debug(ctx, "executing query", slog.String("query", tokenRecordQuery), slog.Any("args", args))
+	row := uow.Tx().QueryRow(ctx, tokenRecordQuery, args...)
+	var record TokenRecord
+	err := row.Scan(
+		&record.TokenHash,
+		&record.ExpiresAt,
+		&record.SessionStartedAt,
+		&record.LastUsedAt,
+		&record.UserAgent,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, shared.ErrNotFound
+			return TokenRecord{}, shared.ErrNotFound
 		}
-		return nil, err
+		return TokenRecord{}, err
 	}
-	return tokenHash, nil
+	return record, nil
 }
 
-const replaceTokenHashQuery = `UPDATE refresh_token SET device_id = $3, token_hash = $4
+const replaceTokenHashQuery = `UPDATE refresh_token
+SET device_id = $3, token_hash = $4, expires_at = $5, last_used_at = $6, last_ip = $7, user_agent = $8,
+refresh_count = refresh_count + 1
 WHERE user_id = $1 AND device_id = $2`
 
 func (r *refreshTokensRepository) UpdateTokenHash(
@@ -73,8 +95,11 @@ func (r *refreshTokensRepository) UpdateTokenHash(
 	oldDeviceId DeviceId,
 	newDeviceId DeviceId,
 	tokenHash []byte,
+	expiresAt time.Time,
+	usedAt time.Time,
+	client Client,
 ) error {
-	args := []any{userId, oldDeviceId[:], newDeviceId[:], tokenHash}
+	args := []any{userId, oldDeviceId[:], newDeviceId[:], tokenHash, expiresAt, usedAt, client.IpAddress, client.UserAgent}
 	r.log.Debug(ctx, "executing query", slog.String("query", replaceTokenHashQuery), slog.Any("args", args))
 	cmd, err := uow.Tx().Exec(ctx, replaceTokenHashQuery, args...)
 	if err != nil {
@@ -85,3 +110,152 @@ func (r *refreshTokensRepository) UpdateTokenHash(
 	}
 	return nil
 }
+
+const sessionsQuery = `SELECT family_id, device_id, created_at, session_started_at, last_used_at, last_ip, user_agent, refresh_count
+FROM refresh_token WHERE user_id = $1 AND expires_at > now()
+ORDER BY last_used_at DESC`
+
+func (r *refreshTokensRepository) Sessions(ctx context.Context, userId uuid.UUID) ([]SessionRecord, error) {
+	args := []any{userId}
+	r.log.Debug(ctx, "executing query", slog.String("query", sessionsQuery), slog.Any("args", args))
+	rows, err := r.pool.Query(ctx, sessionsQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (SessionRecord, error) {
+		var record SessionRecord
+		var deviceId []byte
+		err := row.Scan(
+			&record.Id,
+			&deviceId,
+			&record.CreatedAt,
+			&record.SessionStartedAt,
+			&record.LastUsedAt,
+			&record.LastIpAddress,
+			&record.UserAgent,
+			&record.RefreshCount,
+		)
+		copy(record.DeviceId[:], deviceId)
+		return record, err
+	})
+}
+
+const deleteTokenFamilyQuery = `DELETE FROM refresh_token WHERE user_id = $1 AND family_id = $2`
+
+func (r *refreshTokensRepository) DeleteTokenFamily(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	userId uuid.UUID,
+	familyId uuid.UUID,
+) (int64, error) {
+	args := []any{userId, familyId}
+	r.log.Debug(ctx, "executing query", slog.String("query", deleteTokenFamilyQuery), slog.Any("args", args))
+	cmd, err := uow.Tx().Exec(ctx, deleteTokenFamilyQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+const deleteTokenHashQuery = `DELETE FROM refresh_token WHERE user_id = $1 AND device_id = $2`
+
+func (r *refreshTokensRepository) DeleteTokenHash(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	userId uuid.UUID,
+	deviceId DeviceId,
+) error {
+	args := []any{userId, deviceId[:]}
+	r.log.Debug(ctx, "executing query", slog.String("query", deleteTokenHashQuery), slog.Any("args", args))
+	cmd, err := uow.Tx().Exec(ctx, deleteTokenHashQuery, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return shared.ErrNotFound
+	}
+	return nil
+}
+
+// Записи удаляются после истечения срока действия токена
+const purgeRevokedAccessTokensQuery = `DELETE FROM revoked_access_token WHERE expires_at < now()`
+
+const revokeAccessTokenQuery = `INSERT INTO revoked_access_token (jti, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING`
+
+func (r *refreshTokensRepository) RevokeAccessToken(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	jti uuid.UUID,
+	expiresAt time.Time,
+) error {
+	r.log.Debug(ctx, "executing query", slog.String("query", purgeRevokedAccessTokensQuery))
+	if _, err := uow.Tx().Exec(ctx, purgeRevokedAccessTokensQuery); err != nil {
+		return err
+	}
+	args := []any{jti, expiresAt}
+	r.log.Debug(ctx, "executing query", slog.String("query", revokeAccessTokenQuery), slog.Any("args", args))
+	_, err := uow.Tx().Exec(ctx, revokeAccessTokenQuery, args...)
+	return err
+}
+
+const isAccessTokenRevokedQuery = `SELECT EXISTS (SELECT 1 FROM revoked_access_token WHERE jti = $1)`
+
+func (r *refreshTokensRepository) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	args := []any{jti}
+	r.log.Debug(ctx, "executing query", slog.String("query", isAccessTokenRevokedQuery), slog.Any("args", args))
+	var revoked bool
+	err := r.pool.QueryRow(ctx, isAccessTokenRevokedQuery, args...).Scan(&revoked)
+	return revoked, err
+}
+
+const deleteUserTokensQuery = `DELETE FROM refresh_token WHERE user_id = $1`
+
+func (r *refreshTokensRepository) DeleteUserTokens(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	userId uuid.UUID,
+) (int64, error) {
+	args := []any{userId}
+	r.log.Debug(ctx, "executing query", slog.String("query", deleteUserTokensQuery), slog.Any("args", args))
+	cmd, err := uow.Tx().Exec(ctx, deleteUserTokensQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+const tokenVersionQuery = `SELECT version FROM user_token_version WHERE user_id = $1`
+
+func (r *refreshTokensRepository) TokenVersion(ctx context.Context, userId uuid.UUID) (int64, error) {
+	args := []any{userId}
+	r.log.Debug(ctx, "executing query", slog.String("query", tokenVersionQuery), slog.Any("args", args))
+	var version int64
+	err := r.pool.QueryRow(ctx, tokenVersionQuery, args...).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+const incrementTokenVersionQuery = `INSERT INTO user_token_version (user_id, version)
+VALUES ($1, 1)
+ON CONFLICT (user_id) DO UPDATE SET version = user_token_version.version + 1
+RETURNING version`
+
+func (r *refreshTokensRepository) IncrementTokenVersion(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	userId uuid.UUID,
+) (int64, error) {
+	args := []any{userId}
+	r.log.Debug(ctx, "executing query", slog.String("query", incrementTokenVersionQuery), slog.Any("args", args))
+	var version int64
+	err := uow.Tx().QueryRow(ctx, incrementTokenVersionQuery, args...).Scan(&version)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return 0, shared.ErrNotFound
+	}
+	return version, err
+}
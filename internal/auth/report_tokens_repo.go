@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+type reportTokensRepository struct {
+	log *logger.Logger
+}
+
+func newReportTokensRepository(log *logger.Logger) *reportTokensRepository {
+	return &reportTokensRepository{
+		log: log,
+	}
+}
+
+// Записи нужны только до истечения срока действия токена
+const purgeUsedReportTokensQuery = `DELETE FROM used_report_token WHERE expires_at < now()`
+
+const useReportTokenQuery = `INSERT INTO used_report_token (id, expires_at) VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING`
+
+func (r *reportTokensRepository) UseReportToken(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	id uuid.UUID,
+	expiresAt time.Time,
+) (bool, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", purgeUsedReportTokensQuery))
+	if _, err := uow.Tx().Exec(ctx, purgeUsedReportTokensQuery); err != nil {
+		return false, err
+	}
+	args := []any{id, expiresAt}
+	r.log.Debug(ctx, "executing query", slog.String("query", useReportTokenQuery), slog.Any("args", args))
+	cmd, err := uow.Tx().Exec(ctx, useReportTokenQuery, args...)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() == 1, nil
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRiskRule = errors.New("invalid risk rule")
+
+type RiskDecision int
+
+// Решения упорядочены по строгости
+const (
+	RiskAllow RiskDecision = iota
+	RiskWarn
+	RiskStepUp
+	RiskDeny
+)
+
+var riskDecisions = map[string]RiskDecision{
+	"allow":   RiskAllow,
+	"warn":    RiskWarn,
+	"step_up": RiskStepUp,
+	"deny":    RiskDeny,
+}
+
+func (d RiskDecision) String() string {
+	for name, decision := range riskDecisions {
+		if decision == d {
+			return name
+		}
+	}
+	return fmt.Sprintf("RiskDecision(%d)", int(d))
+}
+
+type RiskSignal string
+
+const (
+	IpChangeSignal        RiskSignal = "ip_change"
+	SubnetChangeSignal    RiskSignal = "subnet_change"
+	AsnChangeSignal       RiskSignal = "asn_change"
+	CountryChangeSignal   RiskSignal = "country_change"
+	UserAgentChangeSignal RiskSignal = "user_agent_change"
+	InactivitySignal      RiskSignal = "inactivity"
+)
+
+// Сведения о ротации пары, по которым оценивается риск
+type RiskSignals struct {
+	PreviousIpAddress string
+	IpAddress         string
+	PreviousUserAgent string
+	UserAgent         string
+	SinceLastRefresh  time.Duration
+}
+
+type RiskAssessment struct {
+	Decision RiskDecision
+	// Сигналы, которые привели к решению
+	Reasons []RiskSignal
+	// Заполняются, если для оценки потребовалось определить местоположение
+	PreviousLocation Location
+	Location         Location
+}
+
+func (a RiskAssessment) reasons() string {
+	return strings.Join(a.reasonsList(), ", ")
+}
+
+func (a RiskAssessment) reasonsList() []string {
+	reasons := make([]string, 0, len(a.Reasons))
+	for _, r := range a.Reasons {
+		reasons = append(reasons, string(r))
+	}
+	return reasons
+}
+
+type RiskPolicy interface {
+	Evaluate(ctx context.Context, signals RiskSignals) (RiskAssessment, error)
+}
+
+type Location struct {
+	Country string
+	Asn     uint32
+}
+
+func (l Location) String() string {
+	switch {
+	case l.Country != "" && l.Asn != 0:
+		return fmt.Sprintf("%s, AS%d", l.Country, l.Asn)
+	case l.Country != "":
+		return l.Country
+	case l.Asn != 0:
+		return fmt.Sprintf("AS%d", l.Asn)
+	}
+	return ""
+}
+
+// Необходим для сигналов `asn_change` и `country_change`,
+// если задан, местоположение попадает и в предупреждения
+type GeoLocator interface {
+	Locate(ctx context.Context, ipAddress string) (Location, error)
+	Capabilities() GeoCapabilities
+}
+
+// Поля `Location`, которые локатор способен заполнить
+type GeoCapabilities struct {
+	Country bool
+	Asn     bool
+}
+
+type RiskRule struct {
+	Signal   RiskSignal `yaml:"signal"`
+	Decision string     `yaml:"decision"`
+	// Для `inactivity`: минимальное время с последнего обновления
+	After time.Duration `yaml:"after"`
+	// Для `subnet_change`: длины префиксов, по умолчанию /24 и /48
+	Ipv4Prefix int `yaml:"ipv4_prefix"`
+	Ipv6Prefix int `yaml:"ipv6_prefix"`
+}
+
+// Сохраняет прежнее поведение: смена ip-адреса приводит к предупреждению
+var DefaultRiskRules = []RiskRule{
+	{Signal: IpChangeSignal, Decision: "warn"},
+}
+
+type rulesRiskPolicy struct {
+	rules []riskRule
+	geo   GeoLocator
+}
+
+type riskRule struct {
+	RiskRule
+	decision RiskDecision
+}
+
+func newRiskPolicy(rules []RiskRule, geo GeoLocator) (*rulesRiskPolicy, error) {
+	if len(rules) == 0 {
+		rules = DefaultRiskRules
+	}
+	policy := &rulesRiskPolicy{
+		rules: make([]riskRule, 0, len(rules)),
+		geo:   geo,
+	}
+	for _, r := range rules {
+		decision, ok := riskDecisions[r.Decision]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown decision %q", ErrInvalidRiskRule, r.Decision)
+		}
+		switch r.Signal {
+		case IpChangeSignal, UserAgentChangeSignal:
+		case SubnetChangeSignal:
+			if r.Ipv4Prefix == 0 {
+				r.Ipv4Prefix = 24
+			}
+			if r.Ipv6Prefix == 0 {
+				r.Ipv6Prefix = 48
+			}
+			if r.Ipv4Prefix < 0 || r.Ipv4Prefix > 32 || r.Ipv6Prefix < 0 || r.Ipv6Prefix > 128 {
+				return nil, fmt.Errorf("%w: invalid subnet prefix", ErrInvalidRiskRule)
+			}
+		case InactivitySignal:
+			if r.After <= 0 {
+				return nil, fmt.Errorf("%w: %s requires positive `after`", ErrInvalidRiskRule, r.Signal)
+			}
+		case AsnChangeSignal:
+			if geo == nil || !geo.Capabilities().Asn {
+				return nil, fmt.Errorf("%w: %s requires asn database", ErrInvalidRiskRule, r.Signal)
+			}
+		case CountryChangeSignal:
+			if geo == nil || !geo.Capabilities().Country {
+				return nil, fmt.Errorf("%w: %s requires country database", ErrInvalidRiskRule, r.Signal)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown signal %q", ErrInvalidRiskRule, r.Signal)
+		}
+		policy.rules = append(policy.rules, riskRule{r, decision})
+	}
+	return policy, nil
+}
+
+// Итоговое решение определяется самым строгим из сработавших правил
+func (p *rulesRiskPolicy) Evaluate(ctx context.Context, signals RiskSignals) (RiskAssessment, error) {
+	var previous, current Location
+	if p.geo != nil && signals.PreviousIpAddress != signals.IpAddress {
+		var err error
+		if previous, err = p.geo.Locate(ctx, signals.PreviousIpAddress); err != nil {
+			return RiskAssessment{}, fmt.Errorf("locate previous ip: %w", err)
+		}
+		if current, err = p.geo.Locate(ctx, signals.IpAddress); err != nil {
+			return RiskAssessment{}, fmt.Errorf("locate ip: %w", err)
+		}
+	}
+	assessment := RiskAssessment{
+		Decision:         RiskAllow,
+		PreviousLocation: previous,
+		Location:         current,
+	}
+	for _, r := range p.rules {
+		var matched bool
+		switch r.Signal {
+		case IpChangeSignal:
+			matched = signals.PreviousIpAddress != signals.IpAddress
+		case SubnetChangeSignal:
+			matched = subnetChanged(signals.PreviousIpAddress, signals.IpAddress, r.Ipv4Prefix, r.Ipv6Prefix)
+		// Адрес, которого нет в базе, не считается сменой местоположения
+		case AsnChangeSignal:
+			matched = previous.Asn != 0 && current.Asn != 0 && previous.Asn != current.Asn
+		case CountryChangeSignal:
+			matched = previous.Country != "" && current.Country != "" && previous.Country != current.Country
+		case UserAgentChangeSignal:
+			matched = signals.PreviousUserAgent != signals.UserAgent
+		case InactivitySignal:
+			matched = signals.SinceLastRefresh >= r.After
+		}
+		if !matched {
+			continue
+		}
+		assessment.Reasons = append(assessment.Reasons, r.Signal)
+		if r.decision > assessment.Decision {
+			assessment.Decision = r.decision
+		}
+	}
+	return assessment, nil
+}
+
+// Адреса, которые не удалось разобрать, считаются разными подсетями
+func subnetChanged(previous, current string, ipv4Prefix, ipv6Prefix int) bool {
+	if previous == current {
+		return false
+	}
+	a, err := netip.ParseAddr(previous)
+	if err != nil {
+		return true
+	}
+	b, err := netip.ParseAddr(current)
+	if err != nil {
+		return true
+	}
+	a, b = a.Unmap(), b.Unmap()
+	if a.Is4() != b.Is4() {
+		return true
+	}
+	bits := ipv6Prefix
+	if a.Is4() {
+		bits = ipv4Prefix
+	}
+	pa, err := a.Prefix(bits)
+	if err != nil {
+		return true
+	}
+	pb, err := b.Prefix(bits)
+	if err != nil {
+		return true
+	}
+	return pa != pb
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRiskPolicy(t *testing.T) {
+	policy, err := newRiskPolicy([]RiskRule{
+		{Signal: IpChangeSignal, Decision: "warn"},
+		{Signal: SubnetChangeSignal, Decision: "step_up"},
+		{Signal: UserAgentChangeSignal, Decision: "allow"},
+		{Signal: InactivitySignal, Decision: "deny", After: 24 * time.Hour},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		signals  RiskSignals
+		decision RiskDecision
+		reasons  []RiskSignal
+	}{
+		{
+			name: "should allow refresh without changes",
+			signals: RiskSignals{
+				PreviousIpAddress: "192.0.2.1",
+				IpAddress:         "192.0.2.1",
+			},
+			decision: RiskAllow,
+		},
+		{
+			name: "should warn about ip change within subnet",
+			signals: RiskSignals{
+				PreviousIpAddress: "192.0.2.1",
+				IpAddress:         "192.0.2.2",
+			},
+			decision: RiskWarn,
+			reasons:  []RiskSignal{IpChangeSignal},
+		},
+		{
+			name: "should require step-up for subnet change",
+			signals: RiskSignals{
+				PreviousIpAddress: "192.0.2.1",
+				IpAddress:         "198.51.100.1",
+				UserAgent:         "changed",
+			},
+			decision: RiskStepUp,
+			reasons:  []RiskSignal{IpChangeSignal, SubnetChangeSignal, UserAgentChangeSignal},
+		},
+		{
+			name: "should deny refresh after long inactivity",
+			signals: RiskSignals{
+				PreviousIpAddress: "192.0.2.1",
+				IpAddress:         "192.0.2.1",
+				SinceLastRefresh:  48 * time.Hour,
+			},
+			decision: RiskDeny,
+			reasons:  []RiskSignal{InactivitySignal},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assessment, err := policy.Evaluate(context.Background(), c.signals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assessment.Decision != c.decision {
+				t.Errorf("expected decision %s, got %s", c.decision, assessment.Decision)
+			}
+			if !slices.Equal(assessment.Reasons, c.reasons) {
+				t.Errorf("expected reasons %v, got %v", c.reasons, assessment.Reasons)
+			}
+		})
+	}
+}
+
+func TestRiskPolicyValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		rule RiskRule
+	}{
+		{
+			name: "should reject unknown decision",
+			rule: RiskRule{Signal: IpChangeSignal, Decision: "block"},
+		},
+		{
+			name: "should reject unknown signal",
+			rule: RiskRule{Signal: "moon_phase", Decision: "warn"},
+		},
+		{
+			name: "should require inactivity threshold",
+			rule: RiskRule{Signal: InactivitySignal, Decision: "warn"},
+		},
+		{
+			name: "should require geo locator for country change",
+			rule: RiskRule{Signal: CountryChangeSignal, Decision: "warn"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := newRiskPolicy([]RiskRule{c.rule}, nil); !errors.Is(err, ErrInvalidRiskRule) {
+				t.Errorf("expected invalid rule error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should require asn database for asn change", func(t *testing.T) {
+		geo := countryOnlyGeoLocator{}
+		if _, err := newRiskPolicy([]RiskRule{{Signal: AsnChangeSignal, Decision: "warn"}}, geo); !errors.Is(err, ErrInvalidRiskRule) {
+			t.Errorf("expected invalid rule error, got %v", err)
+		}
+		if _, err := newRiskPolicy([]RiskRule{{Signal: CountryChangeSignal, Decision: "warn"}}, geo); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+type stubGeoLocator map[string]Location
+
+func (l stubGeoLocator) Locate(ctx context.Context, ipAddress string) (Location, error) {
+	return l[ipAddress], nil
+}
+
+func (l stubGeoLocator) Capabilities() GeoCapabilities {
+	return GeoCapabilities{Country: true, Asn: true}
+}
+
+type countryOnlyGeoLocator struct{ stubGeoLocator }
+
+func (l countryOnlyGeoLocator) Capabilities() GeoCapabilities {
+	return GeoCapabilities{Country: true}
+}
+
+func TestRiskPolicyGeoSignals(t *testing.T) {
+	geo := stubGeoLocator{
+		"192.0.2.1":    {Country: "RU", Asn: 1},
+		"192.0.2.2":    {Country: "RU", Asn: 2},
+		"198.51.100.1": {Country: "DE", Asn: 2},
+	}
+	cases := []struct {
+		name     string
+		rules    []RiskRule
+		ip       string
+		decision RiskDecision
+		location Location
+	}{
+		{
+			name:     "should warn about asn change",
+			rules:    []RiskRule{{Signal: AsnChangeSignal, Decision: "warn"}},
+			ip:       "192.0.2.2",
+			decision: RiskWarn,
+			location: Location{Country: "RU", Asn: 2},
+		},
+		{
+			name:     "should require step-up for country change",
+			rules:    []RiskRule{{Signal: CountryChangeSignal, Decision: "step_up"}},
+			ip:       "198.51.100.1",
+			decision: RiskStepUp,
+			location: Location{Country: "DE", Asn: 2},
+		},
+		{
+			name:     "should not treat unknown address as country change",
+			rules:    []RiskRule{{Signal: CountryChangeSignal, Decision: "step_up"}, {Signal: AsnChangeSignal, Decision: "warn"}},
+			ip:       "203.0.113.1",
+			decision: RiskAllow,
+		},
+		{
+			name:     "should locate addresses without geo rules",
+			rules:    []RiskRule{{Signal: IpChangeSignal, Decision: "warn"}},
+			ip:       "198.51.100.1",
+			decision: RiskWarn,
+			location: Location{Country: "DE", Asn: 2},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy, err := newRiskPolicy(c.rules, geo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assessment, err := policy.Evaluate(context.Background(), RiskSignals{
+				PreviousIpAddress: "192.0.2.1",
+				IpAddress:         c.ip,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assessment.Decision != c.decision {
+				t.Errorf("expected decision %s, got %s", c.decision, assessment.Decision)
+			}
+			if assessment.Location != c.location {
+				t.Errorf("expected location %v, got %v", c.location, assessment.Location)
+			}
+			if assessment.PreviousLocation != geo["192.0.2.1"] {
+				t.Errorf("unexpected previous location %v", assessment.PreviousLocation)
+			}
+		})
+	}
+}
@@ -1,17 +1,60 @@
 package auth
 
-import "net/http"
+import (
+	"net/http"
+
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
+)
 
 type AuthController interface {
 	Login(w http.ResponseWriter, r *http.Request)
 	Refresh(w http.ResponseWriter, r *http.Request)
+	VerifyRefresh(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+	Introspect(w http.ResponseWriter, r *http.Request)
+	RevokeSessions(w http.ResponseWriter, r *http.Request)
+	Sessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+	JWKS(w http.ResponseWriter, r *http.Request)
+	SecurityReport(w http.ResponseWriter, r *http.Request)
+	SubmitSecurityReport(w http.ResponseWriter, r *http.Request)
 }
 
 func newRouter(
 	authController AuthController,
+	introspectionClients map[string]string,
+	limiter *rateLimiter,
+) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST /login", limiter.login(authController.Login))
+	mux.Handle("POST /refresh", limiter.refresh(authController.Refresh))
+	mux.Handle("POST /refresh/verify", limiter.refresh(authController.VerifyRefresh))
+	mux.HandleFunc("POST /revoke", authController.Revoke)
+	mux.Handle("POST /introspect", http_adapters.BasicAuth(
+		introspectionClients,
+		http.HandlerFunc(authController.Introspect),
+	))
+	mux.HandleFunc("GET /sessions", authController.Sessions)
+	mux.HandleFunc("DELETE /sessions", authController.RevokeSessions)
+	mux.HandleFunc("DELETE /sessions/{id}", authController.RevokeSession)
+	mux.HandleFunc("GET /.well-known/jwks.json", authController.JWKS)
+	mux.HandleFunc("GET /security/report", authController.SecurityReport)
+	mux.HandleFunc("POST /security/report", authController.SubmitSecurityReport)
+	return mux
+}
+
+type AdminController interface {
+	RotateKeys(w http.ResponseWriter, r *http.Request)
+	UserSessions(w http.ResponseWriter, r *http.Request)
+	RevokeUserSessions(w http.ResponseWriter, r *http.Request)
+}
+
+func newAdminRouter(
+	adminController AdminController,
 ) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /login", authController.Login)
-	mux.HandleFunc("POST /refresh", authController.Refresh)
+	mux.HandleFunc("POST /keys/rotate", adminController.RotateKeys)
+	mux.HandleFunc("GET /users/{id}/sessions", adminController.UserSessions)
+	mux.HandleFunc("DELETE /users/{id}/sessions", adminController.RevokeUserSessions)
 	return mux
 }
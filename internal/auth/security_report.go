@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrFailedToReport = errors.New("failed to report activity")
+var ErrReportLinkUsed = errors.New("report link already used")
+
+// Отличает токены ссылок от Access и Refresh токенов, подписанных тем же ключом
+const reportAudience = "security_report"
+
+const SecurityReportPath = "/auth/security/report"
+
+type ReportScope string
+
+const (
+	// Завершает сессию, в которой произошло событие
+	ReportDeviceScope ReportScope = "device"
+	ReportAllScope    ReportScope = "all"
+)
+
+type ReportTokensRepository[T any] interface {
+	// Отмечает токен использованным, возвращает false при повторном использовании
+	UseReportToken(ctx context.Context, uow unit_of_work.UnitOfWork[T], id uuid.UUID, expiresAt time.Time) (bool, error)
+}
+
+// Ссылка "это был не я" из предупреждения
+type ReportLink struct {
+	Id     uuid.UUID
+	UserId uuid.UUID
+	// Семейство Refresh токенов сессии, нулевое значение
+	// если сессия уже отозвана
+	FamilyId  uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Возвращает пустую строку, если не задан публичный адрес сервиса
+func (s *service[T]) reportUrl(ctx context.Context, userId uuid.UUID, familyId uuid.UUID) string {
+	if s.cfg.PublicUrl == "" {
+		return ""
+	}
+	now := s.now()
+	claims := jwt.MapClaims{
+		"aud": reportAudience,
+		"sub": userId,
+		"jti": uuid.New(),
+		"iat": now.Unix(),
+		"exp": now.Add(s.cfg.ReportLinkTTL).Unix(),
+	}
+	if familyId != uuid.Nil {
+		claims["fam"] = familyId.String()
+	}
+	token, err := s.keyring.Sign(claims)
+	if err != nil {
+		s.log.Error(ctx, "failed to sign report link", slog.String("user_id", userId.String()), sl.Err(err))
+		return ""
+	}
+	return s.cfg.PublicUrl + SecurityReportPath + "?token=" + url.QueryEscape(token)
+}
+
+// Проверяет подпись и срок действия ссылки, не отмечая ее использованной
+func (s *service[T]) ReportLink(ctx context.Context, token string) (ReportLink, *shared.DomainError) {
+	parsed, err := jwt.Parse(
+		token,
+		s.keyring.KeyFunc,
+		jwt.WithAudience(reportAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return ReportLink{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w: %s", ErrFailedToReport, ErrInvalidToken, err),
+			"link is invalid or expired",
+		)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	link := ReportLink{FamilyId: parseFamilyId(claims)}
+	if link.Id, err = uuidClaim(claims, "jti"); err == nil {
+		link.UserId, err = uuidClaim(claims, "sub")
+	}
+	if err != nil {
+		return ReportLink{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w: %s", ErrFailedToReport, ErrInvalidToken, err),
+			"link is invalid or expired",
+		)
+	}
+	exp, _ := claims.GetExpirationTime()
+	link.ExpiresAt = exp.Time
+	return link, nil
+}
+
+// Ссылка одноразовая, повторный переход не отзывает сессии, созданные после первого
+func (s *service[T]) Report(ctx context.Context, token string, scope ReportScope, client Client) *shared.DomainError {
+	link, dErr := s.ReportLink(ctx, token)
+	if dErr != nil {
+		return dErr
+	}
+	if link.FamilyId == uuid.Nil {
+		scope = ReportAllScope
+	}
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToReport, err),
+			"failed to revoke sessions",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	fresh, err := s.reportTokensRepo.UseReportToken(ctx, uow, link.Id, link.ExpiresAt)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: use report token: %s", ErrFailedToReport, err),
+			"failed to revoke sessions",
+		)
+	}
+	if !fresh {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToReport, ErrReportLinkUsed),
+			"link has already been used",
+		)
+	}
+	var revoked int64
+	switch scope {
+	case ReportDeviceScope:
+		revoked, err = s.refreshTokensRepo.DeleteTokenFamily(ctx, uow, link.UserId, link.FamilyId)
+	default:
+		if revoked, err = s.refreshTokensRepo.DeleteUserTokens(ctx, uow, link.UserId); err == nil {
+			_, err = s.refreshTokensRepo.IncrementTokenVersion(ctx, uow, link.UserId)
+		}
+	}
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: revoke %s: %s", ErrFailedToReport, scope, err),
+			"failed to revoke sessions",
+		)
+	}
+	if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+		audit.ReportEvent, audit.SuccessOutcome, link.UserId, nil, client, string(scope),
+	)); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToReport, err),
+			"failed to revoke sessions",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToReport, err),
+			"failed to revoke sessions",
+		)
+	}
+	s.log.Warn(
+		ctx,
+		"suspicious activity reported",
+		slog.String("user_id", link.UserId.String()),
+		slog.String("scope", string(scope)),
+		slog.Int64("revoked", revoked),
+	)
+	return nil
+}
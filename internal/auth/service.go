@@ -8,12 +8,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/audit"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
 	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 	"github.com/x0k/medods-authentication-service/internal/shared"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -21,17 +26,72 @@ import (
 var ErrFailedToAuthenticate = errors.New("failed to authenticate")
 var ErrFailedToIssueTokens = errors.New("failed to issue tokens")
 var ErrFailedToRefreshTokens = errors.New("failed to refresh tokens")
+var ErrRefreshTokenExpired = errors.New("refresh token expired")
+var ErrSessionExpired = errors.New("session expired")
+var ErrSessionRevoked = errors.New("session revoked")
+var ErrFailedToRevokeToken = errors.New("failed to revoke token")
+var ErrFailedToIntrospectToken = errors.New("failed to introspect token")
+var ErrAccessTokenRevoked = errors.New("access token revoked")
+var ErrFailedToRevokeSessions = errors.New("failed to revoke sessions")
+var ErrFailedToListSessions = errors.New("failed to list sessions")
+var ErrFailedToRevokeSession = errors.New("failed to revoke session")
+var ErrDeviceMismatch = errors.New("device mismatch")
+var ErrRefreshDenied = errors.New("refresh denied")
+var ErrStepUpRequired = errors.New("step-up verification required")
 
 type DeviceId = [32]byte
 
+type TokenRecord struct {
+	TokenHash []byte
+	ExpiresAt time.Time
+	// Сохраняется при ротации пары, сбрасывается только при входе
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	UserAgent        string
+}
+
+// Сведения о клиенте, от имени которого выполняется запрос
+type Client struct {
+	IpAddress string
+	UserAgent string
+	// Необязательный идентификатор устройства, предоставленный клиентом
+	DeviceId string
+	// Предпочитаемый язык из `Accept-Language` для сообщений пользователю
+	Locale string
+}
+
+type SessionRecord struct {
+	// Идентификатор семейства Refresh токенов
+	Id       uuid.UUID
+	DeviceId DeviceId
+	// Первый вход с устройства, в отличие от начала сессии
+	// не сбрасывается при повторном входе
+	CreatedAt        time.Time
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	LastIpAddress    string
+	UserAgent        string
+	RefreshCount     int64
+}
+
 type RefreshTokensRepository[T any] interface {
-	UpsertTokenHash(ctx context.Context, userId uuid.UUID, deviceId DeviceId, tokenHash []byte) error
-	TokenHash(
+	UpsertTokenHash(
 		ctx context.Context,
 		uow unit_of_work.UnitOfWork[T],
 		userId uuid.UUID,
 		deviceId DeviceId,
-	) ([]byte, error)
+		tokenHash []byte,
+		expiresAt time.Time,
+		sessionStartedAt time.Time,
+		familyId uuid.UUID,
+		client Client,
+	) error
+	TokenRecord(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		userId uuid.UUID,
+		deviceId DeviceId,
+	) (TokenRecord, error)
 	UpdateTokenHash(
 		ctx context.Context,
 		uow unit_of_work.UnitOfWork[T],
@@ -39,105 +99,251 @@ type RefreshTokensRepository[T any] interface {
 		oldDeviceId DeviceId,
 		newDeviceId DeviceId,
 		tokenHash []byte,
+		expiresAt time.Time,
+		usedAt time.Time,
+		client Client,
+	) error
+	DeleteTokenFamily(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		userId uuid.UUID,
+		familyId uuid.UUID,
+	) (int64, error)
+	DeleteTokenHash(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		userId uuid.UUID,
+		deviceId DeviceId,
 	) error
+	RevokeAccessToken(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		jti uuid.UUID,
+		expiresAt time.Time,
+	) error
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+	Sessions(ctx context.Context, userId uuid.UUID) ([]SessionRecord, error)
+	DeleteUserTokens(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID) (int64, error)
+	// Токены с версией меньше текущей считаются отозванными
+	TokenVersion(ctx context.Context, userId uuid.UUID) (int64, error)
+	// Возвращает `shared.ErrNotFound`, если пользователя нет
+	IncrementTokenVersion(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID) (int64, error)
 }
 
 type UsersRepository interface {
 	UserExists(ctx context.Context, id uuid.UUID) (bool, error)
 }
 
+// Предупреждения доставляются через `outbox.Outbox`
 type MessagesSender interface {
-	SendWarning(ctx context.Context, userId uuid.UUID, message string) error
+	SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error
 }
 
 type service[T any] struct {
 	log               *logger.Logger
-	secret            []byte
+	cfg               *Config
+	keyring           *Keyring
 	usersRepo         UsersRepository
 	refreshTokensRepo RefreshTokensRepository[T]
+	challengesRepo    ChallengesRepository[T]
+	reportTokensRepo  ReportTokensRepository[T]
+	auditLog          audit.AuditLog[T]
+	outbox            outbox.Outbox[T]
 	sender            MessagesSender
 	uowFactory        unit_of_work.Factory[T]
+	deviceIdentifier  DeviceIdentifier
+	riskPolicy        RiskPolicy
+	now               func() time.Time
+}
+
+type Tokens struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
+}
+
+// Сессия начинается при входе и сохраняется при ротации пары
+type session struct {
+	startedAt time.Time
+	// Все Refresh токены выданные в рамках сессии
+	familyId uuid.UUID
+	// Версия токенов пользователя на момент выдачи
+	tokenVersion int64
+	deviceId     DeviceId
+	// Идентификатор предоставлен клиентом и привязывается к токенам
+	deviceBound bool
 }
 
 type tokens struct {
 	accessToken           string
 	refreshToken          string
 	hashOfAccessTokenHash []byte
+	issuedAt              time.Time
+	refreshTokenExpiresAt time.Time
 }
 
 func newService[T any](
 	log *logger.Logger,
-	secret []byte,
+	cfg *Config,
+	keyring *Keyring,
 	usersRepo UsersRepository,
 	refreshTokensRepo RefreshTokensRepository[T],
+	challengesRepo ChallengesRepository[T],
+	reportTokensRepo ReportTokensRepository[T],
+	auditLog audit.AuditLog[T],
+	outbox outbox.Outbox[T],
 	sender MessagesSender,
 	uowFactory unit_of_work.Factory[T],
+	deviceIdentifier DeviceIdentifier,
+	riskPolicy RiskPolicy,
+	now func() time.Time,
 ) *service[T] {
 	return &service[T]{
 		log:               log,
-		secret:            secret,
+		cfg:               cfg,
+		keyring:           keyring,
 		usersRepo:         usersRepo,
 		refreshTokensRepo: refreshTokensRepo,
+		challengesRepo:    challengesRepo,
+		reportTokensRepo:  reportTokensRepo,
+		auditLog:          auditLog,
+		outbox:            outbox,
 		sender:            sender,
 		uowFactory:        uowFactory,
+		deviceIdentifier:  deviceIdentifier,
+		riskPolicy:        riskPolicy,
+		now:               now,
 	}
 }
 
-func (s *service[T]) IssueTokens(ctx context.Context, userId uuid.UUID, ipAddress string) (string, string, *shared.DomainError) {
+func (s *service[T]) IssueTokens(ctx context.Context, userId uuid.UUID, client Client) (Tokens, *shared.DomainError) {
+	ipAddress := client.IpAddress
 	if err := s.userExists(ctx, userId); err != nil {
+		if err.Expected {
+			s.recordEvent(ctx, s.auditEvent(audit.LoginEvent, audit.FailureOutcome, userId, nil, client, "user not found"))
+		}
 		err.Err = fmt.Errorf("%w: %s", ErrFailedToIssueTokens, err.Err)
-		return "", "", err
+		return Tokens{}, err
 	}
-	tokens, err := s.issueTokens(userId, ipAddress)
+	// В задании отсутствует информация об ограничениях на количество токенов для одного пользователя.
+	// Будем считать что один пользователь может иметь по одному Refresh токену на устройство.
+	deviceId, deviceBound, dErr := s.deviceIdentifier.Identify(client)
+	if dErr != nil {
+		s.recordEvent(ctx, s.auditEvent(audit.LoginEvent, audit.FailureOutcome, userId, nil, client, "invalid device id"))
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %s", ErrFailedToIssueTokens, dErr),
+			"invalid device id",
+		)
+	}
+	tokenVersion, vErr := s.refreshTokensRepo.TokenVersion(ctx, userId)
+	if vErr != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: token version: %s", ErrFailedToIssueTokens, vErr),
+			"failed to get user info",
+		)
+	}
+	session := session{
+		startedAt:    s.now(),
+		familyId:     uuid.New(),
+		tokenVersion: tokenVersion,
+		deviceId:     deviceId,
+		deviceBound:  deviceBound,
+	}
+	tokens, err := s.issueTokens(userId, ipAddress, session)
 	if err != nil {
-		return "", "", err
+		return Tokens{}, err
 	}
-	// В задании отсутствует информация об ограничениях на количество токенов для одного пользователя.
-	// Будем считать что один пользователь может иметь по одному Refresh токену на устройство.
-	// За неимением другой информации в качестве id устройства будем использовать ip-адрес.
-	deviceId := sha256.Sum256([]byte(ipAddress))
-	if err := s.refreshTokensRepo.UpsertTokenHash(ctx, userId, deviceId, tokens.hashOfAccessTokenHash); err != nil {
-		return "", "", shared.NewUnexpectedError(
+	uow, uErr := s.uowFactory(ctx)
+	if uErr != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToIssueTokens, uErr),
+			"failed to persist token",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	if err := s.refreshTokensRepo.UpsertTokenHash(
+		ctx,
+		uow,
+		userId,
+		deviceId,
+		tokens.hashOfAccessTokenHash,
+		tokens.refreshTokenExpiresAt,
+		session.startedAt,
+		session.familyId,
+		client,
+	); err != nil {
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: save token hash: %s", ErrFailedToIssueTokens, err),
 			"failed to persist token",
 		)
 	}
-	return tokens.accessToken, tokens.refreshToken, nil
+	if err := s.auditLog.Record(
+		ctx,
+		uow,
+		s.auditEvent(audit.LoginEvent, audit.SuccessOutcome, userId, deviceId[:], client, ""),
+	); err != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToIssueTokens, err),
+			"failed to persist token",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToIssueTokens, err),
+			"failed to persist token",
+		)
+	}
+	return s.result(tokens), nil
 }
 
 func (s *service[T]) Refresh(
 	ctx context.Context,
 	accessTokenString string,
 	refreshTokenString string,
-	ipAddress string,
-) (string, string, *shared.DomainError) {
-	accessToken, err := jwt.Parse(accessTokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("%w: unexpected signing method: %s", ErrFailedToRefreshTokens, t.Header["alg"])
-		}
-		return s.secret, nil
-	})
+	client Client,
+) (Tokens, *shared.DomainError) {
+	return s.refresh(ctx, accessTokenString, refreshTokenString, client, false)
+}
+
+// Подтвержденное одноразовым кодом обновление не требует повторного подтверждения
+func (s *service[T]) refresh(
+	ctx context.Context,
+	accessTokenString string,
+	refreshTokenString string,
+	client Client,
+	verified bool,
+) (Tokens, *shared.DomainError) {
+	ipAddress := client.IpAddress
+	// Срок действия Access токена не проверяется, т.к. обновление
+	// пары после его истечения и является назначением Refresh токена.
+	// Срок действия самой пары ограничен Refresh токеном.
+	accessToken, err := jwt.Parse(accessTokenString, s.keyring.KeyFunc, jwt.WithoutClaimsValidation())
 	if err != nil {
-		return "", "", shared.NewDomainError(
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: access token: %s", ErrFailedToRefreshTokens, err),
 			"invalid access token",
 		)
 	}
 	decodedRefreshTokenString, err := base64.URLEncoding.DecodeString(refreshTokenString)
 	if err != nil {
-		return "", "", shared.NewDomainError(
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: refresh token: %s", ErrFailedToRefreshTokens, err),
 			"invalid refresh token encoding",
 		)
 	}
-	refreshToken, err := jwt.Parse(string(decodedRefreshTokenString), func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("%w: unexpected signing method: %s", ErrFailedToRefreshTokens, t.Header["alg"])
-		}
-		return s.secret, nil
-	})
+	refreshToken, err := jwt.Parse(
+		string(decodedRefreshTokenString),
+		s.keyring.KeyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
 	if err != nil {
-		return "", "", shared.NewDomainError(
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: refresh token: %s", ErrFailedToRefreshTokens, err),
 			"invalid refresh token",
 		)
@@ -146,16 +352,17 @@ func (s *service[T]) Refresh(
 	accessTokenHash := sha256.Sum256([]byte(accessTokenString))
 	accessTokenHashSlice := accessTokenHash[:]
 	refreshTokenClaims := refreshToken.Claims.(jwt.MapClaims)
+	familyId := parseFamilyId(refreshTokenClaims)
 	base64EncodedAccessTokenHash := refreshTokenClaims["sub"].(string)
 	accessTokenHashFromRefreshToken, err := base64.URLEncoding.DecodeString(base64EncodedAccessTokenHash)
 	if err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: access token hash: %s", ErrFailedToRefreshTokens, err),
 			"failed to decode access token",
 		)
 	}
 	if !bytes.Equal(accessTokenHashSlice, accessTokenHashFromRefreshToken) {
-		return "", "", shared.NewDomainError(
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: tokens mismatch", ErrFailedToRefreshTokens),
 			"tokens mismatch",
 		)
@@ -163,13 +370,40 @@ func (s *service[T]) Refresh(
 	userId := uuid.MustParse(accessTokenClaims["sub"].(string))
 	if err := s.userExists(ctx, userId); err != nil {
 		err.Err = fmt.Errorf("%w: %s", ErrFailedToRefreshTokens, err.Err)
-		return "", "", err
+		return Tokens{}, err
 	}
 	oldIpAddress := accessTokenClaims["ip"].(string)
-	oldDeviceId := sha256.Sum256([]byte(oldIpAddress))
+	oldDeviceId, deviceBound, err := deviceIdClaim(refreshTokenClaims, oldIpAddress)
+	if err != nil {
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: refresh token: %s", ErrFailedToRefreshTokens, err),
+			"invalid refresh token",
+		)
+	}
+	newDeviceId, newDeviceBound, err := s.deviceIdentifier.Identify(client)
+	if err != nil {
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %s", ErrFailedToRefreshTokens, err),
+			"invalid device id",
+		)
+	}
+	// Токены привязанные к устройству нельзя использовать с другого устройства
+	if deviceBound && newDeviceId != oldDeviceId {
+		s.log.Warn(
+			ctx,
+			"device mismatch",
+			slog.String("user_id", userId.String()),
+			slog.String("ip", ipAddress),
+		)
+		s.recordEvent(ctx, s.auditEvent(audit.AnomalyEvent, audit.DeniedOutcome, userId, oldDeviceId[:], client, "device mismatch"))
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToRefreshTokens, ErrDeviceMismatch),
+			"device mismatch",
+		)
+	}
 	uow, err := s.uowFactory(ctx)
 	if err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: create unit of work: %s", ErrFailedToRefreshTokens, err),
 			"failed to persist token",
 		)
@@ -179,103 +413,866 @@ func (s *service[T]) Refresh(
 			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
 		}
 	}()
-	tokenHash, err := s.refreshTokensRepo.TokenHash(ctx, uow, userId, oldDeviceId)
+	record, err := s.refreshTokensRepo.TokenRecord(ctx, uow, userId, oldDeviceId)
 	if errors.Is(err, shared.ErrNotFound) {
+		if err := s.rejectRevokedSession(ctx, uow, userId, oldDeviceId, accessTokenClaims, client); err != nil {
+			return Tokens{}, err
+		}
 		s.log.Warn(
 			ctx,
 			"refresh token reuse attempt",
 			slog.String("user_id", userId.String()),
 			slog.String("ip", ipAddress),
 		)
-		return "", "", shared.NewDomainError(
+		// Строка могла быть перенесена на другое устройство при смене ip-адреса
+		s.revokeFamily(ctx, uow, userId, familyId, oldDeviceId, client, "refresh token reuse attempt")
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: token hash not found", ErrFailedToRefreshTokens),
 			"invalid refresh token",
 		)
 	}
 	if err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: get token hash: %s", ErrFailedToRefreshTokens, err),
 			"failed to check refresh token",
 		)
 	}
-	err = bcrypt.CompareHashAndPassword(tokenHash, accessTokenHashSlice)
+	if !s.now().Before(record.ExpiresAt) {
+		s.recordAndCommit(ctx, uow, s.auditEvent(
+			audit.RefreshEvent, audit.FailureOutcome, userId, oldDeviceId[:], client, "refresh token expired",
+		))
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToRefreshTokens, ErrRefreshTokenExpired),
+			"refresh token expired",
+		)
+	}
+	if err := s.checkSession(record); err != nil {
+		s.log.Info(
+			ctx,
+			"session expired",
+			slog.String("user_id", userId.String()),
+			slog.Time("session_started_at", record.SessionStartedAt),
+			slog.Time("last_used_at", record.LastUsedAt),
+			sl.Err(err),
+		)
+		s.recordAndCommit(ctx, uow, s.auditEvent(
+			audit.RefreshEvent, audit.FailureOutcome, userId, oldDeviceId[:], client, err.Error(),
+		))
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToRefreshTokens, err),
+			"session expired",
+		)
+	}
+	err = bcrypt.CompareHashAndPassword(record.TokenHash, accessTokenHashSlice)
 	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if err := s.rejectRevokedSession(ctx, uow, userId, oldDeviceId, accessTokenClaims, client); err != nil {
+			return Tokens{}, err
+		}
 		s.log.Warn(
 			ctx,
 			"stale tokens pair reuse attempt",
 			slog.String("user_id", userId.String()),
 			slog.String("ip", ipAddress),
 		)
-		return "", "", shared.NewDomainError(
+		s.revokeFamily(ctx, uow, userId, familyId, oldDeviceId, client, "stale tokens pair reuse attempt")
+		return Tokens{}, shared.NewDomainError(
 			fmt.Errorf("%w: compare hash: %s", ErrFailedToRefreshTokens, err),
 			"invalid refresh token",
 		)
 	}
 	if err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: compare hash: %s", ErrFailedToRefreshTokens, err),
 			"failed to check refresh token",
 		)
 	}
-	tokens, dErr := s.issueTokens(userId, ipAddress)
-	if dErr != nil {
-		dErr.Err = fmt.Errorf("%w: %s", ErrFailedToRefreshTokens, dErr.Err)
-		return "", "", dErr
+	assessment, err := s.riskPolicy.Evaluate(ctx, RiskSignals{
+		PreviousIpAddress: oldIpAddress,
+		IpAddress:         ipAddress,
+		PreviousUserAgent: record.UserAgent,
+		UserAgent:         client.UserAgent,
+		SinceLastRefresh:  s.now().Sub(record.LastUsedAt),
+	})
+	if err != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: evaluate risk: %s", ErrFailedToRefreshTokens, err),
+			"failed to check refresh",
+		)
 	}
-	var message string
-	if oldIpAddress != ipAddress {
+	if assessment.Decision != RiskAllow {
 		s.log.Warn(
 			ctx,
-			"ip mismatch",
+			"risky refresh",
 			slog.String("user_id", userId.String()),
+			slog.String("decision", assessment.Decision.String()),
+			slog.String("reasons", assessment.reasons()),
 			slog.String("old_ip", oldIpAddress),
 			slog.String("new_ip", ipAddress),
 		)
-		newDeviceId := sha256.Sum256([]byte(ipAddress))
-		if err := s.refreshTokensRepo.UpdateTokenHash(
-			ctx,
-			uow,
-			userId,
-			oldDeviceId,
-			newDeviceId,
-			tokens.hashOfAccessTokenHash,
-		); err != nil {
-			return "", "", shared.NewUnexpectedError(
-				fmt.Errorf("%w: replace token hash: %s", ErrFailedToRefreshTokens, err),
-				"failed to persist token",
+	}
+	if assessment.Decision == RiskDeny {
+		s.recordAndCommit(ctx, uow, s.auditEvent(
+			audit.AnomalyEvent, audit.DeniedOutcome, userId, oldDeviceId[:], client, assessment.reasons(),
+		))
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w: %s", ErrFailedToRefreshTokens, ErrRefreshDenied, assessment.reasons()),
+			"refresh denied",
+		)
+	}
+	if assessment.Decision == RiskStepUp && !verified {
+		challenge, code, err := s.createChallenge(ctx, uow, userId, accessTokenHashSlice, record.ExpiresAt)
+		if errors.Is(err, ErrTooManyAttempts) {
+			s.recordAndCommit(ctx, uow, s.auditEvent(
+				audit.RefreshEvent, audit.FailureOutcome, userId, oldDeviceId[:], client, err.Error(),
+			))
+			return Tokens{}, shared.NewDomainError(
+				fmt.Errorf("%w: %w", ErrFailedToRefreshTokens, err),
+				"too many attempts",
 			)
 		}
-		message = fmt.Sprintf("ip mismatch: %s != %s", oldIpAddress, ipAddress)
-	} else if err = s.refreshTokensRepo.UpdateTokenHash(
+		if err != nil {
+			return Tokens{}, shared.NewUnexpectedError(
+				fmt.Errorf("%w: %w: %s", ErrFailedToRefreshTokens, ErrStepUpRequired, err),
+				"failed to create challenge",
+			)
+		}
+		if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+			audit.RefreshEvent, audit.ChallengedOutcome, userId, oldDeviceId[:], client, assessment.reasons(),
+		)); err != nil {
+			return Tokens{}, shared.NewUnexpectedError(
+				fmt.Errorf("%w: record audit event: %s", ErrFailedToRefreshTokens, err),
+				"failed to create challenge",
+			)
+		}
+		if err := uow.Commit(ctx); err != nil {
+			return Tokens{}, shared.NewUnexpectedError(
+				fmt.Errorf("%w: commit unit of work: %s", ErrFailedToRefreshTokens, err),
+				"failed to create challenge",
+			)
+		}
+		// Код действующего подтверждения уже отправлен
+		if code != "" {
+			s.sendVerificationCode(ctx, userId, code)
+		}
+		return Tokens{}, shared.NewDomainError(
+			fmt.Errorf("%w: %w: %w", ErrFailedToRefreshTokens, ErrStepUpRequired, challenge),
+			"additional verification required",
+		)
+	}
+	tokenVersion, err := s.refreshTokensRepo.TokenVersion(ctx, userId)
+	if err != nil {
+		return Tokens{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: token version: %s", ErrFailedToRefreshTokens, err),
+			"failed to get user info",
+		)
+	}
+	tokens, dErr := s.issueTokens(userId, ipAddress, session{
+		startedAt:    record.SessionStartedAt,
+		familyId:     familyId,
+		tokenVersion: tokenVersion,
+		deviceId:     newDeviceId,
+		deviceBound:  newDeviceBound,
+	})
+	if dErr != nil {
+		dErr.Err = fmt.Errorf("%w: %s", ErrFailedToRefreshTokens, dErr.Err)
+		return Tokens{}, dErr
+	}
+	// При идентификации по ip-адресу строка переносится на новое устройство
+	if err = s.refreshTokensRepo.UpdateTokenHash(
 		ctx,
 		uow,
 		userId,
 		oldDeviceId,
-		oldDeviceId,
+		newDeviceId,
 		tokens.hashOfAccessTokenHash,
+		tokens.refreshTokenExpiresAt,
+		tokens.issuedAt,
+		client,
 	); err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: save token hash: %s", ErrFailedToRefreshTokens, err),
 			"failed to persist token",
 		)
 	}
+	events := []audit.Event{
+		s.auditEvent(audit.RefreshEvent, audit.SuccessOutcome, userId, newDeviceId[:], client, ""),
+	}
+	if verified {
+		events[0].Reason = "verified"
+	}
+	if assessment.Decision == RiskWarn {
+		events = append(events, s.auditEvent(
+			audit.AnomalyEvent, audit.SuccessOutcome, userId, newDeviceId[:], client, assessment.reasons(),
+		))
+	}
+	for _, event := range events {
+		if err := s.auditLog.Record(ctx, uow, event); err != nil {
+			return Tokens{}, shared.NewUnexpectedError(
+				fmt.Errorf("%w: record audit event: %s", ErrFailedToRefreshTokens, err),
+				"failed to persist token",
+			)
+		}
+	}
+	if assessment.Decision == RiskWarn {
+		if err := s.enqueueWarning(ctx, uow, userId, messages_sender.Warning{
+			Event:             messages_sender.SuspiciousRefreshWarning,
+			Time:              s.now(),
+			Locale:            client.Locale,
+			IpAddress:         ipAddress,
+			PreviousIpAddress: oldIpAddress,
+			Location:          assessment.Location.String(),
+			PreviousLocation:  assessment.PreviousLocation.String(),
+			Device:            client.UserAgent,
+			Reasons:           assessment.reasonsList(),
+			ReportUrl:         s.reportUrl(ctx, userId, familyId),
+		}); err != nil {
+			return Tokens{}, shared.NewUnexpectedError(
+				fmt.Errorf("%w: enqueue warning: %s", ErrFailedToRefreshTokens, err),
+				"failed to persist token",
+			)
+		}
+	}
 	if err = uow.Commit(ctx); err != nil {
-		return "", "", shared.NewUnexpectedError(
+		return Tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToRefreshTokens, err),
 			"failed to persist token",
 		)
 	}
-	if message != "" {
-		if err = s.sender.SendWarning(ctx, userId, message); err != nil {
-			s.log.Error(
-				ctx,
-				"failed to send warning",
-				slog.String("user_id", userId.String()),
-				slog.String("message", message), sl.Err(err),
+	return s.result(tokens), nil
+}
+
+const (
+	AccessTokenHint  = "access_token"
+	RefreshTokenHint = "refresh_token"
+)
+
+type revocationTarget struct {
+	userId               uuid.UUID
+	deviceId             DeviceId
+	accessTokenHash      []byte
+	accessTokenId        uuid.UUID
+	accessTokenExpiresAt time.Time
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7009
+// Отзыв любого токена из пары удаляет Refresh токен устройства (если пара
+// актуальна) и добавляет идентификатор Access токена в список отозванных.
+// Невалидные токены не считаются ошибкой.
+func (s *service[T]) Revoke(
+	ctx context.Context,
+	token string,
+	tokenTypeHint string,
+	client Client,
+) *shared.DomainError {
+	parsers := []func(string) (revocationTarget, error){
+		s.accessTokenRevocationTarget,
+		s.refreshTokenRevocationTarget,
+	}
+	if tokenTypeHint == RefreshTokenHint {
+		slices.Reverse(parsers)
+	}
+	var target revocationTarget
+	var err error
+	for _, parse := range parsers {
+		if target, err = parse(token); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		s.log.Debug(ctx, "ignoring invalid token revocation", sl.Err(err))
+		return nil
+	}
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToRevokeToken, err),
+			"failed to revoke token",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	record, err := s.refreshTokensRepo.TokenRecord(ctx, uow, target.userId, target.deviceId)
+	if err != nil && !errors.Is(err, shared.ErrNotFound) {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: get token hash: %s", ErrFailedToRevokeToken, err),
+			"failed to revoke token",
+		)
+	}
+	if err == nil && bcrypt.CompareHashAndPassword(record.TokenHash, target.accessTokenHash) == nil {
+		if err := s.refreshTokensRepo.DeleteTokenHash(ctx, uow, target.userId, target.deviceId); err != nil &&
+			!errors.Is(err, shared.ErrNotFound) {
+			return shared.NewUnexpectedError(
+				fmt.Errorf("%w: delete token hash: %s", ErrFailedToRevokeToken, err),
+				"failed to revoke token",
 			)
 		}
 	}
-	return tokens.accessToken, tokens.refreshToken, nil
+	if target.accessTokenId != uuid.Nil && s.now().Before(target.accessTokenExpiresAt) {
+		if err := s.refreshTokensRepo.RevokeAccessToken(
+			ctx,
+			uow,
+			target.accessTokenId,
+			target.accessTokenExpiresAt,
+		); err != nil {
+			return shared.NewUnexpectedError(
+				fmt.Errorf("%w: revoke access token: %s", ErrFailedToRevokeToken, err),
+				"failed to revoke token",
+			)
+		}
+	}
+	if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+		audit.RevokeEvent, audit.SuccessOutcome, target.userId, target.deviceId[:], client, "token",
+	)); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToRevokeToken, err),
+			"failed to revoke token",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToRevokeToken, err),
+			"failed to revoke token",
+		)
+	}
+	s.log.Info(
+		ctx,
+		"token revoked",
+		slog.String("user_id", target.userId.String()),
+		slog.String("jti", target.accessTokenId.String()),
+	)
+	return nil
+}
+
+// Срок действия не проверяется, отозвать можно и истекший токен
+func (s *service[T]) accessTokenRevocationTarget(token string) (revocationTarget, error) {
+	accessToken, err := jwt.Parse(token, s.keyring.KeyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	claims := accessToken.Claims.(jwt.MapClaims)
+	userId, err := uuidClaim(claims, "sub")
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	ip, ok := claims["ip"].(string)
+	if !ok {
+		return revocationTarget{}, fmt.Errorf("invalid ip claim")
+	}
+	jti, err := uuidClaim(claims, "jti")
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return revocationTarget{}, fmt.Errorf("invalid exp claim")
+	}
+	deviceId, _, err := deviceIdClaim(claims, ip)
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	hash := sha256.Sum256([]byte(token))
+	return revocationTarget{
+		userId:               userId,
+		deviceId:             deviceId,
+		accessTokenHash:      hash[:],
+		accessTokenId:        jti,
+		accessTokenExpiresAt: exp.Time,
+	}, nil
+}
+
+func (s *service[T]) refreshTokenRevocationTarget(token string) (revocationTarget, error) {
+	decoded, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	refreshToken, err := jwt.Parse(string(decoded), s.keyring.KeyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	claims := refreshToken.Claims.(jwt.MapClaims)
+	userId, err := uuidClaim(claims, "uid")
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	ip, ok := claims["ip"].(string)
+	if !ok {
+		return revocationTarget{}, fmt.Errorf("invalid ip claim")
+	}
+	deviceId, _, err := deviceIdClaim(claims, ip)
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	sub, ok := claims["sub"].(string)
+	if !ok {
+		return revocationTarget{}, fmt.Errorf("invalid sub claim")
+	}
+	accessTokenHash, err := base64.URLEncoding.DecodeString(sub)
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	jti, err := uuidClaim(claims, "ati")
+	if err != nil {
+		return revocationTarget{}, err
+	}
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return revocationTarget{}, fmt.Errorf("invalid iat claim")
+	}
+	return revocationTarget{
+		userId:          userId,
+		deviceId:        deviceId,
+		accessTokenHash: accessTokenHash,
+		accessTokenId:   jti,
+		// Access токен выдается вместе с Refresh токеном
+		accessTokenExpiresAt: iat.Add(s.cfg.AccessTTL),
+	}, nil
+}
+
+type Introspection struct {
+	Active    bool
+	Revoked   bool
+	UserId    uuid.UUID
+	IpAddress string
+	TokenId   uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7662
+// Поддерживаются только Access токены, для остальных `active` будет `false`.
+func (s *service[T]) Introspect(ctx context.Context, token string) (Introspection, *shared.DomainError) {
+	claims, err := s.verifyAccessToken(ctx, token)
+	if errors.Is(err, ErrAccessTokenRevoked) {
+		return Introspection{Revoked: true}, nil
+	}
+	if errors.Is(err, ErrFailedToIntrospectToken) {
+		return Introspection{}, shared.NewUnexpectedError(err, "failed to check token")
+	}
+	if err != nil {
+		s.log.Debug(ctx, "inactive token introspected", sl.Err(err))
+		return Introspection{}, nil
+	}
+	return Introspection{
+		Active:    true,
+		UserId:    claims.userId,
+		IpAddress: claims.ipAddress,
+		TokenId:   claims.tokenId,
+		IssuedAt:  claims.issuedAt,
+		ExpiresAt: claims.expiresAt,
+	}, nil
+}
+
+type Principal struct {
+	UserId    uuid.UUID
+	DeviceId  DeviceId
+	IpAddress string
+	TokenId   uuid.UUID
+	// Нулевое значение для токенов, выданных без `auth_time`
+	AuthTime time.Time
+}
+
+func (s *service[T]) Authenticate(ctx context.Context, accessToken string) (Principal, *shared.DomainError) {
+	claims, err := s.verifyAccessToken(ctx, accessToken)
+	if errors.Is(err, ErrFailedToIntrospectToken) {
+		return Principal{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToAuthenticate, err),
+			"failed to check access token",
+		)
+	}
+	if err != nil {
+		return Principal{}, shared.NewDomainError(
+			fmt.Errorf("%w: %s", ErrFailedToAuthenticate, err),
+			"invalid access token",
+		)
+	}
+	return Principal{
+		UserId:    claims.userId,
+		DeviceId:  claims.deviceId,
+		IpAddress: claims.ipAddress,
+		TokenId:   claims.tokenId,
+		AuthTime:  claims.authTime,
+	}, nil
+}
+
+// Удаляет Refresh токены всех устройств пользователя и повышает версию
+// токенов, чтобы ранее выданные Access токены перестали проходить проверку.
+func (s *service[T]) RevokeSessions(ctx context.Context, userId uuid.UUID, client Client) *shared.DomainError {
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToRevokeSessions, err),
+			"failed to revoke sessions",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	deleted, err := s.refreshTokensRepo.DeleteUserTokens(ctx, uow, userId)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: delete tokens: %s", ErrFailedToRevokeSessions, err),
+			"failed to revoke sessions",
+		)
+	}
+	version, err := s.refreshTokensRepo.IncrementTokenVersion(ctx, uow, userId)
+	if errors.Is(err, shared.ErrNotFound) {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: user not found", ErrFailedToRevokeSessions),
+			"user not found",
+		)
+	}
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: increment token version: %s", ErrFailedToRevokeSessions, err),
+			"failed to revoke sessions",
+		)
+	}
+	if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+		audit.RevokeEvent, audit.SuccessOutcome, userId, nil, client, "all sessions",
+	)); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToRevokeSessions, err),
+			"failed to revoke sessions",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToRevokeSessions, err),
+			"failed to revoke sessions",
+		)
+	}
+	s.log.Info(
+		ctx,
+		"sessions revoked",
+		slog.String("user_id", userId.String()),
+		slog.Int64("deleted", deleted),
+		slog.Int64("token_version", version),
+	)
+	return nil
+}
+
+type Session struct {
+	Id              uuid.UUID
+	CreatedAt       time.Time
+	FirstSeenAt     time.Time
+	LastRefreshedAt time.Time
+	LastIpAddress   string
+	UserAgent       string
+	RefreshCount    int64
+	// Сессия устройства, с которого выполнен запрос
+	Current bool
+}
+
+func (s *service[T]) Sessions(ctx context.Context, principal Principal) ([]Session, *shared.DomainError) {
+	records, err := s.refreshTokensRepo.Sessions(ctx, principal.UserId)
+	if err != nil {
+		return nil, shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToListSessions, err),
+			"failed to list sessions",
+		)
+	}
+	sessions := make([]Session, 0, len(records))
+	for _, r := range records {
+		sessions = append(sessions, Session{
+			Id:              r.Id,
+			CreatedAt:       r.SessionStartedAt,
+			FirstSeenAt:     r.CreatedAt,
+			LastRefreshedAt: r.LastUsedAt,
+			LastIpAddress:   r.LastIpAddress,
+			UserAgent:       r.UserAgent,
+			RefreshCount:    r.RefreshCount,
+			Current:         r.DeviceId == principal.DeviceId,
+		})
+	}
+	return sessions, nil
+}
+
+// Access токены сессии остаются действительными до истечения срока,
+// для их немедленного отзыва следует использовать `RevokeSessions`.
+func (s *service[T]) RevokeSession(
+	ctx context.Context,
+	userId uuid.UUID,
+	sessionId uuid.UUID,
+	client Client,
+) *shared.DomainError {
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToRevokeSession, err),
+			"failed to revoke session",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	deleted, err := s.refreshTokensRepo.DeleteTokenFamily(ctx, uow, userId, sessionId)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: delete token family: %s", ErrFailedToRevokeSession, err),
+			"failed to revoke session",
+		)
+	}
+	if deleted == 0 {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToRevokeSession, shared.ErrNotFound),
+			"session not found",
+		)
+	}
+	if err := s.auditLog.Record(ctx, uow, s.auditEvent(
+		audit.RevokeEvent, audit.SuccessOutcome, userId, nil, client, "session "+sessionId.String(),
+	)); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToRevokeSession, err),
+			"failed to revoke session",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit unit of work: %s", ErrFailedToRevokeSession, err),
+			"failed to revoke session",
+		)
+	}
+	s.log.Info(
+		ctx,
+		"session revoked",
+		slog.String("user_id", userId.String()),
+		slog.String("session_id", sessionId.String()),
+	)
+	return nil
+}
+
+type accessTokenClaims struct {
+	userId    uuid.UUID
+	ipAddress string
+	deviceId  DeviceId
+	tokenId   uuid.UUID
+	issuedAt  time.Time
+	authTime  time.Time
+	expiresAt time.Time
+}
+
+// Проверяет подпись, срок действия и отсутствие токена в списке отозванных.
+// Ошибки хранилища оборачиваются в `ErrFailedToIntrospectToken`.
+func (s *service[T]) verifyAccessToken(ctx context.Context, token string) (accessTokenClaims, error) {
+	accessToken, err := jwt.Parse(
+		token,
+		s.keyring.KeyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return accessTokenClaims{}, err
+	}
+	claims := accessToken.Claims.(jwt.MapClaims)
+	userId, err := uuidClaim(claims, "sub")
+	if err != nil {
+		return accessTokenClaims{}, err
+	}
+	ip, ok := claims["ip"].(string)
+	if !ok {
+		return accessTokenClaims{}, fmt.Errorf("invalid ip claim")
+	}
+	jti, err := uuidClaim(claims, "jti")
+	if err != nil {
+		return accessTokenClaims{}, err
+	}
+	deviceId, _, err := deviceIdClaim(claims, ip)
+	if err != nil {
+		return accessTokenClaims{}, err
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return accessTokenClaims{}, err
+	}
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+	var authTime time.Time
+	if at, ok := claims["auth_time"].(float64); ok {
+		authTime = time.Unix(int64(at), 0)
+	}
+	revoked, err := s.refreshTokensRepo.IsAccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return accessTokenClaims{}, fmt.Errorf("%w: check revocation: %s", ErrFailedToIntrospectToken, err)
+	}
+	if revoked {
+		return accessTokenClaims{}, ErrAccessTokenRevoked
+	}
+	currentVersion, err := s.refreshTokensRepo.TokenVersion(ctx, userId)
+	if err != nil {
+		return accessTokenClaims{}, fmt.Errorf("%w: token version: %s", ErrFailedToIntrospectToken, err)
+	}
+	if tokenVersionClaim(claims) < currentVersion {
+		return accessTokenClaims{}, ErrAccessTokenRevoked
+	}
+	return accessTokenClaims{
+		userId:    userId,
+		ipAddress: ip,
+		deviceId:  deviceId,
+		tokenId:   jti,
+		issuedAt:  issuedAt,
+		authTime:  authTime,
+		expiresAt: exp.Time,
+	}, nil
+}
+
+// Пара, выданная до завершения всех сессий пользователя (самим пользователем
+// или администратором), отсутствует в хранилище законно и не является признаком
+// компрометации. Возвращает `nil`, если версия пары актуальна.
+func (s *service[T]) rejectRevokedSession(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[T],
+	userId uuid.UUID,
+	deviceId DeviceId,
+	accessTokenClaims jwt.MapClaims,
+	client Client,
+) *shared.DomainError {
+	currentVersion, err := s.refreshTokensRepo.TokenVersion(ctx, userId)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: token version: %s", ErrFailedToRefreshTokens, err),
+			"failed to check refresh token",
+		)
+	}
+	if tokenVersionClaim(accessTokenClaims) >= currentVersion {
+		return nil
+	}
+	s.log.Info(
+		ctx,
+		"refresh of revoked session",
+		slog.String("user_id", userId.String()),
+		slog.String("ip", client.IpAddress),
+	)
+	s.recordAndCommit(ctx, uow, s.auditEvent(
+		audit.RefreshEvent, audit.FailureOutcome, userId, deviceId[:], client, "session revoked",
+	))
+	return shared.NewDomainError(
+		fmt.Errorf("%w: %w", ErrFailedToRefreshTokens, ErrSessionRevoked),
+		"session revoked",
+	)
+}
+
+// Повторное использование выведенного из оборота Refresh токена означает
+// компрометацию пары, поэтому отзывается все семейство вместе с текущим
+// токеном законного владельца.
+func (s *service[T]) revokeFamily(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[T],
+	userId uuid.UUID,
+	familyId uuid.UUID,
+	deviceId DeviceId,
+	client Client,
+	reason string,
+) {
+	var revoked int64
+	// Токены выданные до появления семейств
+	if familyId != uuid.Nil {
+		var err error
+		if revoked, err = s.refreshTokensRepo.DeleteTokenFamily(ctx, uow, userId, familyId); err != nil {
+			s.log.Error(ctx, "failed to revoke token family", slog.String("family_id", familyId.String()), sl.Err(err))
+			return
+		}
+	}
+	if revoked > 0 {
+		if err := s.enqueueWarning(ctx, uow, userId, messages_sender.Warning{
+			Event:     messages_sender.TokenReuseWarning,
+			Time:      s.now(),
+			Locale:    client.Locale,
+			IpAddress: client.IpAddress,
+			Device:    client.UserAgent,
+			Reasons:   []string{reason},
+			// Семейство уже отозвано, остается завершить все сессии
+			ReportUrl: s.reportUrl(ctx, userId, uuid.Nil),
+		}); err != nil {
+			s.log.Error(ctx, "failed to enqueue warning", slog.String("user_id", userId.String()), sl.Err(err))
+			return
+		}
+	}
+	if !s.recordAndCommit(ctx, uow, s.auditEvent(audit.ReuseEvent, audit.DeniedOutcome, userId, deviceId[:], client, reason)) {
+		return
+	}
+	if revoked == 0 {
+		return
+	}
+	s.log.Warn(
+		ctx,
+		"refresh token family revoked",
+		slog.String("user_id", userId.String()),
+		slog.String("family_id", familyId.String()),
+		slog.String("ip", client.IpAddress),
+		slog.Int64("revoked", revoked),
+	)
+}
+
+func (s *service[T]) auditEvent(
+	eventType audit.EventType,
+	outcome audit.Outcome,
+	userId uuid.UUID,
+	deviceId []byte,
+	client Client,
+	reason string,
+) audit.Event {
+	return audit.Event{
+		Id:        uuid.New(),
+		Type:      eventType,
+		Outcome:   outcome,
+		UserId:    userId,
+		DeviceId:  deviceId,
+		IpAddress: client.IpAddress,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+		CreatedAt: s.now(),
+	}
+}
+
+// Используется для неудачных операций, когда ошибка записи
+// не должна менять ответ клиенту. Сообщает, выполнена ли фиксация.
+func (s *service[T]) recordAndCommit(ctx context.Context, uow unit_of_work.UnitOfWork[T], event audit.Event) bool {
+	if err := s.auditLog.Record(ctx, uow, event); err != nil {
+		s.log.Error(ctx, "failed to record audit event", slog.String("type", string(event.Type)), sl.Err(err))
+		return false
+	}
+	if err := uow.Commit(ctx); err != nil {
+		s.log.Error(ctx, "failed to commit audit event", slog.String("type", string(event.Type)), sl.Err(err))
+		return false
+	}
+	return true
+}
+
+// Записывает событие в отдельной единице работы, если операция не дошла до изменения токенов
+func (s *service[T]) recordEvent(ctx context.Context, event audit.Event) {
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		s.log.Error(ctx, "failed to record audit event", slog.String("type", string(event.Type)), sl.Err(err))
+		return
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	s.recordAndCommit(ctx, uow, event)
+}
+
+func (s *service[T]) enqueueWarning(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[T],
+	userId uuid.UUID,
+	warning messages_sender.Warning,
+) error {
+	message, err := outbox.Warning(userId, warning, s.now())
+	if err != nil {
+		return err
+	}
+	return s.outbox.Enqueue(ctx, uow, message)
 }
 
 func (s *service[T]) userExists(
@@ -298,20 +1295,68 @@ func (s *service[T]) userExists(
 	return nil
 }
 
+// Абсолютное время жизни сессии отсчитывается от последнего входа,
+// время простоя - от последнего обновления пары.
+func (s *service[T]) checkSession(record TokenRecord) error {
+	now := s.now()
+	if s.cfg.SessionMaxAge > 0 && now.Sub(record.SessionStartedAt) >= s.cfg.SessionMaxAge {
+		return fmt.Errorf("%w: max age exceeded", ErrSessionExpired)
+	}
+	if s.cfg.SessionIdleTimeout > 0 && now.Sub(record.LastUsedAt) >= s.cfg.SessionIdleTimeout {
+		return fmt.Errorf("%w: idle timeout exceeded", ErrSessionExpired)
+	}
+	return nil
+}
+
 func (s *service[T]) issueTokens(
 	userId uuid.UUID,
 	ipAddress string,
+	session session,
 ) (tokens, *shared.DomainError) {
+	now := s.now()
+	accessTokenId := uuid.New().String()
+	refreshTokenExpiresAt := now.Add(s.cfg.RefreshTTL)
+	// Refresh токен не должен переживать сессию
+	if s.cfg.SessionMaxAge > 0 {
+		sessionExpiresAt := session.startedAt.Add(s.cfg.SessionMaxAge)
+		if sessionExpiresAt.Before(refreshTokenExpiresAt) {
+			refreshTokenExpiresAt = sessionExpiresAt
+		}
+	}
 	// > Access токен тип JWT, алгоритм SHA512
-	// Наверно имелся в виду `HMAC-SHA512` (`HS512`)
-	t := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+	// Наверно имелся в виду `HMAC-SHA512` (`HS512`), он используется по умолчанию,
+	// но для проверки токенов сторонними сервисами можно выбрать асимметричный алгоритм
+	accessTokenClaims := jwt.MapClaims{
 		"sub": userId,
 		"ip":  ipAddress,
 		// Access токен должен быть уникальным, иначе один Access токен
 		// будет подходить к нескольким Refresh токенам и наоборот
-		"jti": uuid.New().String(),
-	})
-	accessToken, err := t.SignedString(s.secret)
+		"jti":       accessTokenId,
+		"ver":       session.tokenVersion,
+		"auth_time": session.startedAt.Unix(),
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       now.Add(s.cfg.AccessTTL).Unix(),
+	}
+	refreshTokenClaims := jwt.MapClaims{
+		// > Payload токенов должен содержать сведения об ip адресе клиента
+		// Не понятно зачем оно тут, может опечатка или пропущено слово `Access`?
+		"ip": ipAddress,
+		// Позволяют отозвать пару по Refresh токену
+		"uid":       userId,
+		"ati":       accessTokenId,
+		"auth_time": session.startedAt.Unix(),
+		"fam":       session.familyId.String(),
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       refreshTokenExpiresAt.Unix(),
+	}
+	if session.deviceBound {
+		did := base64.RawURLEncoding.EncodeToString(session.deviceId[:])
+		accessTokenClaims["did"] = did
+		refreshTokenClaims["did"] = did
+	}
+	accessToken, err := s.keyring.Sign(accessTokenClaims)
 	if err != nil {
 		return tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: access token: %s", ErrFailedToIssueTokens, err),
@@ -320,16 +1365,11 @@ func (s *service[T]) issueTokens(
 	}
 	accessTokenHash := sha256.Sum256([]byte(accessToken))
 	accessTokenHashSlice := accessTokenHash[:]
-	t = jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
-		"sub": base64.URLEncoding.EncodeToString(accessTokenHashSlice),
-		// > Payload токенов должен содержать сведения об ip адресе клиента
-		// Не понятно зачем оно тут, может опечатка или пропущено слово `Access`?
-		"ip": ipAddress,
-	})
 	// > Refresh токен ... должен быть защищен от изменения на стороне клиента
 	// Тут может быть речь про HttpOnly Secure Lax/Strict Cookie, но т.к. нет
 	// информации о клиентах, то будем считать что речь про JWS/JWE
-	refreshToken, err := t.SignedString(s.secret)
+	refreshTokenClaims["sub"] = base64.URLEncoding.EncodeToString(accessTokenHashSlice)
+	refreshToken, err := s.keyring.Sign(refreshTokenClaims)
 	if err != nil {
 		return tokens{}, shared.NewUnexpectedError(
 			fmt.Errorf("%w: refresh token: %s", ErrFailedToIssueTokens, err),
@@ -350,5 +1390,59 @@ func (s *service[T]) issueTokens(
 		accessToken:           accessToken,
 		refreshToken:          base64EncodedRefreshToken,
 		hashOfAccessTokenHash: hashOfAccessTokenHash,
+		issuedAt:              now,
+		refreshTokenExpiresAt: refreshTokenExpiresAt,
 	}, nil
 }
+
+func (s *service[T]) result(t tokens) Tokens {
+	return Tokens{
+		AccessToken:      t.accessToken,
+		RefreshToken:     t.refreshToken,
+		ExpiresIn:        s.cfg.AccessTTL,
+		RefreshExpiresIn: t.refreshTokenExpiresAt.Sub(t.issuedAt),
+	}
+}
+
+func uuidClaim(claims jwt.MapClaims, name string) (uuid.UUID, error) {
+	value, ok := claims[name].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("invalid %s claim", name)
+	}
+	return uuid.Parse(value)
+}
+
+// Токены без привязки к устройству идентифицируют его по ip-адресу
+func deviceIdClaim(claims jwt.MapClaims, ip string) (DeviceId, bool, error) {
+	did, ok := claims["did"].(string)
+	if !ok {
+		return sha256.Sum256([]byte(ip)), false, nil
+	}
+	var deviceId DeviceId
+	decoded, err := base64.RawURLEncoding.DecodeString(did)
+	if err != nil || len(decoded) != len(deviceId) {
+		return DeviceId{}, false, fmt.Errorf("invalid did claim")
+	}
+	copy(deviceId[:], decoded)
+	return deviceId, true, nil
+}
+
+// Токены выданные до появления версий имеют нулевую версию
+func tokenVersionClaim(claims jwt.MapClaims) int64 {
+	if ver, ok := claims["ver"].(float64); ok {
+		return int64(ver)
+	}
+	return 0
+}
+
+func parseFamilyId(claims jwt.MapClaims) uuid.UUID {
+	fam, ok := claims["fam"].(string)
+	if !ok {
+		return uuid.Nil
+	}
+	familyId, err := uuid.Parse(fam)
+	if err != nil {
+		return uuid.Nil
+	}
+	return familyId
+}
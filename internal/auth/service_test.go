@@ -8,20 +8,37 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/audit"
 	"github.com/x0k/medods-authentication-service/internal/lib/logger"
 	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
 	"github.com/x0k/medods-authentication-service/internal/shared"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var testConfig = Config{
+	AccessTTL:            15 * time.Minute,
+	RefreshTTL:           time.Hour,
+	SessionMaxAge:        24 * time.Hour,
+	SessionIdleTimeout:   2 * time.Hour,
+	ChallengeTTL:         10 * time.Minute,
+	ChallengeMaxAttempts: 3,
+}
+
 type serviceMocks struct {
 	users         *MockUsersRepository
 	refreshTokens *MockRefreshTokensRepository[any]
+	challenges    *MockChallengesRepository[any]
+	reportTokens  *MockReportTokensRepository[any]
+	auditLog      *audit.MockAuditLog[any]
+	outbox        *outbox.MockOutbox[any]
 	sender        *MockMessagesSender
 	uowFactory    *unit_of_work.MockFactory[any]
 	uow           *unit_of_work.MockUnitOfWork[any]
@@ -34,6 +51,10 @@ func newTestService(t *testing.T, secret []byte, setup func(serviceMocks)) *serv
 	})))
 	users := NewMockUsersRepository(t)
 	refreshTokens := NewMockRefreshTokensRepository[any](t)
+	challenges := NewMockChallengesRepository[any](t)
+	reportTokens := NewMockReportTokensRepository[any](t)
+	auditLog := audit.NewMockAuditLog[any](t)
+	outbox := outbox.NewMockOutbox[any](t)
 	sender := NewMockMessagesSender(t)
 	uowFactory := unit_of_work.NewMockFactory[any](t)
 	uow := unit_of_work.NewMockUnitOfWork[any](t)
@@ -41,21 +62,61 @@ func newTestService(t *testing.T, secret []byte, setup func(serviceMocks)) *serv
 		setup(serviceMocks{
 			users:         users,
 			refreshTokens: refreshTokens,
+			challenges:    challenges,
+			reportTokens:  reportTokens,
+			auditLog:      auditLog,
+			outbox:        outbox,
 			sender:        sender,
 			uowFactory:    uowFactory,
 			uow:           uow,
 		})
 	}
+	key, err := NewSigningKey(HS512, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := NewKeyring(time.Now)
+	keyring.reset(key, nil)
+	riskPolicy, err := newRiskPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	return newService(
 		log,
-		secret,
+		&testConfig,
+		keyring,
 		users,
 		refreshTokens,
+		challenges,
+		reportTokens,
+		auditLog,
+		outbox,
 		sender,
 		uowFactory.Execute,
+		clientDeviceIdentifier{fallback: ipDeviceIdentifier{}},
+		riskPolicy,
+		time.Now,
 	)
 }
 
+func expectAuditEvent(m serviceMocks, eventType audit.EventType, outcome audit.Outcome) {
+	m.auditLog.EXPECT().
+		Record(mock.Anything, m.uow, mock.MatchedBy(func(e audit.Event) bool {
+			return e.Type == eventType && e.Outcome == outcome
+		})).
+		Return(nil).
+		Once()
+}
+
+func expectWarning(m serviceMocks, userId uuid.UUID) {
+	m.outbox.EXPECT().
+		Enqueue(mock.Anything, m.uow, mock.MatchedBy(func(msg outbox.Message) bool {
+			return msg.Kind == outbox.WarningKind && msg.UserId == userId
+		})).
+		Return(nil).
+		Once()
+}
+
 func TestServiceIssueTokens(t *testing.T) {
 	secret := []byte("secret")
 	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
@@ -73,6 +134,10 @@ func TestServiceIssueTokens(t *testing.T) {
 			name: "should return error if user does not exist",
 			service: newTestService(t, secret, func(sm serviceMocks) {
 				sm.users.EXPECT().UserExists(mock.Anything, mock.Anything).Return(false, nil)
+				sm.uowFactory.EXPECT().Execute(mock.Anything).Return(sm.uow, nil)
+				sm.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				sm.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(sm, audit.LoginEvent, audit.FailureOutcome)
 			}),
 			err: shared.NewDomainError(
 				ErrFailedToIssueTokens,
@@ -83,9 +148,14 @@ func TestServiceIssueTokens(t *testing.T) {
 			name: "should issue tokens for the identified user",
 			service: newTestService(t, secret, func(sm serviceMocks) {
 				sm.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+				sm.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
 				sm.refreshTokens.EXPECT().
-					UpsertTokenHash(mock.Anything, userId, userDeviceId, mock.Anything).
+					UpsertTokenHash(mock.Anything, sm.uow, userId, userDeviceId, mock.Anything, mock.Anything, mock.Anything, mock.Anything, Client{IpAddress: userIpAddress}).
 					Return(nil)
+				sm.uowFactory.EXPECT().Execute(mock.Anything).Return(sm.uow, nil)
+				sm.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				sm.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(sm, audit.LoginEvent, audit.SuccessOutcome)
 			}),
 			userId:    userId,
 			ipAddress: userIpAddress,
@@ -94,10 +164,10 @@ func TestServiceIssueTokens(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			accessToken, refreshToken, dErr := c.service.IssueTokens(
+			tokens, dErr := c.service.IssueTokens(
 				context.Background(),
 				c.userId,
-				c.ipAddress,
+				Client{IpAddress: c.ipAddress},
 			)
 			if dErr != nil {
 				if c.err == nil ||
@@ -108,7 +178,7 @@ func TestServiceIssueTokens(t *testing.T) {
 				}
 				return
 			}
-			if _, err := jwt.Parse(accessToken, func(t *jwt.Token) (interface{}, error) {
+			if _, err := jwt.Parse(tokens.AccessToken, func(t *jwt.Token) (interface{}, error) {
 				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
 				}
@@ -116,7 +186,7 @@ func TestServiceIssueTokens(t *testing.T) {
 			}); err != nil {
 				t.Fatal(err)
 			}
-			if _, err := base64.URLEncoding.DecodeString(refreshToken); err != nil {
+			if _, err := base64.URLEncoding.DecodeString(tokens.RefreshToken); err != nil {
 				t.Fatalf("failed to decode refresh token: %s", err)
 			}
 		})
@@ -128,12 +198,17 @@ func TestServiceRefresh(t *testing.T) {
 	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	userIpAddress := "127.0.0.1"
 	userDeviceId := sha256.Sum256([]byte(userIpAddress))
+	testSession := session{
+		startedAt: time.Now(),
+		familyId:  uuid.New(),
+	}
 
 	type testCase struct {
 		name      string
 		service   *service[any]
 		tokens    tokens
 		ipAddress string
+		deviceId  string
 		err       *shared.DomainError
 	}
 
@@ -143,7 +218,7 @@ func TestServiceRefresh(t *testing.T) {
 		update func(*testCase),
 	) testCase {
 		service := newTestService(t, secret, setup)
-		tokens, err := service.issueTokens(userId, userIpAddress)
+		tokens, err := service.issueTokens(userId, userIpAddress, testSession)
 		if err != nil {
 			t.Fatalf("failed to issue tokens: %v", err)
 		}
@@ -196,7 +271,7 @@ func TestServiceRefresh(t *testing.T) {
 			"should return error if tokens are not related to each other",
 			nil,
 			func(tc *testCase) {
-				tokens, err := tc.service.issueTokens(userId, userIpAddress)
+				tokens, err := tc.service.issueTokens(userId, userIpAddress, testSession)
 				if err != nil {
 					t.Fatalf("failed to issue tokens: %v", err)
 				}
@@ -228,8 +303,14 @@ func TestServiceRefresh(t *testing.T) {
 				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
 
 				m.refreshTokens.EXPECT().
-					TokenHash(mock.Anything, m.uow, userId, userDeviceId).
-					Return(nil, shared.ErrNotFound)
+					TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+					Return(TokenRecord{}, shared.ErrNotFound)
+				m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
+				m.refreshTokens.EXPECT().
+					DeleteTokenFamily(mock.Anything, m.uow, userId, testSession.familyId).
+					Return(0, nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(m, audit.ReuseEvent, audit.DeniedOutcome)
 			},
 			func(tc *testCase) {
 				tc.err = shared.NewDomainError(
@@ -239,12 +320,42 @@ func TestServiceRefresh(t *testing.T) {
 			},
 		),
 		newTestCase(
-			"should return error if stored refresh token is different",
+			"should not treat refresh after sessions revocation as reuse",
+			func(m serviceMocks) {
+				m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+
+				m.refreshTokens.EXPECT().
+					TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+					Return(TokenRecord{}, shared.ErrNotFound)
+				m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(1, nil)
+				expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+			},
+			func(tc *testCase) {
+				tc.err = shared.NewDomainError(
+					ErrFailedToRefreshTokens,
+					"session revoked",
+				)
+			},
+		),
+		newTestCase(
+			"should revoke token family if stored refresh token is different",
 			func(m serviceMocks) {
 				m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
 
 				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
 				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+
+				m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
+				m.refreshTokens.EXPECT().
+					DeleteTokenFamily(mock.Anything, m.uow, userId, testSession.familyId).
+					Return(1, nil)
+				expectAuditEvent(m, audit.ReuseEvent, audit.DeniedOutcome)
+				expectWarning(m, userId)
 
 				hash, err := bcrypt.GenerateFromPassword(
 					[]byte("different refresh token"),
@@ -254,16 +365,161 @@ func TestServiceRefresh(t *testing.T) {
 					t.Fatalf("failed to generate hash: %v", err)
 				}
 				m.refreshTokens.EXPECT().
-					TokenHash(mock.Anything, m.uow, userId, userDeviceId).
-					Return(hash, nil)
+					TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+					Return(TokenRecord{
+						TokenHash:        hash,
+						ExpiresAt:        time.Now().Add(time.Hour),
+						SessionStartedAt: time.Now(),
+						LastUsedAt:       time.Now(),
+					}, nil)
+			},
+			func(tc *testCase) {
+				tc.err = shared.NewDomainError(
+					ErrFailedToRefreshTokens,
+					"invalid refresh token",
+				)
+			},
+		),
+		newTestCase(
+			"should return error if device id does not match bound device",
+			func(m serviceMocks) {
+				m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(m, audit.AnomalyEvent, audit.DeniedOutcome)
 			},
 			func(tc *testCase) {
+				boundSession := testSession
+				boundSession.deviceId, boundSession.deviceBound, _ = tc.service.deviceIdentifier.Identify(Client{
+					DeviceId: "0123456789abcdef",
+				})
+				tokens, err := tc.service.issueTokens(userId, userIpAddress, boundSession)
+				if err != nil {
+					t.Fatalf("failed to issue tokens: %v", err)
+				}
+				tc.tokens = tokens
+				tc.deviceId = "fedcba9876543210"
+				tc.err = shared.NewDomainError(
+					ErrDeviceMismatch,
+					"device mismatch",
+				)
+			},
+		),
+		newTestCase(
+			"should return error if refresh token is expired",
+			nil,
+			func(tc *testCase) {
+				tc.service.now = func() time.Time {
+					return time.Now().Add(testConfig.RefreshTTL + time.Minute)
+				}
 				tc.err = shared.NewDomainError(
 					ErrFailedToRefreshTokens,
 					"invalid refresh token",
 				)
 			},
 		),
+		newTestCase(
+			"should return error if stored refresh token is expired",
+			nil,
+			func(tc *testCase) {
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash: tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt: time.Now().Add(-time.Minute),
+							}, nil
+						})
+				})
+				tc.err = shared.NewDomainError(
+					ErrRefreshTokenExpired,
+					"refresh token expired",
+				)
+			},
+		),
+		newTestCase(
+			"should return error if session exceeds max age",
+			nil,
+			func(tc *testCase) {
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: time.Now().Add(-testConfig.SessionMaxAge),
+								LastUsedAt:       time.Now(),
+							}, nil
+						})
+				})
+				tc.err = shared.NewDomainError(
+					ErrSessionExpired,
+					"session expired",
+				)
+			},
+		),
+		newTestCase(
+			"should return error if session is idle for too long",
+			nil,
+			func(tc *testCase) {
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: time.Now(),
+								LastUsedAt:       time.Now().Add(-testConfig.SessionIdleTimeout),
+							}, nil
+						})
+				})
+				tc.err = shared.NewDomainError(
+					ErrSessionExpired,
+					"session expired",
+				)
+			},
+		),
 		newTestCase(
 			"should return new tokens",
 			nil,
@@ -274,17 +530,24 @@ func TestServiceRefresh(t *testing.T) {
 					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
 					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
 					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.SuccessOutcome)
 
 					m.refreshTokens.EXPECT().
-						TokenHash(mock.Anything, m.uow, userId, userDeviceId).
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
 						RunAndReturn(func(
 							ctx context.Context,
 							uow unit_of_work.UnitOfWork[any],
 							u uuid.UUID,
 							b [32]byte,
-						) ([]byte, error) {
-							return tc.tokens.hashOfAccessTokenHash, nil
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: tc.tokens.issuedAt,
+								LastUsedAt:       tc.tokens.issuedAt,
+							}, nil
 						})
+					m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
 					m.refreshTokens.EXPECT().
 						UpdateTokenHash(
 							mock.Anything,
@@ -293,6 +556,9 @@ func TestServiceRefresh(t *testing.T) {
 							userDeviceId,
 							userDeviceId,
 							mock.AnythingOfType("[]uint8"),
+							mock.AnythingOfType("time.Time"),
+							mock.AnythingOfType("time.Time"),
+							mock.AnythingOfType("auth.Client"),
 						).
 						Return(nil)
 				})
@@ -309,17 +575,25 @@ func TestServiceRefresh(t *testing.T) {
 					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
 					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
 					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.SuccessOutcome)
+					expectAuditEvent(m, audit.AnomalyEvent, audit.SuccessOutcome)
 
 					m.refreshTokens.EXPECT().
-						TokenHash(mock.Anything, m.uow, userId, userDeviceId).
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
 						RunAndReturn(func(
 							ctx context.Context,
 							uow unit_of_work.UnitOfWork[any],
 							u uuid.UUID,
 							b [32]byte,
-						) ([]byte, error) {
-							return tc.tokens.hashOfAccessTokenHash, nil
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: tc.tokens.issuedAt,
+								LastUsedAt:       tc.tokens.issuedAt,
+							}, nil
 						})
+					m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
 					m.refreshTokens.EXPECT().
 						UpdateTokenHash(
 							mock.Anything,
@@ -328,24 +602,158 @@ func TestServiceRefresh(t *testing.T) {
 							userDeviceId,
 							sha256.Sum256([]byte(tc.ipAddress)),
 							mock.AnythingOfType("[]uint8"),
+							mock.AnythingOfType("time.Time"),
+							mock.AnythingOfType("time.Time"),
+							mock.AnythingOfType("auth.Client"),
 						).
 						Return(nil)
 
+					expectWarning(m, userId)
+				})
+			},
+		),
+		newTestCase(
+			"should require verification if risk policy demands step up",
+			nil,
+			func(tc *testCase) {
+				tc.ipAddress = "127.0.0.2"
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.ChallengedOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: tc.tokens.issuedAt,
+								LastUsedAt:       tc.tokens.issuedAt,
+							}, nil
+						})
+					m.challenges.EXPECT().
+						PairChallenge(mock.Anything, m.uow, mock.AnythingOfType("[]uint8")).
+						Return(Challenge{}, shared.ErrNotFound)
+					m.challenges.EXPECT().
+						CreateChallenge(mock.Anything, m.uow, mock.AnythingOfType("auth.Challenge")).
+						Return(nil)
 					m.sender.EXPECT().
-						SendWarning(mock.Anything, userId, mock.AnythingOfType("string")).
+						SendVerificationCode(mock.Anything, userId, mock.AnythingOfType("string")).
 						Return(nil)
 				})
+				tc.service.riskPolicy = newStepUpRiskPolicy(t)
+				tc.err = shared.NewDomainError(
+					ErrStepUpRequired,
+					"additional verification required",
+				)
+			},
+		),
+		newTestCase(
+			"should reuse active challenge without sending new code",
+			nil,
+			func(tc *testCase) {
+				tc.ipAddress = "127.0.0.2"
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.ChallengedOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: tc.tokens.issuedAt,
+								LastUsedAt:       tc.tokens.issuedAt,
+							}, nil
+						})
+					m.challenges.EXPECT().
+						PairChallenge(mock.Anything, m.uow, mock.AnythingOfType("[]uint8")).
+						Return(Challenge{
+							Id:        uuid.New(),
+							UserId:    userId,
+							Attempts:  1,
+							ExpiresAt: time.Now().Add(time.Minute),
+						}, nil)
+				})
+				tc.service.riskPolicy = newStepUpRiskPolicy(t)
+				tc.err = shared.NewDomainError(
+					ErrStepUpRequired,
+					"additional verification required",
+				)
+			},
+		),
+		newTestCase(
+			"should refuse challenge for pair with exhausted attempts",
+			nil,
+			func(tc *testCase) {
+				tc.ipAddress = "127.0.0.2"
+				tc.service = newTestService(t, secret, func(m serviceMocks) {
+					m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+
+					m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+					m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+
+					m.refreshTokens.EXPECT().
+						TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							u uuid.UUID,
+							b [32]byte,
+						) (TokenRecord, error) {
+							return TokenRecord{
+								TokenHash:        tc.tokens.hashOfAccessTokenHash,
+								ExpiresAt:        tc.tokens.refreshTokenExpiresAt,
+								SessionStartedAt: tc.tokens.issuedAt,
+								LastUsedAt:       tc.tokens.issuedAt,
+							}, nil
+						})
+					m.challenges.EXPECT().
+						PairChallenge(mock.Anything, m.uow, mock.AnythingOfType("[]uint8")).
+						Return(Challenge{
+							Id:        uuid.New(),
+							UserId:    userId,
+							Attempts:  testConfig.ChallengeMaxAttempts,
+							ExpiresAt: time.Now().Add(-time.Minute),
+						}, nil)
+				})
+				tc.service.riskPolicy = newStepUpRiskPolicy(t)
+				tc.err = shared.NewDomainError(
+					ErrTooManyAttempts,
+					"too many attempts",
+				)
 			},
 		),
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			accessToken, refreshToken, dErr := c.service.Refresh(
+			tokens, dErr := c.service.Refresh(
 				context.Background(),
 				c.tokens.accessToken,
 				c.tokens.refreshToken,
-				c.ipAddress,
+				Client{IpAddress: c.ipAddress, DeviceId: c.deviceId},
 			)
 			if dErr != nil {
 				if c.err == nil ||
@@ -356,7 +764,7 @@ func TestServiceRefresh(t *testing.T) {
 				}
 				return
 			}
-			if _, err := jwt.Parse(accessToken, func(t *jwt.Token) (interface{}, error) {
+			if _, err := jwt.Parse(tokens.AccessToken, func(t *jwt.Token) (interface{}, error) {
 				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
 				}
@@ -364,9 +772,624 @@ func TestServiceRefresh(t *testing.T) {
 			}); err != nil {
 				t.Fatal(err)
 			}
-			if _, err := base64.URLEncoding.DecodeString(refreshToken); err != nil {
+			if _, err := base64.URLEncoding.DecodeString(tokens.RefreshToken); err != nil {
 				t.Fatalf("failed to decode refresh token: %s", err)
 			}
 		})
 	}
 }
+
+func TestServiceRevoke(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	userIpAddress := "127.0.0.1"
+	userDeviceId := sha256.Sum256([]byte(userIpAddress))
+	testSession := session{
+		startedAt: time.Now(),
+		familyId:  uuid.New(),
+	}
+
+	newRevokingService := func(t *testing.T, tokens *tokens) *service[any] {
+		return newTestService(t, secret, func(m serviceMocks) {
+			m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+			m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+			m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+
+			m.refreshTokens.EXPECT().
+				TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+				RunAndReturn(func(
+					ctx context.Context,
+					uow unit_of_work.UnitOfWork[any],
+					u uuid.UUID,
+					b [32]byte,
+				) (TokenRecord, error) {
+					return TokenRecord{TokenHash: tokens.hashOfAccessTokenHash}, nil
+				})
+			m.refreshTokens.EXPECT().
+				DeleteTokenHash(mock.Anything, m.uow, userId, userDeviceId).
+				Return(nil)
+			m.refreshTokens.EXPECT().
+				RevokeAccessToken(mock.Anything, m.uow, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("time.Time")).
+				Return(nil)
+			expectAuditEvent(m, audit.RevokeEvent, audit.SuccessOutcome)
+		})
+	}
+
+	t.Run("should revoke pair by access token", func(t *testing.T) {
+		var tokens tokens
+		service := newRevokingService(t, &tokens)
+		tokens, err := service.issueTokens(userId, userIpAddress, testSession)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.Revoke(context.Background(), tokens.accessToken, "", Client{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should revoke pair by refresh token", func(t *testing.T) {
+		var tokens tokens
+		service := newRevokingService(t, &tokens)
+		tokens, err := service.issueTokens(userId, userIpAddress, testSession)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.Revoke(context.Background(), tokens.refreshToken, RefreshTokenHint, Client{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should ignore invalid token", func(t *testing.T) {
+		service := newTestService(t, secret, nil)
+		if err := service.Revoke(context.Background(), "invalid token", "", Client{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestServiceIntrospect(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	userIpAddress := "127.0.0.1"
+	testSession := session{
+		startedAt: time.Now(),
+		familyId:  uuid.New(),
+	}
+
+	cases := []struct {
+		name          string
+		revoked       bool
+		expired       bool
+		tokenVersion  int64
+		token         string
+		introspection Introspection
+	}{
+		{
+			name: "should return active token claims",
+			introspection: Introspection{
+				Active:    true,
+				UserId:    userId,
+				IpAddress: userIpAddress,
+			},
+		},
+		{
+			name:          "should return revoked token as inactive",
+			revoked:       true,
+			introspection: Introspection{Revoked: true},
+		},
+		{
+			name:          "should return token issued before sessions revocation as inactive",
+			tokenVersion:  1,
+			introspection: Introspection{Revoked: true},
+		},
+		{
+			name:    "should return expired token as inactive",
+			expired: true,
+		},
+		{
+			name:  "should return invalid token as inactive",
+			token: "invalid token",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service := newTestService(t, secret, func(m serviceMocks) {
+				if c.token == "" && !c.expired {
+					m.refreshTokens.EXPECT().
+						IsAccessTokenRevoked(mock.Anything, mock.AnythingOfType("uuid.UUID")).
+						Return(c.revoked, nil)
+				}
+				if c.token == "" && !c.expired && !c.revoked {
+					m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(c.tokenVersion, nil)
+				}
+			})
+			tokens, dErr := service.issueTokens(userId, userIpAddress, testSession)
+			if dErr != nil {
+				t.Fatal(dErr)
+			}
+			token := tokens.accessToken
+			if c.token != "" {
+				token = c.token
+			}
+			if c.expired {
+				service.now = func() time.Time {
+					return time.Now().Add(testConfig.AccessTTL + time.Minute)
+				}
+			}
+			introspection, dErr := service.Introspect(context.Background(), token)
+			if dErr != nil {
+				t.Fatal(dErr)
+			}
+			if introspection.Active != c.introspection.Active ||
+				introspection.Revoked != c.introspection.Revoked ||
+				introspection.UserId != c.introspection.UserId ||
+				introspection.IpAddress != c.introspection.IpAddress {
+				t.Errorf("unexpected introspection: %+v", introspection)
+			}
+		})
+	}
+}
+
+func TestServiceRevokeSessions(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+
+	service := newTestService(t, secret, func(m serviceMocks) {
+		m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+		m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+		m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+
+		m.refreshTokens.EXPECT().DeleteUserTokens(mock.Anything, m.uow, userId).Return(2, nil)
+		m.refreshTokens.EXPECT().IncrementTokenVersion(mock.Anything, m.uow, userId).Return(1, nil)
+		expectAuditEvent(m, audit.RevokeEvent, audit.SuccessOutcome)
+	})
+	if err := service.RevokeSessions(context.Background(), userId, Client{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should reject unknown user", func(t *testing.T) {
+		service := newTestService(t, secret, func(m serviceMocks) {
+			m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+			m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+
+			m.refreshTokens.EXPECT().DeleteUserTokens(mock.Anything, m.uow, userId).Return(0, nil)
+			m.refreshTokens.EXPECT().IncrementTokenVersion(mock.Anything, m.uow, userId).Return(0, shared.ErrNotFound)
+		})
+		err := service.RevokeSessions(context.Background(), userId, Client{})
+		if err == nil || !err.Expected {
+			t.Fatalf("expected user not found error, got %v", err)
+		}
+	})
+}
+
+func TestServiceSessions(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	currentDeviceId := sha256.Sum256([]byte("127.0.0.1"))
+	otherDeviceId := sha256.Sum256([]byte("127.0.0.2"))
+	currentSessionId := uuid.New()
+
+	service := newTestService(t, secret, func(m serviceMocks) {
+		m.refreshTokens.EXPECT().Sessions(mock.Anything, userId).Return([]SessionRecord{
+			{Id: uuid.New(), DeviceId: otherDeviceId, UserAgent: "other"},
+			{Id: currentSessionId, DeviceId: currentDeviceId, UserAgent: "current"},
+		}, nil)
+	})
+	sessions, err := service.Sessions(context.Background(), Principal{
+		UserId:   userId,
+		DeviceId: currentDeviceId,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.Current != (s.Id == currentSessionId) {
+			t.Errorf("unexpected current flag for session %+v", s)
+		}
+	}
+}
+
+func TestServiceRevokeSession(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	sessionId := uuid.New()
+
+	cases := []struct {
+		name    string
+		deleted int64
+		err     error
+	}{
+		{
+			name:    "should revoke session",
+			deleted: 1,
+		},
+		{
+			name: "should return not found error for unknown session",
+			err:  shared.ErrNotFound,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service := newTestService(t, secret, func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				if c.err == nil {
+					m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+					expectAuditEvent(m, audit.RevokeEvent, audit.SuccessOutcome)
+				}
+				m.refreshTokens.EXPECT().
+					DeleteTokenFamily(mock.Anything, m.uow, userId, sessionId).
+					Return(c.deleted, nil)
+			})
+			err := service.RevokeSession(context.Background(), userId, sessionId, Client{})
+			if err != nil {
+				if c.err == nil || !errors.Is(err.Err, c.err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if c.err != nil {
+				t.Fatalf("expected error %v", c.err)
+			}
+		})
+	}
+}
+
+func newStepUpRiskPolicy(t *testing.T) RiskPolicy {
+	policy, err := newRiskPolicy([]RiskRule{
+		{Signal: IpChangeSignal, Decision: "step_up"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestServiceVerifyRefresh(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	userIpAddress := "127.0.0.1"
+	userDeviceId := sha256.Sum256([]byte(userIpAddress))
+	newIpAddress := "127.0.0.2"
+	challengeId := uuid.New()
+	code := "123456"
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSession := session{
+		startedAt: time.Now(),
+		familyId:  uuid.New(),
+	}
+
+	cases := []struct {
+		name      string
+		code      string
+		challenge func(accessToken string) Challenge
+		setup     func(m serviceMocks)
+		err       error
+	}{
+		{
+			name: "should return error for unknown challenge",
+			setup: func(m serviceMocks) {
+				m.challenges.EXPECT().
+					Challenge(mock.Anything, m.uow, challengeId).
+					Return(Challenge{}, shared.ErrNotFound)
+			},
+			err: shared.ErrNotFound,
+		},
+		{
+			name: "should return error if challenge belongs to other tokens",
+			challenge: func(string) Challenge {
+				hash := sha256.Sum256([]byte("other access token"))
+				return Challenge{
+					Id:              challengeId,
+					UserId:          userId,
+					AccessTokenHash: hash[:],
+					CodeHash:        codeHash,
+					ExpiresAt:       time.Now().Add(time.Minute),
+				}
+			},
+			err: ErrFailedToVerifyRefresh,
+		},
+		{
+			name: "should keep expired challenge",
+			challenge: func(accessToken string) Challenge {
+				hash := sha256.Sum256([]byte(accessToken))
+				return Challenge{
+					Id:              challengeId,
+					UserId:          userId,
+					AccessTokenHash: hash[:],
+					CodeHash:        codeHash,
+					ExpiresAt:       time.Now().Add(-time.Minute),
+				}
+			},
+			setup: func(m serviceMocks) {
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+			},
+			err: ErrChallengeExpired,
+		},
+		{
+			name: "should keep challenge after too many attempts",
+			challenge: func(accessToken string) Challenge {
+				hash := sha256.Sum256([]byte(accessToken))
+				return Challenge{
+					Id:              challengeId,
+					UserId:          userId,
+					AccessTokenHash: hash[:],
+					CodeHash:        codeHash,
+					Attempts:        testConfig.ChallengeMaxAttempts,
+					ExpiresAt:       time.Now().Add(time.Minute),
+				}
+			},
+			setup: func(m serviceMocks) {
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+			},
+			err: ErrTooManyAttempts,
+		},
+		{
+			name: "should count invalid code attempt",
+			code: "654321",
+			challenge: func(accessToken string) Challenge {
+				hash := sha256.Sum256([]byte(accessToken))
+				return Challenge{
+					Id:              challengeId,
+					UserId:          userId,
+					AccessTokenHash: hash[:],
+					CodeHash:        codeHash,
+					ExpiresAt:       time.Now().Add(time.Minute),
+				}
+			},
+			setup: func(m serviceMocks) {
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.challenges.EXPECT().IncrementChallengeAttempts(mock.Anything, m.uow, challengeId).Return(nil)
+				expectAuditEvent(m, audit.RefreshEvent, audit.FailureOutcome)
+			},
+			err: ErrInvalidVerificationCode,
+		},
+		{
+			name: "should issue tokens after successful verification",
+			challenge: func(accessToken string) Challenge {
+				hash := sha256.Sum256([]byte(accessToken))
+				return Challenge{
+					Id:              challengeId,
+					UserId:          userId,
+					AccessTokenHash: hash[:],
+					CodeHash:        codeHash,
+					ExpiresAt:       time.Now().Add(time.Minute),
+				}
+			},
+			setup: func(m serviceMocks) {
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.challenges.EXPECT().DeleteChallenge(mock.Anything, m.uow, challengeId).Return(nil)
+				m.users.EXPECT().UserExists(mock.Anything, userId).Return(true, nil)
+				m.refreshTokens.EXPECT().TokenVersion(mock.Anything, userId).Return(0, nil)
+				expectAuditEvent(m, audit.RefreshEvent, audit.SuccessOutcome)
+				m.refreshTokens.EXPECT().
+					UpdateTokenHash(
+						mock.Anything,
+						m.uow,
+						userId,
+						userDeviceId,
+						sha256.Sum256([]byte(newIpAddress)),
+						mock.AnythingOfType("[]uint8"),
+						mock.AnythingOfType("time.Time"),
+						mock.AnythingOfType("time.Time"),
+						mock.AnythingOfType("auth.Client"),
+					).
+					Return(nil)
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var issued tokens
+			service := newTestService(t, secret, func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				if c.challenge != nil {
+					m.challenges.EXPECT().
+						Challenge(mock.Anything, m.uow, challengeId).
+						RunAndReturn(func(
+							ctx context.Context,
+							uow unit_of_work.UnitOfWork[any],
+							id uuid.UUID,
+						) (Challenge, error) {
+							return c.challenge(issued.accessToken), nil
+						})
+				}
+				m.refreshTokens.EXPECT().
+					TokenRecord(mock.Anything, m.uow, userId, userDeviceId).
+					RunAndReturn(func(
+						ctx context.Context,
+						uow unit_of_work.UnitOfWork[any],
+						u uuid.UUID,
+						b [32]byte,
+					) (TokenRecord, error) {
+						return TokenRecord{
+							TokenHash:        issued.hashOfAccessTokenHash,
+							ExpiresAt:        issued.refreshTokenExpiresAt,
+							SessionStartedAt: issued.issuedAt,
+							LastUsedAt:       issued.issuedAt,
+						}, nil
+					}).
+					Maybe()
+				if c.setup != nil {
+					c.setup(m)
+				}
+			})
+			service.riskPolicy = newStepUpRiskPolicy(t)
+			var dErr *shared.DomainError
+			issued, dErr = service.issueTokens(userId, userIpAddress, testSession)
+			if dErr != nil {
+				t.Fatalf("failed to issue tokens: %v", dErr)
+			}
+			enteredCode := c.code
+			if enteredCode == "" {
+				enteredCode = code
+			}
+			result, dErr := service.VerifyRefresh(
+				context.Background(),
+				challengeId,
+				enteredCode,
+				issued.accessToken,
+				issued.refreshToken,
+				Client{IpAddress: newIpAddress},
+			)
+			if dErr != nil {
+				if c.err == nil || !errors.Is(dErr.Err, c.err) || !dErr.Expected {
+					t.Fatalf("unexpected error: %v", dErr)
+				}
+				return
+			}
+			if c.err != nil {
+				t.Fatalf("expected error %v", c.err)
+			}
+			if result.AccessToken == "" || result.RefreshToken == "" {
+				t.Fatal("expected new tokens")
+			}
+		})
+	}
+}
+
+func TestServiceReport(t *testing.T) {
+	secret := []byte("secret")
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	familyId := uuid.New()
+
+	cases := []struct {
+		name     string
+		familyId uuid.UUID
+		ttl      time.Duration
+		token    func(s *service[any], link string) string
+		scope    ReportScope
+		setup    func(m serviceMocks)
+		err      error
+	}{
+		{
+			name: "should reject malformed token",
+			token: func(*service[any], string) string {
+				return "invalid"
+			},
+			err: ErrInvalidToken,
+		},
+		{
+			name: "should reject token without report audience",
+			token: func(s *service[any], _ string) string {
+				token, err := s.keyring.Sign(jwt.MapClaims{
+					"sub": userId,
+					"jti": uuid.New(),
+					"exp": time.Now().Add(time.Hour).Unix(),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			err: ErrInvalidToken,
+		},
+		{
+			name: "should reject expired link",
+			ttl:  -time.Minute,
+			err:  ErrInvalidToken,
+		},
+		{
+			name:     "should reject used link",
+			familyId: familyId,
+			scope:    ReportDeviceScope,
+			setup: func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.reportTokens.EXPECT().
+					UseReportToken(mock.Anything, m.uow, mock.Anything, mock.Anything).
+					Return(false, nil)
+			},
+			err: ErrReportLinkUsed,
+		},
+		{
+			name:     "should revoke reported session",
+			familyId: familyId,
+			scope:    ReportDeviceScope,
+			setup: func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.reportTokens.EXPECT().
+					UseReportToken(mock.Anything, m.uow, mock.Anything, mock.Anything).
+					Return(true, nil)
+				m.refreshTokens.EXPECT().
+					DeleteTokenFamily(mock.Anything, m.uow, userId, familyId).
+					Return(1, nil)
+				expectAuditEvent(m, audit.ReportEvent, audit.SuccessOutcome)
+			},
+		},
+		{
+			name:     "should revoke all sessions",
+			familyId: familyId,
+			scope:    ReportAllScope,
+			setup: func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.reportTokens.EXPECT().
+					UseReportToken(mock.Anything, m.uow, mock.Anything, mock.Anything).
+					Return(true, nil)
+				m.refreshTokens.EXPECT().DeleteUserTokens(mock.Anything, m.uow, userId).Return(2, nil)
+				m.refreshTokens.EXPECT().IncrementTokenVersion(mock.Anything, m.uow, userId).Return(1, nil)
+				expectAuditEvent(m, audit.ReportEvent, audit.SuccessOutcome)
+			},
+		},
+		{
+			name:  "should revoke all sessions if reported session is unknown",
+			scope: ReportDeviceScope,
+			setup: func(m serviceMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.reportTokens.EXPECT().
+					UseReportToken(mock.Anything, m.uow, mock.Anything, mock.Anything).
+					Return(true, nil)
+				m.refreshTokens.EXPECT().DeleteUserTokens(mock.Anything, m.uow, userId).Return(2, nil)
+				m.refreshTokens.EXPECT().IncrementTokenVersion(mock.Anything, m.uow, userId).Return(1, nil)
+				expectAuditEvent(m, audit.ReportEvent, audit.SuccessOutcome)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, secret, tc.setup)
+			cfg := testConfig
+			cfg.PublicUrl = "https://auth.test"
+			cfg.ReportLinkTTL = time.Hour
+			if tc.ttl != 0 {
+				cfg.ReportLinkTTL = tc.ttl
+			}
+			s.cfg = &cfg
+			link := s.reportUrl(context.Background(), userId, tc.familyId)
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := u.Query().Get("token")
+			if tc.token != nil {
+				token = tc.token(s, link)
+			}
+			dErr := s.Report(context.Background(), token, tc.scope, Client{IpAddress: "127.0.0.1"})
+			if tc.err == nil {
+				if dErr != nil {
+					t.Fatalf("unexpected error: %v", dErr.Err)
+				}
+				return
+			}
+			if dErr == nil || !errors.Is(dErr.Err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, dErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc7517
+
+var ErrUnsupportedKey = errors.New("unsupported key")
+var ErrUnknownKey = errors.New("unknown key")
+
+type Key struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+func New(kid string, alg string, publicKey crypto.PublicKey) (Key, error) {
+	key := Key{
+		Use: "sig",
+		Alg: alg,
+		Kid: kid,
+	}
+	switch k := publicKey.(type) {
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = encode(k)
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = encode(k.N.Bytes())
+		key.E = encode(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		key.Kty = "EC"
+		key.Crv = k.Curve.Params().Name
+		size := (k.Curve.Params().BitSize + 7) / 8
+		key.X = encode(k.X.FillBytes(make([]byte, size)))
+		key.Y = encode(k.Y.FillBytes(make([]byte, size)))
+	default:
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, publicKey)
+	}
+	return key, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key size", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, k.Kty)
+	}
+}
+
+// Проверяет подпись ключом набора с совпадающими `kid` и `alg`
+func (s Set) KeyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	for _, k := range s.Keys {
+		if k.Kid != kid || k.Kid == "" {
+			continue
+		}
+		if k.Alg != t.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
+		}
+		return k.PublicKey()
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
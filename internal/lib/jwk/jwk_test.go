@@ -0,0 +1,65 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSetKeyFunc(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		method    jwt.SigningMethod
+		key       crypto.Signer
+		publicKey crypto.PublicKey
+	}{
+		{name: "Ed25519", method: jwt.SigningMethodEdDSA, key: edKey, publicKey: edKey.Public()},
+		{name: "RSA", method: jwt.SigningMethodRS256, key: rsaKey, publicKey: rsaKey.Public()},
+		{name: "EC", method: jwt.SigningMethodES256, key: ecKey, publicKey: ecKey.Public()},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := New(tc.name, tc.method.Alg(), tc.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set := Set{Keys: []Key{key}}
+			token := jwt.NewWithClaims(tc.method, jwt.MapClaims{"sub": "test"})
+			token.Header["kid"] = tc.name
+			signed, err := token.SignedString(tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := jwt.Parse(signed, set.KeyFunc); err != nil {
+				t.Fatal(err)
+			}
+			token.Header["kid"] = "unknown"
+			signed, err = token.SignedString(tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := jwt.Parse(signed, set.KeyFunc); !errors.Is(err, ErrUnknownKey) {
+				t.Fatalf("expected unknown key error, got %v", err)
+			}
+		})
+	}
+}
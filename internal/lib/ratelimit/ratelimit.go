@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// Корзина вмещает `Requests` запросов и полностью восполняется за `Period`
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Формат: `10/1m`, пустая строка или `0` отключают ограничение
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q, expected `requests/period`", ErrInvalidLimit, value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q, requests must be positive", ErrInvalidLimit, value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q, period must be positive", ErrInvalidLimit, value)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Скорость восполнения в токенах за секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Через сколько появится следующий токен, если запрос отклонен
+	RetryAfter time.Duration
+	// Через сколько корзина заполнится полностью
+	Reset time.Duration
+}
+
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Нулевая корзина считается полной
+func Take(bucket Bucket, limit Limit, now time.Time) (Bucket, Result) {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+	tokens := capacity
+	if !bucket.UpdatedAt.IsZero() {
+		elapsed := now.Sub(bucket.UpdatedAt).Seconds()
+		tokens = math.Min(capacity, bucket.Tokens+math.Max(0, elapsed)*rate)
+	}
+	result := Result{}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = seconds((capacity - tokens) / rate)
+	return Bucket{Tokens: tokens, UpdatedAt: now}, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+const sweepInterval = time.Minute
+
+type inMemoryBucket struct {
+	Bucket
+	// Время, после которого корзина полна и ее можно удалить
+	fullAt time.Time
+}
+
+// Подходит для единственного экземпляра сервиса
+type InMemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]inMemoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewInMemoryStore(now func() time.Time) *InMemoryStore {
+	return &InMemoryStore{
+		buckets: make(map[string]inMemoryBucket),
+		now:     now,
+	}
+}
+
+func (s *InMemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	bucket, result := Take(s.buckets[key].Bucket, limit, now)
+	s.buckets[key] = inMemoryBucket{
+		Bucket: bucket,
+		fullAt: now.Add(result.Reset),
+	}
+	return result, nil
+}
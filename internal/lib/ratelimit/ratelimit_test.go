@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected Limit
+		err      bool
+	}{
+		{value: "", expected: Limit{}},
+		{value: "0", expected: Limit{}},
+		{value: "10/1m", expected: Limit{Requests: 10, Period: time.Minute}},
+		{value: " 5/30s ", expected: Limit{Requests: 5, Period: 30 * time.Second}},
+		{value: "10", err: true},
+		{value: "0/1m", err: true},
+		{value: "10/0s", err: true},
+		{value: "ten/1m", err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			limit, err := ParseLimit(c.value)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if limit != c.expected {
+				t.Fatalf("expected %v, got %v", c.expected, limit)
+			}
+		})
+	}
+}
+
+func TestInMemoryStore(t *testing.T) {
+	now := time.Now()
+	store := NewInMemoryStore(func() time.Time { return now })
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+	ctx := context.Background()
+
+	take := func(key string) Result {
+		result, err := store.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if r := take("a"); !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if r := take("a"); !r.Allowed || r.Remaining != 0 || r.Reset != 10*time.Second {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	r := take("a")
+	if r.Allowed || r.RetryAfter != 5*time.Second {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if r := take("b"); !r.Allowed {
+		t.Fatal("buckets must be independent")
+	}
+
+	now = now.Add(5 * time.Second)
+	if r := take("a"); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("unexpected result after refill: %+v", r)
+	}
+
+	now = now.Add(time.Hour)
+	take("c")
+	if _, ok := store.buckets["a"]; ok {
+		t.Fatal("full buckets must be swept")
+	}
+}
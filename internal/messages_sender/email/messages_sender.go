@@ -6,6 +6,12 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/wneessen/go-mail"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+const (
+	digestTemplate           = "digest"
+	verificationCodeTemplate = "verification_code"
 )
 
 type UsersRepository interface {
@@ -13,24 +19,51 @@ type UsersRepository interface {
 }
 
 type Sender struct {
-	repo   UsersRepository
-	client *mail.Client
-	sender string
+	repo      UsersRepository
+	client    *mail.Client
+	sender    string
+	templates *Templates
 }
 
 func New(
 	repo UsersRepository,
 	client *mail.Client,
 	sender string,
+	templates *Templates,
 ) *Sender {
 	return &Sender{
-		repo:   repo,
-		client: client,
-		sender: sender,
+		repo:      repo,
+		client:    client,
+		sender:    sender,
+		templates: templates,
+	}
+}
+
+func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	rendered, err := s.templates.Render(warning.Locale, string(warning.Event), warning)
+	if err != nil {
+		return fmt.Errorf("failed to render warning: %w", err)
 	}
+	return s.send(ctx, userId, rendered)
 }
 
-func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, message string) error {
+func (s *Sender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	rendered, err := s.templates.Render(digest.Locale, digestTemplate, digest)
+	if err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	return s.send(ctx, userId, rendered)
+}
+
+func (s *Sender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	rendered, err := s.templates.Render("", verificationCodeTemplate, struct{ Code string }{code})
+	if err != nil {
+		return fmt.Errorf("failed to render verification code: %w", err)
+	}
+	return s.send(ctx, userId, rendered)
+}
+
+func (s *Sender) send(ctx context.Context, userId uuid.UUID, rendered Rendered) error {
 	msg := mail.NewMsg()
 	if err := msg.From(s.sender); err != nil {
 		return fmt.Errorf("failed to set sender: %w", err)
@@ -43,7 +76,10 @@ func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, message stri
 	if err := msg.To(email); err != nil {
 		return fmt.Errorf("failed to set receiver: %w", err)
 	}
-	msg.Subject("Warning")
-	msg.SetBodyString(mail.TypeTextPlain, message)
+	msg.Subject(rendered.Subject)
+	msg.SetBodyString(mail.TypeTextPlain, rendered.Text)
+	if rendered.Html != "" {
+		msg.AddAlternativeString(mail.TypeTextHTML, rendered.Html)
+	}
 	return s.client.DialAndSendWithContext(ctx, msg)
 }
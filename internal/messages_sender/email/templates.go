@@ -0,0 +1,151 @@
+package email_messages_sender
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	html_template "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	text_template "text/template"
+
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+const (
+	textTemplateExt = ".txt.tmpl"
+	htmlTemplateExt = ".html.tmpl"
+	subjectTemplate = "subject"
+)
+
+type Rendered struct {
+	Subject string
+	Text    string
+	// Пустая строка, если для сообщения нет HTML шаблона
+	Html string
+}
+
+type template struct {
+	text *text_template.Template
+	html *html_template.Template
+}
+
+// Шаблоны хранятся в каталогах по локалям: `<locale>/<name>.txt.tmpl`
+// и `<locale>/<name>.html.tmpl`. Текстовый шаблон задает тему в блоке `subject`.
+type Templates struct {
+	defaultLocale string
+	// локаль -> имя -> шаблон
+	templates map[string]map[string]*template
+}
+
+// Файлы из `dir` заменяют встроенные шаблоны с тем же путем
+func NewTemplates(dir string, defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		defaultLocale: messages_sender.NormalizeLocale(defaultLocale),
+		templates:     make(map[string]map[string]*template),
+	}
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(embedded); err != nil {
+		return nil, fmt.Errorf("embedded templates: %w", err)
+	}
+	if dir != "" {
+		if err := t.load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("templates from %q: %w", dir, err)
+		}
+	}
+	if _, ok := t.templates[t.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no templates for default locale %q", defaultLocale)
+	}
+	for locale, templates := range t.templates {
+		for name, tmpl := range templates {
+			if tmpl.text == nil || tmpl.text.Lookup(subjectTemplate) == nil {
+				return nil, fmt.Errorf("%s/%s: text template with %q block is required", locale, name, subjectTemplate)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *Templates) load(fsys fs.FS) error {
+	paths, err := fs.Glob(fsys, "*/*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		locale := messages_sender.NormalizeLocale(path.Dir(p))
+		file := path.Base(p)
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if t.templates[locale] == nil {
+			t.templates[locale] = make(map[string]*template)
+		}
+		switch {
+		case strings.HasSuffix(file, textTemplateExt):
+			name := strings.TrimSuffix(file, textTemplateExt)
+			tmpl, err := text_template.New(name).Parse(string(content))
+			if err != nil {
+				return err
+			}
+			t.entry(locale, name).text = tmpl
+		case strings.HasSuffix(file, htmlTemplateExt):
+			name := strings.TrimSuffix(file, htmlTemplateExt)
+			tmpl, err := html_template.New(name).Parse(string(content))
+			if err != nil {
+				return err
+			}
+			t.entry(locale, name).html = tmpl
+		}
+	}
+	return nil
+}
+
+func (t *Templates) entry(locale, name string) *template {
+	tmpl, ok := t.templates[locale][name]
+	if !ok {
+		tmpl = &template{}
+		t.templates[locale][name] = tmpl
+	}
+	return tmpl
+}
+
+// Шаблон выбирается по локали через `messages_sender.LookupLocalized`
+func (t *Templates) Render(locale string, name string, data any) (Rendered, error) {
+	tmpl, ok := messages_sender.LookupLocalized(t.templates, locale, t.defaultLocale, name)
+	if !ok {
+		return Rendered{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	var (
+		rendered Rendered
+		buf      bytes.Buffer
+	)
+	if err := tmpl.text.ExecuteTemplate(&buf, subjectTemplate, data); err != nil {
+		return Rendered{}, fmt.Errorf("render subject: %w", err)
+	}
+	rendered.Subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := tmpl.text.Execute(&buf, data); err != nil {
+		return Rendered{}, fmt.Errorf("render text: %w", err)
+	}
+	rendered.Text = buf.String()
+	if tmpl.html != nil {
+		buf.Reset()
+		if err := tmpl.html.Execute(&buf, data); err != nil {
+			return Rendered{}, fmt.Errorf("render html: %w", err)
+		}
+		rendered.Html = buf.String()
+	}
+	return rendered, nil
+}
@@ -0,0 +1,174 @@
+package email_messages_sender
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var testWarning = messages_sender.Warning{
+	Time:              time.Date(2024, 11, 20, 10, 30, 0, 0, time.UTC),
+	IpAddress:         "10.0.0.2",
+	PreviousIpAddress: "10.0.0.1",
+	Location:          "DE",
+	Device:            "<script>alert(1)</script>",
+	ReportUrl:         "https://auth.example.com/auth/security/report?token=abc",
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedTemplates(t *testing.T) {
+	templates, err := NewTemplates("", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, locale := range []string{"en", "ru"} {
+		for _, event := range []messages_sender.WarningEvent{
+			messages_sender.SuspiciousRefreshWarning,
+			messages_sender.TokenReuseWarning,
+		} {
+			warning := testWarning
+			warning.Event = event
+			rendered, err := templates.Render(locale, string(event), warning)
+			if err != nil {
+				t.Fatalf("%s/%s: %s", locale, event, err)
+			}
+			if rendered.Subject == "" || rendered.Text == "" || rendered.Html == "" {
+				t.Errorf("%s/%s: expected subject, text and html parts", locale, event)
+			}
+			if !strings.Contains(rendered.Text, warning.ReportUrl) {
+				t.Errorf("%s/%s: expected report url in text part", locale, event)
+			}
+			if strings.Contains(rendered.Html, warning.Device) {
+				t.Errorf("%s/%s: expected escaped device in html part", locale, event)
+			}
+		}
+		rendered, err := templates.Render(locale, digestTemplate, messages_sender.Digest{
+			Events:      messages_sender.WarningEvents,
+			Count:       3,
+			Since:       testWarning.Time,
+			Until:       testWarning.Time.Add(time.Hour),
+			IpAddresses: []string{testWarning.PreviousIpAddress, testWarning.IpAddress},
+			ReportUrl:   testWarning.ReportUrl,
+		})
+		if err != nil {
+			t.Fatalf("%s/%s: %s", locale, digestTemplate, err)
+		}
+		if !strings.Contains(rendered.Text, "10.0.0.1, 10.0.0.2") || !strings.Contains(rendered.Html, "10.0.0.1, 10.0.0.2") {
+			t.Errorf("%s/%s: expected addresses in text and html parts", locale, digestTemplate)
+		}
+		if _, err := templates.Render(locale, verificationCodeTemplate, struct{ Code string }{"123456"}); err != nil {
+			t.Errorf("%s/%s: %s", locale, verificationCodeTemplate, err)
+		}
+	}
+}
+
+func TestTemplatesRender(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en/token_reuse.txt.tmpl", `{{define "subject"}}Custom{{end}}custom {{.IpAddress}}`)
+	writeTemplate(t, dir, "de/token_reuse.txt.tmpl", `{{define "subject"}}Sitzung beendet{{end}}{{.IpAddress}}`)
+	templates, err := NewTemplates(dir, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		locale  string
+		subject string
+		html    bool
+	}{
+		{
+			name:    "should use overridden text and embedded html",
+			locale:  "en",
+			subject: "Custom",
+			html:    true,
+		},
+		{
+			name:    "should use locale from config directory",
+			locale:  "de-AT",
+			subject: "Sitzung beendet",
+		},
+		{
+			name:    "should fall back to base language",
+			locale:  "ru-RU",
+			subject: "Сессия завершена в целях безопасности",
+			html:    true,
+		},
+		{
+			name:    "should fall back to default locale",
+			locale:  "fr",
+			subject: "Custom",
+			html:    true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rendered, err := templates.Render(tc.locale, string(messages_sender.TokenReuseWarning), testWarning)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered.Subject != tc.subject {
+				t.Errorf("expected subject %q, got %q", tc.subject, rendered.Subject)
+			}
+			if (rendered.Html != "") != tc.html {
+				t.Errorf("unexpected html part: %q", rendered.Html)
+			}
+		})
+	}
+	if _, err := templates.Render("en", "unknown", testWarning); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected %v, got %v", ErrTemplateNotFound, err)
+	}
+}
+
+func TestNewTemplatesValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		files  map[string]string
+		locale string
+	}{
+		{
+			name:   "should require templates for default locale",
+			locale: "de",
+		},
+		{
+			name:   "should require subject block",
+			files:  map[string]string{"en/token_reuse.txt.tmpl": "no subject"},
+			locale: "en",
+		},
+		{
+			name:   "should require text part",
+			files:  map[string]string{"de/custom.html.tmpl": "<p>html only</p>"},
+			locale: "en",
+		},
+		{
+			name:   "should report syntax errors",
+			files:  map[string]string{"en/token_reuse.html.tmpl": "{{.IpAddress"},
+			locale: "en",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				writeTemplate(t, dir, name, content)
+			}
+			if _, err := NewTemplates(dir, tc.locale); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
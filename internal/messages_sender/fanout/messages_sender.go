@@ -0,0 +1,157 @@
+package fanout_messages_sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type Channel struct {
+	Name   string
+	Sender messages_sender.Sender
+}
+
+type ChannelError struct {
+	Channel string
+	Err     error
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Channel, e.Err)
+}
+
+func (e *ChannelError) Unwrap() error {
+	return e.Err
+}
+
+// Сообщение не доставлено ни в один канал
+type DeliveryError struct {
+	Errors []*ChannelError
+}
+
+func (e *DeliveryError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "failed to deliver to any channel: " + strings.Join(msgs, "; ")
+}
+
+func (e *DeliveryError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Доставляет сообщение параллельно во все каналы или в каналы,
+// указанные в контексте через `messages_sender.WithChannels`.
+// Ошибки каналов журналируются по отдельности и не мешают остальным,
+// ошибка возвращается только если сообщение не доставлено никуда,
+// иначе повторная отправка продублировала бы его в рабочих каналах.
+type Sender struct {
+	log      *logger.Logger
+	channels []Channel
+}
+
+func New(
+	log *logger.Logger,
+	channels []Channel,
+) *Sender {
+	return &Sender{
+		log:      log,
+		channels: channels,
+	}
+}
+
+func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	return s.send(ctx, userId, func(sender messages_sender.Sender) error {
+		return sender.SendWarning(ctx, userId, warning)
+	})
+}
+
+func (s *Sender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	return s.send(ctx, userId, func(sender messages_sender.Sender) error {
+		return sender.SendDigest(ctx, userId, digest)
+	})
+}
+
+func (s *Sender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	return s.send(ctx, userId, func(sender messages_sender.Sender) error {
+		return sender.SendVerificationCode(ctx, userId, code)
+	})
+}
+
+func (s *Sender) send(ctx context.Context, userId uuid.UUID, deliver func(messages_sender.Sender) error) error {
+	channels := s.enabledChannels(ctx)
+	errs := make([]error, len(channels))
+	var wg sync.WaitGroup
+	for i, c := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = deliver(c.Sender)
+		}()
+	}
+	wg.Wait()
+	var (
+		delivered int
+		failed    []*ChannelError
+	)
+	for i, err := range errs {
+		c := channels[i]
+		switch {
+		case err == nil:
+			delivered++
+		// У пользователя нет адреса для канала
+		case errors.Is(err, shared.ErrNotFound):
+			s.log.Debug(
+				ctx,
+				"channel is not available",
+				slog.String("channel", c.Name),
+				slog.String("user_id", userId.String()),
+			)
+		default:
+			s.log.Error(
+				ctx,
+				"failed to deliver message",
+				slog.String("channel", c.Name),
+				slog.String("user_id", userId.String()),
+				sl.Err(err),
+			)
+			failed = append(failed, &ChannelError{Channel: c.Name, Err: err})
+		}
+	}
+	if delivered == 0 && len(failed) > 0 {
+		return &DeliveryError{Errors: failed}
+	}
+	if delivered == 0 {
+		s.log.Warn(ctx, "no channels to deliver message", slog.String("user_id", userId.String()))
+	}
+	return nil
+}
+
+func (s *Sender) enabledChannels(ctx context.Context) []Channel {
+	names, ok := messages_sender.ChannelsFromContext(ctx)
+	if !ok {
+		return s.channels
+	}
+	channels := make([]Channel, 0, len(names))
+	for _, c := range s.channels {
+		if slices.Contains(names, c.Name) {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
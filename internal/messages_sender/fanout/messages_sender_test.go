@@ -0,0 +1,120 @@
+package fanout_messages_sender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type senderMocks struct {
+	email   *messages_sender.MockSender
+	webhook *messages_sender.MockSender
+	sms     *messages_sender.MockSender
+}
+
+func TestSenderSendWarning(t *testing.T) {
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.TokenReuseWarning,
+		IpAddress: "127.0.0.1",
+	}
+	sendErr := errors.New("connection refused")
+	cases := []struct {
+		name     string
+		channels []string
+		setup    func(m senderMocks)
+		failed   []string
+	}{
+		{
+			name: "should deliver to every channel",
+			setup: func(m senderMocks) {
+				m.email.EXPECT().SendWarning(mock.Anything, userId, warning).Return(nil)
+				m.webhook.EXPECT().SendWarning(mock.Anything, userId, warning).Return(nil)
+				m.sms.EXPECT().SendWarning(mock.Anything, userId, warning).Return(nil)
+			},
+		},
+		{
+			name:     "should deliver to enabled channels only",
+			channels: []string{"sms", "unknown"},
+			setup: func(m senderMocks) {
+				m.sms.EXPECT().SendWarning(mock.Anything, userId, warning).Return(nil)
+			},
+		},
+		{
+			name: "should not fail if some channels failed",
+			setup: func(m senderMocks) {
+				m.email.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+				m.webhook.EXPECT().SendWarning(mock.Anything, userId, warning).Return(nil)
+				m.sms.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+			},
+		},
+		{
+			name: "should report every failed channel",
+			setup: func(m senderMocks) {
+				m.email.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+				m.webhook.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+				m.sms.EXPECT().SendWarning(mock.Anything, userId, warning).Return(shared.ErrNotFound)
+			},
+			failed: []string{"email", "webhook"},
+		},
+		{
+			name: "should skip channels without user address",
+			setup: func(m senderMocks) {
+				m.email.EXPECT().SendWarning(mock.Anything, userId, warning).Return(shared.ErrNotFound)
+				m.webhook.EXPECT().SendWarning(mock.Anything, userId, warning).Return(shared.ErrNotFound)
+				m.sms.EXPECT().SendWarning(mock.Anything, userId, warning).Return(shared.ErrNotFound)
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logger.New(slog.New(slog.NewTextHandler(&buf, nil)))
+			m := senderMocks{
+				email:   messages_sender.NewMockSender(t),
+				webhook: messages_sender.NewMockSender(t),
+				sms:     messages_sender.NewMockSender(t),
+			}
+			tc.setup(m)
+			s := New(log, []Channel{
+				{Name: "email", Sender: m.email},
+				{Name: "webhook", Sender: m.webhook},
+				{Name: "sms", Sender: m.sms},
+			})
+			ctx := context.Background()
+			if tc.channels != nil {
+				ctx = messages_sender.WithChannels(ctx, tc.channels)
+			}
+			err := s.SendWarning(ctx, userId, warning)
+			if tc.failed == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var dErr *DeliveryError
+			if !errors.As(err, &dErr) {
+				t.Fatalf("expected delivery error, got %v", err)
+			}
+			if len(dErr.Errors) != len(tc.failed) {
+				t.Fatalf("expected %d channel errors, got %v", len(tc.failed), dErr)
+			}
+			for i, name := range tc.failed {
+				if dErr.Errors[i].Channel != name || !errors.Is(dErr.Errors[i], sendErr) {
+					t.Errorf("unexpected channel error %v", dErr.Errors[i])
+				}
+			}
+			if !errors.Is(err, sendErr) {
+				t.Error("expected delivery error to wrap channel errors")
+			}
+		})
+	}
+}
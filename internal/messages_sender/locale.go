@@ -0,0 +1,27 @@
+package messages_sender
+
+import "strings"
+
+// Приводит `pt_BR` и `PT-br` к виду `pt-br`
+func NormalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+// Ищет значение по локали и имени (локаль -> имя -> значение).
+// Локаль `pt-BR` ищется как `pt-br`, затем `pt`, затем локаль по умолчанию,
+// которая должна быть уже нормализована.
+func LookupLocalized[T any](byLocale map[string]map[string]T, locale string, defaultLocale string, name string) (T, bool) {
+	locale = NormalizeLocale(locale)
+	candidates := []string{locale}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, defaultLocale)
+	for _, l := range candidates {
+		if v, ok := byLocale[l][name]; ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,33 @@
+package messages_sender
+
+import "testing"
+
+func TestLookupLocalized(t *testing.T) {
+	byLocale := map[string]map[string]string{
+		"en":    {"greeting": "hello"},
+		"pt":    {"greeting": "olá"},
+		"pt-br": {"greeting": "oi"},
+	}
+	cases := []struct {
+		name     string
+		locale   string
+		expected string
+		found    bool
+	}{
+		{name: "should match exact locale", locale: "pt_BR", expected: "oi", found: true},
+		{name: "should fall back to base locale", locale: "pt-PT", expected: "olá", found: true},
+		{name: "should fall back to default locale", locale: "ru-RU", expected: "hello", found: true},
+		{name: "should use default locale for empty locale", locale: "", expected: "hello", found: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := LookupLocalized(byLocale, tc.locale, "en", "greeting")
+			if ok != tc.found || actual != tc.expected {
+				t.Errorf("expected %q (%t), got %q (%t)", tc.expected, tc.found, actual, ok)
+			}
+		})
+	}
+	if _, ok := LookupLocalized(byLocale, "en", "en", "farewell"); ok {
+		t.Error("expected missing name not to be found")
+	}
+}
@@ -0,0 +1,103 @@
+package messages_sender
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WarningEvent string
+
+const (
+	// Обновление пары, признанное рискованным
+	SuspiciousRefreshWarning WarningEvent = "suspicious_refresh"
+	// Повторное использование Refresh токена, семейство отозвано
+	TokenReuseWarning WarningEvent = "token_reuse"
+)
+
+var WarningEvents = []WarningEvent{SuspiciousRefreshWarning, TokenReuseWarning}
+
+func (e WarningEvent) Valid() bool {
+	return slices.Contains(WarningEvents, e)
+}
+
+const (
+	EmailChannel   = "email"
+	WebhookChannel = "webhook"
+	SmsChannel     = "sms"
+)
+
+var Channels = []string{EmailChannel, WebhookChannel, SmsChannel}
+
+type channelsKey struct{}
+
+// Ограничивает доставку сообщения перечисленными каналами
+func WithChannels(ctx context.Context, channels []string) context.Context {
+	return context.WithValue(ctx, channelsKey{}, channels)
+}
+
+// Возвращает `false`, если каналы не ограничены
+func ChannelsFromContext(ctx context.Context) ([]string, bool) {
+	channels, ok := ctx.Value(channelsKey{}).([]string)
+	return channels, ok
+}
+
+type deliveryIdKey struct{}
+
+// Идентификатор доставки не меняется при повторных попытках,
+// по нему получатель может отбросить дубликаты
+func WithDeliveryId(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, deliveryIdKey{}, id)
+}
+
+func DeliveryIdFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(deliveryIdKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// Доставка отложена до указанного времени, например из-за тихих часов получателя
+type PostponedError struct {
+	Until time.Time
+}
+
+func (e *PostponedError) Error() string {
+	return "delivery postponed until " + e.Until.UTC().Format(time.RFC3339)
+}
+
+// Сведения о событии для шаблонов сообщений, пустые поля не отображаются
+type Warning struct {
+	Event WarningEvent `json:"event"`
+	Time  time.Time    `json:"time"`
+	// Предпочитаемый язык клиента, например `ru-RU`
+	Locale            string `json:"locale,omitempty"`
+	IpAddress         string `json:"ipAddress"`
+	PreviousIpAddress string `json:"previousIpAddress,omitempty"`
+	Location          string `json:"location,omitempty"`
+	PreviousLocation  string `json:"previousLocation,omitempty"`
+	// User-Agent клиента
+	Device  string   `json:"device,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+	// Ссылка "это был не я"
+	ReportUrl string `json:"reportUrl,omitempty"`
+}
+
+// Сводка предупреждений, не отправленных по отдельности
+type Digest struct {
+	Events []WarningEvent `json:"events"`
+	Count  int            `json:"count"`
+	// Время первого и последнего предупреждения
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	Locale      string    `json:"locale,omitempty"`
+	IpAddresses []string  `json:"ipAddresses"`
+	ReportUrl   string    `json:"reportUrl,omitempty"`
+}
+
+// Канал доставки сообщений пользователю
+type Sender interface {
+	SendWarning(ctx context.Context, userId uuid.UUID, warning Warning) error
+	SendDigest(ctx context.Context, userId uuid.UUID, digest Digest) error
+	SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error
+}
@@ -0,0 +1,181 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package messages_sender
+
+import (
+	context "context"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockSender is an autogenerated mock type for the Sender type
+type MockSender struct {
+	mock.Mock
+}
+
+type MockSender_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSender) EXPECT() *MockSender_Expecter {
+	return &MockSender_Expecter{mock: &_m.Mock}
+}
+
+// SendDigest provides a mock function with given fields: ctx, userId, digest
+func (_m *MockSender) SendDigest(ctx context.Context, userId uuid.UUID, digest Digest) error {
+	ret := _m.Called(ctx, userId, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendDigest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, Digest) error); ok {
+		r0 = rf(ctx, userId, digest)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSender_SendDigest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendDigest'
+type MockSender_SendDigest_Call struct {
+	*mock.Call
+}
+
+// SendDigest is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - digest Digest
+func (_e *MockSender_Expecter) SendDigest(ctx interface{}, userId interface{}, digest interface{}) *MockSender_SendDigest_Call {
+	return &MockSender_SendDigest_Call{Call: _e.mock.On("SendDigest", ctx, userId, digest)}
+}
+
+func (_c *MockSender_SendDigest_Call) Run(run func(ctx context.Context, userId uuid.UUID, digest Digest)) *MockSender_SendDigest_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(Digest))
+	})
+	return _c
+}
+
+func (_c *MockSender_SendDigest_Call) Return(_a0 error) *MockSender_SendDigest_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSender_SendDigest_Call) RunAndReturn(run func(context.Context, uuid.UUID, Digest) error) *MockSender_SendDigest_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SendVerificationCode provides a mock function with given fields: ctx, userId, code
+func (_m *MockSender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	ret := _m.Called(ctx, userId, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendVerificationCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, userId, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSender_SendVerificationCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendVerificationCode'
+type MockSender_SendVerificationCode_Call struct {
+	*mock.Call
+}
+
+// SendVerificationCode is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - code string
+func (_e *MockSender_Expecter) SendVerificationCode(ctx interface{}, userId interface{}, code interface{}) *MockSender_SendVerificationCode_Call {
+	return &MockSender_SendVerificationCode_Call{Call: _e.mock.On("SendVerificationCode", ctx, userId, code)}
+}
+
+func (_c *MockSender_SendVerificationCode_Call) Run(run func(ctx context.Context, userId uuid.UUID, code string)) *MockSender_SendVerificationCode_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockSender_SendVerificationCode_Call) Return(_a0 error) *MockSender_SendVerificationCode_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSender_SendVerificationCode_Call) RunAndReturn(run func(context.Context, uuid.UUID, string) error) *MockSender_SendVerificationCode_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SendWarning provides a mock function with given fields: ctx, userId, warning
+func (_m *MockSender) SendWarning(ctx context.Context, userId uuid.UUID, warning Warning) error {
+	ret := _m.Called(ctx, userId, warning)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendWarning")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, Warning) error); ok {
+		r0 = rf(ctx, userId, warning)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSender_SendWarning_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendWarning'
+type MockSender_SendWarning_Call struct {
+	*mock.Call
+}
+
+// SendWarning is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - warning Warning
+func (_e *MockSender_Expecter) SendWarning(ctx interface{}, userId interface{}, warning interface{}) *MockSender_SendWarning_Call {
+	return &MockSender_SendWarning_Call{Call: _e.mock.On("SendWarning", ctx, userId, warning)}
+}
+
+func (_c *MockSender_SendWarning_Call) Run(run func(ctx context.Context, userId uuid.UUID, warning Warning)) *MockSender_SendWarning_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(Warning))
+	})
+	return _c
+}
+
+func (_c *MockSender_SendWarning_Call) Return(_a0 error) *MockSender_SendWarning_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSender_SendWarning_Call) RunAndReturn(run func(context.Context, uuid.UUID, Warning) error) *MockSender_SendWarning_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSender creates a new instance of MockSender. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSender(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSender {
+	mock := &MockSender{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,177 @@
+package sms_messages_sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var ErrTextNotFound = errors.New("text not found")
+
+const (
+	DefaultContentType  = "application/json"
+	DefaultBodyTemplate = `{"to":{{json .To}},"text":{{json .Text}}}`
+
+	digestText           = "digest"
+	verificationCodeText = "verification_code"
+)
+
+// Сообщения короткие, поэтому задаются здесь, а не файлами шаблонов
+var texts = map[string]map[string]string{
+	"en": {
+		string(messages_sender.SuspiciousRefreshWarning): "New sign-in activity on your account from {{.IpAddress}}." +
+			"{{with .ReportUrl}} Not you? {{.}}{{end}}",
+		string(messages_sender.TokenReuseWarning): "Your session was signed out for your security, " +
+			"a used sign-in token was presented from {{.IpAddress}}.{{with .ReportUrl}} Not you? {{.}}{{end}}",
+		digestText: "{{.Count}} more security alerts on your account from {{range $i, $ip := .IpAddresses}}" +
+			"{{if $i}}, {{end}}{{$ip}}{{end}}.{{with .ReportUrl}} Not you? {{.}}{{end}}",
+		verificationCodeText: "Your verification code: {{.Code}}",
+	},
+	"ru": {
+		string(messages_sender.SuspiciousRefreshWarning): "Новая активность в вашем аккаунте с адреса {{.IpAddress}}." +
+			"{{with .ReportUrl}} Это были не вы? {{.}}{{end}}",
+		string(messages_sender.TokenReuseWarning): "Ваш сеанс завершен в целях безопасности, " +
+			"использованный токен входа был предъявлен с адреса {{.IpAddress}}.{{with .ReportUrl}} Это были не вы? {{.}}{{end}}",
+		digestText: "Еще предупреждений о безопасности аккаунта: {{.Count}}, адреса: {{range $i, $ip := .IpAddresses}}" +
+			"{{if $i}}, {{end}}{{$ip}}{{end}}.{{with .ReportUrl}} Это были не вы? {{.}}{{end}}",
+		verificationCodeText: "Ваш код подтверждения: {{.Code}}",
+	},
+}
+
+type PhonesRepository interface {
+	PhoneById(ctx context.Context, id uuid.UUID) (string, error)
+}
+
+// Запрос к шлюзу строится из шаблона тела, которому доступны `.To` и `.Text`
+// и функция `json` для экранирования строк
+type GatewayConfig struct {
+	Url string
+	// Значение заголовка `Authorization`, например `Bearer <token>`
+	Authorization string
+	ContentType   string
+	BodyTemplate  string
+}
+
+type Sender struct {
+	repo          PhonesRepository
+	client        *http.Client
+	cfg           GatewayConfig
+	body          *template.Template
+	texts         map[string]map[string]*template.Template
+	defaultLocale string
+}
+
+func New(
+	repo PhonesRepository,
+	client *http.Client,
+	cfg GatewayConfig,
+	defaultLocale string,
+) (*Sender, error) {
+	if cfg.ContentType == "" {
+		cfg.ContentType = DefaultContentType
+	}
+	if cfg.BodyTemplate == "" {
+		cfg.BodyTemplate = DefaultBodyTemplate
+	}
+	body, err := template.New("body").Funcs(template.FuncMap{
+		"json": func(s string) (string, error) {
+			b, err := json.Marshal(s)
+			return string(b), err
+		},
+	}).Parse(cfg.BodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	s := &Sender{
+		repo:          repo,
+		client:        client,
+		cfg:           cfg,
+		body:          body,
+		texts:         make(map[string]map[string]*template.Template, len(texts)),
+		defaultLocale: messages_sender.NormalizeLocale(defaultLocale),
+	}
+	for locale, named := range texts {
+		s.texts[locale] = make(map[string]*template.Template, len(named))
+		for name, text := range named {
+			s.texts[locale][name] = template.Must(template.New(name).Parse(text))
+		}
+	}
+	if _, ok := s.texts[s.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no texts for default locale %q", defaultLocale)
+	}
+	return s, nil
+}
+
+func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	text, err := s.render(warning.Locale, string(warning.Event), warning)
+	if err != nil {
+		return fmt.Errorf("failed to render warning: %w", err)
+	}
+	return s.send(ctx, userId, text)
+}
+
+func (s *Sender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	text, err := s.render(digest.Locale, digestText, digest)
+	if err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	return s.send(ctx, userId, text)
+}
+
+func (s *Sender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	text, err := s.render("", verificationCodeText, struct{ Code string }{code})
+	if err != nil {
+		return fmt.Errorf("failed to render verification code: %w", err)
+	}
+	return s.send(ctx, userId, text)
+}
+
+func (s *Sender) send(ctx context.Context, userId uuid.UUID, text string) error {
+	phone, err := s.repo.PhoneById(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user phone: %w", err)
+	}
+	var body bytes.Buffer
+	if err := s.body.Execute(&body, struct{ To, Text string }{phone, text}); err != nil {
+		return fmt.Errorf("failed to render request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.cfg.ContentType)
+	if s.cfg.Authorization != "" {
+		req.Header.Set("Authorization", s.cfg.Authorization)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("sms gateway responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Текст выбирается по локали через `messages_sender.LookupLocalized`
+func (s *Sender) render(locale string, name string, data any) (string, error) {
+	tmpl, ok := messages_sender.LookupLocalized(s.texts, locale, s.defaultLocale, name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTextNotFound, name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
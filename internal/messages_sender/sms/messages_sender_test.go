@@ -0,0 +1,158 @@
+package sms_messages_sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+func TestSender(t *testing.T) {
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.SuspiciousRefreshWarning,
+		Time:      time.Date(2024, 11, 20, 10, 30, 0, 0, time.UTC),
+		Locale:    "ru-RU",
+		IpAddress: "10.0.0.2",
+		ReportUrl: "https://auth.example.com/r",
+	}
+	cases := []struct {
+		name   string
+		cfg    GatewayConfig
+		setup  func(repo *MockPhonesRepository)
+		send   func(s *Sender) error
+		status int
+		check  func(t *testing.T, r *http.Request, body []byte)
+		err    error
+	}{
+		{
+			name: "should send localized warning with default body",
+			setup: func(repo *MockPhonesRepository) {
+				repo.EXPECT().PhoneById(mock.Anything, userId).Return("+70000000000", nil)
+			},
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				var req struct{ To, Text string }
+				if err := json.Unmarshal(body, &req); err != nil {
+					t.Fatal(err)
+				}
+				if req.To != "+70000000000" {
+					t.Errorf("unexpected receiver %q", req.To)
+				}
+				if !strings.Contains(req.Text, "Это были не вы? "+warning.ReportUrl) {
+					t.Errorf("unexpected text %q", req.Text)
+				}
+				if r.Header.Get("Authorization") != "" {
+					t.Error("unexpected authorization header")
+				}
+			},
+		},
+		{
+			name: "should use configured body template",
+			cfg: GatewayConfig{
+				Authorization: "Bearer token",
+				ContentType:   "application/x-www-form-urlencoded",
+				BodyTemplate:  "phone={{.To}}&msg={{urlquery .Text}}",
+			},
+			setup: func(repo *MockPhonesRepository) {
+				repo.EXPECT().PhoneById(mock.Anything, userId).Return("70000000000", nil)
+			},
+			send: func(s *Sender) error {
+				return s.SendVerificationCode(context.Background(), userId, "123456")
+			},
+			status: http.StatusAccepted,
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Error("expected authorization header")
+				}
+				if string(body) != "phone=70000000000&msg=Your+verification+code%3A+123456" {
+					t.Errorf("unexpected body %q", body)
+				}
+			},
+		},
+		{
+			name: "should fail without phone",
+			setup: func(repo *MockPhonesRepository) {
+				repo.EXPECT().PhoneById(mock.Anything, userId).Return("", shared.ErrNotFound)
+			},
+			send: func(s *Sender) error {
+				return s.SendVerificationCode(context.Background(), userId, "123456")
+			},
+			err: shared.ErrNotFound,
+		},
+		{
+			name: "should fail on unsuccessful status",
+			setup: func(repo *MockPhonesRepository) {
+				repo.EXPECT().PhoneById(mock.Anything, userId).Return("+70000000000", nil)
+			},
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			status: http.StatusBadGateway,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				called bool
+				req    *http.Request
+				body   []byte
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				req = r
+				var err error
+				body, err = io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+			repo := NewMockPhonesRepository(t)
+			tc.setup(repo)
+			cfg := tc.cfg
+			cfg.Url = srv.URL
+			s, err := New(repo, srv.Client(), cfg, "en")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tc.send(s)
+			if tc.check == nil {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if tc.err != nil && !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !called {
+				t.Fatal("gateway was not called")
+			}
+			tc.check(t, req, body)
+		})
+	}
+}
+
+func TestNewRejectsInvalidBodyTemplate(t *testing.T) {
+	if _, err := New(nil, http.DefaultClient, GatewayConfig{BodyTemplate: "{{.To"}, "en"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,94 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package sms_messages_sender
+
+import (
+	context "context"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockPhonesRepository is an autogenerated mock type for the PhonesRepository type
+type MockPhonesRepository struct {
+	mock.Mock
+}
+
+type MockPhonesRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPhonesRepository) EXPECT() *MockPhonesRepository_Expecter {
+	return &MockPhonesRepository_Expecter{mock: &_m.Mock}
+}
+
+// PhoneById provides a mock function with given fields: ctx, id
+func (_m *MockPhonesRepository) PhoneById(ctx context.Context, id uuid.UUID) (string, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PhoneById")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (string, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPhonesRepository_PhoneById_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PhoneById'
+type MockPhonesRepository_PhoneById_Call struct {
+	*mock.Call
+}
+
+// PhoneById is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+func (_e *MockPhonesRepository_Expecter) PhoneById(ctx interface{}, id interface{}) *MockPhonesRepository_PhoneById_Call {
+	return &MockPhonesRepository_PhoneById_Call{Call: _e.mock.On("PhoneById", ctx, id)}
+}
+
+func (_c *MockPhonesRepository_PhoneById_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockPhonesRepository_PhoneById_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPhonesRepository_PhoneById_Call) Return(_a0 string, _a1 error) *MockPhonesRepository_PhoneById_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPhonesRepository_PhoneById_Call) RunAndReturn(run func(context.Context, uuid.UUID) (string, error)) *MockPhonesRepository_PhoneById_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPhonesRepository creates a new instance of MockPhonesRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPhonesRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPhonesRepository {
+	mock := &MockPhonesRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
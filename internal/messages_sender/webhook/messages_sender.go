@@ -0,0 +1,134 @@
+package webhook_messages_sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+const (
+	IdHeader        = "X-Webhook-Id"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+type EventType string
+
+const (
+	WarningEvent EventType = "warning"
+	DigestEvent  EventType = "digest"
+)
+
+type Event struct {
+	Id        uuid.UUID                `json:"id"`
+	Type      EventType                `json:"type"`
+	UserId    uuid.UUID                `json:"userId"`
+	CreatedAt time.Time                `json:"createdAt"`
+	Warning   *messages_sender.Warning `json:"warning,omitempty"`
+	Digest    *messages_sender.Digest  `json:"digest,omitempty"`
+}
+
+// Отправляет события POST запросом с JSON телом, подписанным HMAC-SHA256
+type Sender struct {
+	client *http.Client
+	url    string
+	secret []byte
+	now    func() time.Time
+}
+
+func New(
+	client *http.Client,
+	url string,
+	secret []byte,
+	now func() time.Time,
+) *Sender {
+	return &Sender{
+		client: client,
+		url:    url,
+		secret: secret,
+		now:    now,
+	}
+}
+
+func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	return s.send(ctx, Event{
+		Type:    WarningEvent,
+		UserId:  userId,
+		Warning: &warning,
+	})
+}
+
+func (s *Sender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	return s.send(ctx, Event{
+		Type:   DigestEvent,
+		UserId: userId,
+		Digest: &digest,
+	})
+}
+
+// Адрес вебхука общий для всех пользователей, поэтому одноразовые коды
+// через него не отправляются и канал пропускается как недоступный
+func (s *Sender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	return fmt.Errorf("%w: verification codes are not sent to webhook", shared.ErrNotFound)
+}
+
+// Повторная отправка сообщения из `outbox` сохраняет идентификатор события
+func (s *Sender) send(ctx context.Context, event Event) error {
+	id, ok := messages_sender.DeliveryIdFromContext(ctx)
+	if !ok {
+		var err error
+		if id, err = uuid.NewV7(); err != nil {
+			return err
+		}
+	}
+	now := s.now().UTC()
+	event.Id = id
+	event.CreatedAt = now
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdHeader, id.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, signaturePrefix+Sign(s.secret, timestamp, body))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Подпись `<timestamp>.<body>`, получатель сравнивает ее с заголовком
+// `X-Webhook-Signature` без префикса `sha256=`
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,127 @@
+package webhook_messages_sender
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+func TestSender(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2024, 11, 20, 10, 30, 0, 0, time.UTC)
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.TokenReuseWarning,
+		Time:      now,
+		IpAddress: "10.0.0.1",
+	}
+	deliveryId := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	cases := []struct {
+		name   string
+		status int
+		send   func(s *Sender) error
+		check  func(t *testing.T, event Event)
+		err    bool
+	}{
+		{
+			name:   "should send signed warning",
+			status: http.StatusNoContent,
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			check: func(t *testing.T, event Event) {
+				if event.Type != WarningEvent || event.Warning == nil || event.Warning.Event != warning.Event {
+					t.Errorf("unexpected event: %+v", event)
+				}
+			},
+		},
+		{
+			name:   "should use delivery id from context",
+			status: http.StatusOK,
+			send: func(s *Sender) error {
+				ctx := messages_sender.WithDeliveryId(context.Background(), deliveryId)
+				return s.SendWarning(ctx, userId, warning)
+			},
+			check: func(t *testing.T, event Event) {
+				if event.Id != deliveryId {
+					t.Errorf("expected id %s, got %s", deliveryId, event.Id)
+				}
+			},
+		},
+		{
+			name:   "should fail on unsuccessful status",
+			status: http.StatusInternalServerError,
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			err: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				event Event
+				valid bool
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				timestamp := r.Header.Get(TimestampHeader)
+				signature := strings.TrimPrefix(r.Header.Get(SignatureHeader), signaturePrefix)
+				valid = hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) &&
+					timestamp == strconv.FormatInt(now.Unix(), 10)
+				if err := json.Unmarshal(body, &event); err != nil {
+					t.Error(err)
+				}
+				if r.Header.Get(IdHeader) != event.Id.String() {
+					t.Errorf("expected id header %s", event.Id)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+			s := New(srv.Client(), srv.URL, secret, func() time.Time { return now })
+			err := tc.send(s)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valid {
+				t.Error("invalid signature")
+			}
+			if event.UserId != userId || !event.CreatedAt.Equal(now) {
+				t.Errorf("unexpected event: %+v", event)
+			}
+			tc.check(t, event)
+		})
+	}
+
+	t.Run("should not send verification code", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		}))
+		defer srv.Close()
+		s := New(srv.Client(), srv.URL, secret, func() time.Time { return now })
+		if err := s.SendVerificationCode(context.Background(), userId, "123456"); !errors.Is(err, shared.ErrNotFound) {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
+)
+
+// Все предупреждения пользователя, выбравшего сводку, попадают в одно окно
+const digestKey = "digest"
+
+type AggregatorConfig struct {
+	// Окно открывается первым предупреждением, повторы в нем отправляются
+	// одной сводкой после его закрытия. Нулевое значение отключает агрегацию.
+	Window        time.Duration
+	FlushInterval time.Duration
+	BatchSize     int
+}
+
+type Aggregate struct {
+	Id          uuid.UUID
+	UserId      uuid.UUID
+	Key         string
+	Events      []messages_sender.WarningEvent
+	IpAddresses []string
+	Occurrences int
+	// Предупреждения, уже отправленные по отдельности
+	Delivered int
+	Locale    string
+	ReportUrl string
+	FirstAt   time.Time
+	LastAt    time.Time
+	WindowEnd time.Time
+}
+
+type AggregatesRepository[T any] interface {
+	// Атомарно учитывает предупреждение в открытом окне `opened.Key` или открывает
+	// окно `opened`, если открытого нет. Возвращает `true`, если окно уже было открыто.
+	TouchOrOpen(ctx context.Context, opened Aggregate, warning messages_sender.Warning, now time.Time) (bool, error)
+	// Удаляет и возвращает агрегаты с закрытым окном
+	TakeClosed(ctx context.Context, uow unit_of_work.UnitOfWork[T], now time.Time, limit int) ([]Aggregate, error)
+}
+
+// Состояние хранится в базе данных, поэтому окна, открытые до перезапуска,
+// закрываются и отправляются после него
+type Aggregator[T any] struct {
+	log            *logger.Logger
+	cfg            AggregatorConfig
+	aggregatesRepo AggregatesRepository[T]
+	outbox         outbox.Outbox[T]
+	uowFactory     unit_of_work.Factory[T]
+	now            func() time.Time
+}
+
+func NewAggregator[T any](
+	log *logger.Logger,
+	cfg AggregatorConfig,
+	aggregatesRepo AggregatesRepository[T],
+	outbox outbox.Outbox[T],
+	uowFactory unit_of_work.Factory[T],
+	now func() time.Time,
+) *Aggregator[T] {
+	return &Aggregator[T]{
+		log:            log,
+		cfg:            cfg,
+		aggregatesRepo: aggregatesRepo,
+		outbox:         outbox,
+		uowFactory:     uowFactory,
+		now:            now,
+	}
+}
+
+// Одинаковыми считаются предупреждения об одном событии с одного набора адресов,
+// поэтому переключение между двумя сетями дает одно окно
+func aggregationKey(warning messages_sender.Warning) string {
+	ips := []string{warning.IpAddress}
+	if warning.PreviousIpAddress != "" && warning.PreviousIpAddress != warning.IpAddress {
+		ips = append(ips, warning.PreviousIpAddress)
+	}
+	slices.Sort(ips)
+	return string(warning.Event) + ":" + strings.Join(ips, ",")
+}
+
+// Возвращает `true`, если предупреждение войдет в сводку и не должно отправляться сейчас.
+// Окно для отдельной отправки открывается до нее, иначе одновременные предупреждения
+// успели бы отправиться по отдельности. Если отправка не удалась, повторная попытка
+// попадет в открытое окно и предупреждение войдет в сводку.
+func (a *Aggregator[T]) Collect(
+	ctx context.Context,
+	userId uuid.UUID,
+	warning messages_sender.Warning,
+	digest bool,
+) (bool, error) {
+	key := aggregationKey(warning)
+	delivered := 1
+	if digest {
+		key = digestKey
+		delivered = 0
+	}
+	now := a.now()
+	touched, err := a.aggregatesRepo.TouchOrOpen(ctx, a.newAggregate(userId, key, warning, now, delivered), warning, now)
+	if err != nil {
+		return false, fmt.Errorf("touch aggregate: %w", err)
+	}
+	return touched || digest, nil
+}
+
+func (a *Aggregator[T]) newAggregate(
+	userId uuid.UUID,
+	key string,
+	warning messages_sender.Warning,
+	now time.Time,
+	delivered int,
+) Aggregate {
+	return Aggregate{
+		Id:          uuid.New(),
+		UserId:      userId,
+		Key:         key,
+		Events:      []messages_sender.WarningEvent{warning.Event},
+		IpAddresses: []string{warning.IpAddress},
+		Occurrences: 1,
+		Delivered:   delivered,
+		Locale:      warning.Locale,
+		ReportUrl:   warning.ReportUrl,
+		FirstAt:     warning.Time,
+		LastAt:      warning.Time,
+		WindowEnd:   now.Add(a.cfg.Window),
+	}
+}
+
+func (a *Aggregator[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := a.Flush(ctx)
+			if err != nil {
+				a.log.Error(ctx, "failed to flush aggregates", sl.Err(err))
+				break
+			}
+			if n < a.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Записывает в очередь сводки по закрытым окнам и возвращает число обработанных окон
+func (a *Aggregator[T]) Flush(ctx context.Context) (int, error) {
+	uow, err := a.uowFactory(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("create unit of work: %w", err)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			a.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	now := a.now()
+	aggregates, err := a.aggregatesRepo.TakeClosed(ctx, uow, now, a.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("take closed aggregates: %w", err)
+	}
+	for _, aggregate := range aggregates {
+		count := aggregate.Occurrences - aggregate.Delivered
+		if count <= 0 {
+			continue
+		}
+		message, err := outbox.Digest(aggregate.UserId, messages_sender.Digest{
+			Events:      aggregate.Events,
+			Count:       count,
+			Since:       aggregate.FirstAt,
+			Until:       aggregate.LastAt,
+			Locale:      aggregate.Locale,
+			IpAddresses: aggregate.IpAddresses,
+			ReportUrl:   aggregate.ReportUrl,
+		}, now)
+		if err != nil {
+			return 0, fmt.Errorf("create digest message: %w", err)
+		}
+		if err := a.outbox.Enqueue(ctx, uow, message); err != nil {
+			return 0, fmt.Errorf("enqueue digest: %w", err)
+		}
+		a.log.Debug(
+			ctx,
+			"digest enqueued",
+			slog.String("user_id", aggregate.UserId.String()),
+			slog.Int("count", count),
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(aggregates), nil
+}
@@ -0,0 +1,219 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
+)
+
+var testAggregatorConfig = AggregatorConfig{
+	Window:        15 * time.Minute,
+	FlushInterval: time.Second,
+	BatchSize:     10,
+}
+
+type aggregatorMocks struct {
+	repo       *MockAggregatesRepository[any]
+	outbox     *outbox.MockOutbox[any]
+	uowFactory *unit_of_work.MockFactory[any]
+	uow        *unit_of_work.MockUnitOfWork[any]
+}
+
+func newTestAggregator(t *testing.T, now time.Time, setup func(aggregatorMocks)) *Aggregator[any] {
+	var buf bytes.Buffer
+	log := logger.New(slog.New(slog.NewTextHandler(&buf, nil)))
+	m := aggregatorMocks{
+		repo:       NewMockAggregatesRepository[any](t),
+		outbox:     outbox.NewMockOutbox[any](t),
+		uowFactory: unit_of_work.NewMockFactory[any](t),
+		uow:        unit_of_work.NewMockUnitOfWork[any](t),
+	}
+	if setup != nil {
+		setup(m)
+	}
+	return NewAggregator(
+		log,
+		testAggregatorConfig,
+		m.repo,
+		m.outbox,
+		m.uowFactory.Execute,
+		func() time.Time { return now },
+	)
+}
+
+func TestAggregationKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		warning  messages_sender.Warning
+		expected string
+	}{
+		{
+			name: "should use single address",
+			warning: messages_sender.Warning{
+				Event:     messages_sender.TokenReuseWarning,
+				IpAddress: "127.0.0.1",
+			},
+			expected: "token_reuse:127.0.0.1",
+		},
+		{
+			name: "should ignore equal previous address",
+			warning: messages_sender.Warning{
+				Event:             messages_sender.SuspiciousRefreshWarning,
+				IpAddress:         "127.0.0.1",
+				PreviousIpAddress: "127.0.0.1",
+			},
+			expected: "suspicious_refresh:127.0.0.1",
+		},
+		{
+			name: "should not depend on switch direction",
+			warning: messages_sender.Warning{
+				Event:             messages_sender.SuspiciousRefreshWarning,
+				IpAddress:         "127.0.0.2",
+				PreviousIpAddress: "127.0.0.1",
+			},
+			expected: "suspicious_refresh:127.0.0.1,127.0.0.2",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := aggregationKey(tc.warning); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAggregatorCollect(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.SuspiciousRefreshWarning,
+		Time:      now,
+		IpAddress: "127.0.0.1",
+	}
+	opened := func(key string, delivered int) any {
+		return mock.MatchedBy(func(a Aggregate) bool {
+			return a.UserId == userId &&
+				a.Key == key &&
+				a.Occurrences == 1 &&
+				a.Delivered == delivered &&
+				a.WindowEnd.Equal(now.Add(testAggregatorConfig.Window))
+		})
+	}
+	cases := []struct {
+		name      string
+		digest    bool
+		setup     func(aggregatorMocks)
+		collected bool
+	}{
+		{
+			name: "should collect repeated warning",
+			setup: func(m aggregatorMocks) {
+				m.repo.EXPECT().TouchOrOpen(mock.Anything, opened("suspicious_refresh:127.0.0.1", 1), warning, now).Return(true, nil)
+			},
+			collected: true,
+		},
+		{
+			name: "should pass first warning and count it as delivered",
+			setup: func(m aggregatorMocks) {
+				m.repo.EXPECT().TouchOrOpen(mock.Anything, opened("suspicious_refresh:127.0.0.1", 1), warning, now).Return(false, nil)
+			},
+		},
+		{
+			name:   "should collect first warning into digest window",
+			digest: true,
+			setup: func(m aggregatorMocks) {
+				m.repo.EXPECT().TouchOrOpen(mock.Anything, opened(digestKey, 0), warning, now).Return(false, nil)
+			},
+			collected: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestAggregator(t, now, tc.setup)
+			collected, err := a.Collect(context.Background(), userId, warning, tc.digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if collected != tc.collected {
+				t.Errorf("expected %t, got %t", tc.collected, collected)
+			}
+		})
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	aggregate := Aggregate{
+		Id:          uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		UserId:      userId,
+		Key:         "suspicious_refresh:127.0.0.1",
+		Events:      []messages_sender.WarningEvent{messages_sender.SuspiciousRefreshWarning},
+		IpAddresses: []string{"127.0.0.1"},
+		Occurrences: 4,
+		Delivered:   1,
+		Locale:      "en",
+		FirstAt:     now.Add(-20 * time.Minute),
+		LastAt:      now.Add(-10 * time.Minute),
+		WindowEnd:   now.Add(-5 * time.Minute),
+	}
+	single := aggregate
+	single.Occurrences = 1
+	digestOf := func(count int) any {
+		return mock.MatchedBy(func(m outbox.Message) bool {
+			var d messages_sender.Digest
+			if m.Kind != outbox.DigestKind || m.UserId != userId || json.Unmarshal([]byte(m.Payload), &d) != nil {
+				return false
+			}
+			return d.Count == count && d.Since.Equal(aggregate.FirstAt) && d.Until.Equal(aggregate.LastAt)
+		})
+	}
+	cases := []struct {
+		name       string
+		aggregates []Aggregate
+		setup      func(aggregatorMocks)
+	}{
+		{
+			name:       "should enqueue digest of undelivered warnings",
+			aggregates: []Aggregate{aggregate},
+			setup: func(m aggregatorMocks) {
+				m.outbox.EXPECT().Enqueue(mock.Anything, m.uow, digestOf(3)).Return(nil)
+			},
+		},
+		{
+			name:       "should skip window without repeats",
+			aggregates: []Aggregate{single},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestAggregator(t, now, func(m aggregatorMocks) {
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.repo.EXPECT().TakeClosed(mock.Anything, m.uow, now, testAggregatorConfig.BatchSize).Return(tc.aggregates, nil)
+				if tc.setup != nil {
+					tc.setup(m)
+				}
+			})
+			n, err := a.Flush(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(tc.aggregates) {
+				t.Errorf("expected %d aggregates, got %d", len(tc.aggregates), n)
+			}
+		})
+	}
+}
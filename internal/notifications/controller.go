@@ -0,0 +1,201 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	http_adapters "github.com/x0k/medods-authentication-service/internal/adapters/http"
+	"github.com/x0k/medods-authentication-service/internal/lib/httpx"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type NotificationsService interface {
+	Preferences(ctx context.Context, userId uuid.UUID) (Preferences, *shared.DomainError)
+	UpdatePreferences(ctx context.Context, userId uuid.UUID, preferences Preferences, client Client) *shared.DomainError
+}
+
+type controller struct {
+	log                  *logger.Logger
+	notificationsService NotificationsService
+	freshLoginMaxAge     time.Duration
+	decoder              *httpx.JsonBodyDecoder
+}
+
+func newController(
+	log *logger.Logger,
+	notificationsService NotificationsService,
+	freshLoginMaxAge time.Duration,
+) *controller {
+	return &controller{
+		log:                  log,
+		notificationsService: notificationsService,
+		freshLoginMaxAge:     freshLoginMaxAge,
+		decoder: &httpx.JsonBodyDecoder{
+			MaxBytes:              4096,
+			DisallowUnknownFields: true,
+		},
+	}
+}
+
+type quietHoursDTO struct {
+	// Формат `HH:MM`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Имя из базы часовых поясов IANA, например `Europe/Moscow`
+	Timezone string `json:"timezone"`
+}
+
+type preferencesDTO struct {
+	Events     []string       `json:"events"`
+	Channels   []string       `json:"channels"`
+	QuietHours *quietHoursDTO `json:"quietHours"`
+	Digest     bool           `json:"digest"`
+}
+
+func newPreferencesDTO(p Preferences) preferencesDTO {
+	dto := preferencesDTO{
+		Events:   make([]string, len(p.Events)),
+		Channels: p.Channels,
+		Digest:   p.Digest,
+	}
+	for i, e := range p.Events {
+		dto.Events[i] = string(e)
+	}
+	if q := p.QuietHours; q != nil {
+		dto.QuietHours = &quietHoursDTO{
+			Start:    FormatTimeOfDay(q.Start),
+			End:      FormatTimeOfDay(q.End),
+			Timezone: q.Location.String(),
+		}
+	}
+	return dto
+}
+
+func (dto preferencesDTO) preferences() (Preferences, error) {
+	p := Preferences{
+		Events:   make([]messages_sender.WarningEvent, len(dto.Events)),
+		Channels: dto.Channels,
+		Digest:   dto.Digest,
+	}
+	for i, e := range dto.Events {
+		p.Events[i] = messages_sender.WarningEvent(e)
+	}
+	if q := dto.QuietHours; q != nil {
+		start, err := ParseTimeOfDay(q.Start)
+		if err != nil {
+			return Preferences{}, err
+		}
+		end, err := ParseTimeOfDay(q.End)
+		if err != nil {
+			return Preferences{}, err
+		}
+		if q.Timezone == "" {
+			return Preferences{}, fmt.Errorf("%w: quiet hours timezone is required", ErrInvalidPreferences)
+		}
+		location, err := time.LoadLocation(q.Timezone)
+		if err != nil {
+			return Preferences{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreferences, q.Timezone)
+		}
+		p.QuietHours = &QuietHours{
+			Start:    start,
+			End:      end,
+			Location: location,
+		}
+	}
+	return p, nil
+}
+
+func (c *controller) Preferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := http_adapters.UserId(r.Context())
+	if !ok {
+		c.serverError(w, r, ErrUnauthenticated, "user is not authenticated")
+		return
+	}
+	p, err := c.notificationsService.Preferences(r.Context(), userId)
+	if err != nil {
+		c.domainError(w, r, err)
+		return
+	}
+	c.json(w, r, newPreferencesDTO(p), http.StatusOK)
+}
+
+func (c *controller) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := http_adapters.UserId(r.Context())
+	if !ok {
+		c.serverError(w, r, ErrUnauthenticated, "user is not authenticated")
+		return
+	}
+	dto, httpErr := httpx.JSONBody[preferencesDTO](c.decoder, w, r)
+	if httpErr != nil {
+		http.Error(w, httpErr.Text, httpErr.Status)
+		c.log.Debug(r.Context(), "failed to decode JSON", sl.Err(httpErr))
+		return
+	}
+	p, err := dto.preferences()
+	if err != nil {
+		c.badRequest(w, r, err, err.Error())
+		return
+	}
+	authTime, _ := http_adapters.AuthTime(r.Context())
+	dErr := c.notificationsService.UpdatePreferences(r.Context(), userId, p, Client{
+		IpAddress: http_adapters.ClientIpAddress(r),
+		UserAgent: r.UserAgent(),
+		AuthTime:  authTime,
+	})
+	if dErr != nil && errors.Is(dErr.Err, ErrLoginRequired) {
+		c.insufficientAuthentication(w, r, dErr.Err, dErr.Msg)
+		return
+	}
+	if dErr != nil {
+		c.domainError(w, r, dErr)
+		return
+	}
+	c.json(w, r, newPreferencesDTO(p), http.StatusOK)
+}
+
+func (c *controller) domainError(w http.ResponseWriter, r *http.Request, err *shared.DomainError) {
+	if err.Expected {
+		c.badRequest(w, r, err.Err, err.Msg)
+	} else {
+		c.serverError(w, r, err.Err, err.Msg)
+	}
+}
+
+func (c *controller) badRequest(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	http.Error(w, msg, http.StatusBadRequest)
+	c.log.Debug(r.Context(), msg, sl.Err(err))
+}
+
+// https://datatracker.ietf.org/doc/html/rfc9470#section-3
+func (c *controller) insufficientAuthentication(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+		`Bearer error="insufficient_user_authentication", error_description=%q, max_age=%d`,
+		msg,
+		int64(c.freshLoginMaxAge.Seconds()),
+	))
+	http.Error(w, msg, http.StatusUnauthorized)
+	c.log.Debug(r.Context(), msg, sl.Err(err))
+}
+
+func (c *controller) serverError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	http.Error(w, msg, http.StatusInternalServerError)
+	c.log.Error(r.Context(), msg, sl.Err(err))
+}
+
+func (c *controller) json(w http.ResponseWriter, r *http.Request, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		c.serverError(w, r, err, "failed to encode JSON")
+	}
+}
@@ -0,0 +1,161 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package notifications
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender"
+
+	time "time"
+
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+// MockAggregatesRepository is an autogenerated mock type for the AggregatesRepository type
+type MockAggregatesRepository[T any] struct {
+	mock.Mock
+}
+
+type MockAggregatesRepository_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAggregatesRepository[T]) EXPECT() *MockAggregatesRepository_Expecter[T] {
+	return &MockAggregatesRepository_Expecter[T]{mock: &_m.Mock}
+}
+
+// TakeClosed provides a mock function with given fields: ctx, uow, now, limit
+func (_m *MockAggregatesRepository[T]) TakeClosed(ctx context.Context, uow unit_of_work.UnitOfWork[T], now time.Time, limit int) ([]Aggregate, error) {
+	ret := _m.Called(ctx, uow, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakeClosed")
+	}
+
+	var r0 []Aggregate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], time.Time, int) ([]Aggregate, error)); ok {
+		return rf(ctx, uow, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], time.Time, int) []Aggregate); ok {
+		r0 = rf(ctx, uow, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Aggregate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, unit_of_work.UnitOfWork[T], time.Time, int) error); ok {
+		r1 = rf(ctx, uow, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAggregatesRepository_TakeClosed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TakeClosed'
+type MockAggregatesRepository_TakeClosed_Call[T any] struct {
+	*mock.Call
+}
+
+// TakeClosed is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - now time.Time
+//   - limit int
+func (_e *MockAggregatesRepository_Expecter[T]) TakeClosed(ctx interface{}, uow interface{}, now interface{}, limit interface{}) *MockAggregatesRepository_TakeClosed_Call[T] {
+	return &MockAggregatesRepository_TakeClosed_Call[T]{Call: _e.mock.On("TakeClosed", ctx, uow, now, limit)}
+}
+
+func (_c *MockAggregatesRepository_TakeClosed_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], now time.Time, limit int)) *MockAggregatesRepository_TakeClosed_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(time.Time), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *MockAggregatesRepository_TakeClosed_Call[T]) Return(_a0 []Aggregate, _a1 error) *MockAggregatesRepository_TakeClosed_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAggregatesRepository_TakeClosed_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], time.Time, int) ([]Aggregate, error)) *MockAggregatesRepository_TakeClosed_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TouchOrOpen provides a mock function with given fields: ctx, opened, warning, now
+func (_m *MockAggregatesRepository[T]) TouchOrOpen(ctx context.Context, opened Aggregate, warning messages_sender.Warning, now time.Time) (bool, error) {
+	ret := _m.Called(ctx, opened, warning, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TouchOrOpen")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, Aggregate, messages_sender.Warning, time.Time) (bool, error)); ok {
+		return rf(ctx, opened, warning, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, Aggregate, messages_sender.Warning, time.Time) bool); ok {
+		r0 = rf(ctx, opened, warning, now)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, Aggregate, messages_sender.Warning, time.Time) error); ok {
+		r1 = rf(ctx, opened, warning, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAggregatesRepository_TouchOrOpen_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TouchOrOpen'
+type MockAggregatesRepository_TouchOrOpen_Call[T any] struct {
+	*mock.Call
+}
+
+// TouchOrOpen is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opened Aggregate
+//   - warning messages_sender.Warning
+//   - now time.Time
+func (_e *MockAggregatesRepository_Expecter[T]) TouchOrOpen(ctx interface{}, opened interface{}, warning interface{}, now interface{}) *MockAggregatesRepository_TouchOrOpen_Call[T] {
+	return &MockAggregatesRepository_TouchOrOpen_Call[T]{Call: _e.mock.On("TouchOrOpen", ctx, opened, warning, now)}
+}
+
+func (_c *MockAggregatesRepository_TouchOrOpen_Call[T]) Run(run func(ctx context.Context, opened Aggregate, warning messages_sender.Warning, now time.Time)) *MockAggregatesRepository_TouchOrOpen_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Aggregate), args[2].(messages_sender.Warning), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockAggregatesRepository_TouchOrOpen_Call[T]) Return(_a0 bool, _a1 error) *MockAggregatesRepository_TouchOrOpen_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAggregatesRepository_TouchOrOpen_Call[T]) RunAndReturn(run func(context.Context, Aggregate, messages_sender.Warning, time.Time) (bool, error)) *MockAggregatesRepository_TouchOrOpen_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockAggregatesRepository creates a new instance of MockAggregatesRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAggregatesRepository[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAggregatesRepository[T] {
+	mock := &MockAggregatesRepository[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,148 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package notifications
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockPreferencesRepository is an autogenerated mock type for the PreferencesRepository type
+type MockPreferencesRepository[T any] struct {
+	mock.Mock
+}
+
+type MockPreferencesRepository_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPreferencesRepository[T]) EXPECT() *MockPreferencesRepository_Expecter[T] {
+	return &MockPreferencesRepository_Expecter[T]{mock: &_m.Mock}
+}
+
+// Preferences provides a mock function with given fields: ctx, userId
+func (_m *MockPreferencesRepository[T]) Preferences(ctx context.Context, userId uuid.UUID) (Preferences, error) {
+	ret := _m.Called(ctx, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Preferences")
+	}
+
+	var r0 Preferences
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (Preferences, error)); ok {
+		return rf(ctx, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) Preferences); ok {
+		r0 = rf(ctx, userId)
+	} else {
+		r0 = ret.Get(0).(Preferences)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPreferencesRepository_Preferences_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Preferences'
+type MockPreferencesRepository_Preferences_Call[T any] struct {
+	*mock.Call
+}
+
+// Preferences is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+func (_e *MockPreferencesRepository_Expecter[T]) Preferences(ctx interface{}, userId interface{}) *MockPreferencesRepository_Preferences_Call[T] {
+	return &MockPreferencesRepository_Preferences_Call[T]{Call: _e.mock.On("Preferences", ctx, userId)}
+}
+
+func (_c *MockPreferencesRepository_Preferences_Call[T]) Run(run func(ctx context.Context, userId uuid.UUID)) *MockPreferencesRepository_Preferences_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPreferencesRepository_Preferences_Call[T]) Return(_a0 Preferences, _a1 error) *MockPreferencesRepository_Preferences_Call[T] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPreferencesRepository_Preferences_Call[T]) RunAndReturn(run func(context.Context, uuid.UUID) (Preferences, error)) *MockPreferencesRepository_Preferences_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SavePreferences provides a mock function with given fields: ctx, uow, userId, preferences, now
+func (_m *MockPreferencesRepository[T]) SavePreferences(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, preferences Preferences, now time.Time) error {
+	ret := _m.Called(ctx, uow, userId, preferences, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SavePreferences")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, Preferences, time.Time) error); ok {
+		r0 = rf(ctx, uow, userId, preferences, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPreferencesRepository_SavePreferences_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SavePreferences'
+type MockPreferencesRepository_SavePreferences_Call[T any] struct {
+	*mock.Call
+}
+
+// SavePreferences is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - userId uuid.UUID
+//   - preferences Preferences
+//   - now time.Time
+func (_e *MockPreferencesRepository_Expecter[T]) SavePreferences(ctx interface{}, uow interface{}, userId interface{}, preferences interface{}, now interface{}) *MockPreferencesRepository_SavePreferences_Call[T] {
+	return &MockPreferencesRepository_SavePreferences_Call[T]{Call: _e.mock.On("SavePreferences", ctx, uow, userId, preferences, now)}
+}
+
+func (_c *MockPreferencesRepository_SavePreferences_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], userId uuid.UUID, preferences Preferences, now time.Time)) *MockPreferencesRepository_SavePreferences_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(uuid.UUID), args[3].(Preferences), args[4].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockPreferencesRepository_SavePreferences_Call[T]) Return(_a0 error) *MockPreferencesRepository_SavePreferences_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPreferencesRepository_SavePreferences_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], uuid.UUID, Preferences, time.Time) error) *MockPreferencesRepository_SavePreferences_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPreferencesRepository creates a new instance of MockPreferencesRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPreferencesRepository[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPreferencesRepository[T] {
+	mock := &MockPreferencesRepository[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
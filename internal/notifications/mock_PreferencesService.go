@@ -0,0 +1,98 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package notifications
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	shared "github.com/x0k/medods-authentication-service/internal/shared"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockPreferencesService is an autogenerated mock type for the PreferencesService type
+type MockPreferencesService struct {
+	mock.Mock
+}
+
+type MockPreferencesService_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPreferencesService) EXPECT() *MockPreferencesService_Expecter {
+	return &MockPreferencesService_Expecter{mock: &_m.Mock}
+}
+
+// Preferences provides a mock function with given fields: ctx, userId
+func (_m *MockPreferencesService) Preferences(ctx context.Context, userId uuid.UUID) (Preferences, *shared.DomainError) {
+	ret := _m.Called(ctx, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Preferences")
+	}
+
+	var r0 Preferences
+	var r1 *shared.DomainError
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (Preferences, *shared.DomainError)); ok {
+		return rf(ctx, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) Preferences); ok {
+		r0 = rf(ctx, userId)
+	} else {
+		r0 = ret.Get(0).(Preferences)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) *shared.DomainError); ok {
+		r1 = rf(ctx, userId)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*shared.DomainError)
+		}
+	}
+
+	return r0, r1
+}
+
+// MockPreferencesService_Preferences_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Preferences'
+type MockPreferencesService_Preferences_Call struct {
+	*mock.Call
+}
+
+// Preferences is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+func (_e *MockPreferencesService_Expecter) Preferences(ctx interface{}, userId interface{}) *MockPreferencesService_Preferences_Call {
+	return &MockPreferencesService_Preferences_Call{Call: _e.mock.On("Preferences", ctx, userId)}
+}
+
+func (_c *MockPreferencesService_Preferences_Call) Run(run func(ctx context.Context, userId uuid.UUID)) *MockPreferencesService_Preferences_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPreferencesService_Preferences_Call) Return(_a0 Preferences, _a1 *shared.DomainError) *MockPreferencesService_Preferences_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPreferencesService_Preferences_Call) RunAndReturn(run func(context.Context, uuid.UUID) (Preferences, *shared.DomainError)) *MockPreferencesService_Preferences_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPreferencesService creates a new instance of MockPreferencesService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPreferencesService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPreferencesService {
+	mock := &MockPreferencesService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,98 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package notifications
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockWarningsAggregator is an autogenerated mock type for the WarningsAggregator type
+type MockWarningsAggregator struct {
+	mock.Mock
+}
+
+type MockWarningsAggregator_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockWarningsAggregator) EXPECT() *MockWarningsAggregator_Expecter {
+	return &MockWarningsAggregator_Expecter{mock: &_m.Mock}
+}
+
+// Collect provides a mock function with given fields: ctx, userId, warning, digest
+func (_m *MockWarningsAggregator) Collect(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning, digest bool) (bool, error) {
+	ret := _m.Called(ctx, userId, warning, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Collect")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, messages_sender.Warning, bool) (bool, error)); ok {
+		return rf(ctx, userId, warning, digest)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, messages_sender.Warning, bool) bool); ok {
+		r0 = rf(ctx, userId, warning, digest)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, messages_sender.Warning, bool) error); ok {
+		r1 = rf(ctx, userId, warning, digest)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockWarningsAggregator_Collect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Collect'
+type MockWarningsAggregator_Collect_Call struct {
+	*mock.Call
+}
+
+// Collect is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - warning messages_sender.Warning
+//   - digest bool
+func (_e *MockWarningsAggregator_Expecter) Collect(ctx interface{}, userId interface{}, warning interface{}, digest interface{}) *MockWarningsAggregator_Collect_Call {
+	return &MockWarningsAggregator_Collect_Call{Call: _e.mock.On("Collect", ctx, userId, warning, digest)}
+}
+
+func (_c *MockWarningsAggregator_Collect_Call) Run(run func(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning, digest bool)) *MockWarningsAggregator_Collect_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(messages_sender.Warning), args[3].(bool))
+	})
+	return _c
+}
+
+func (_c *MockWarningsAggregator_Collect_Call) Return(_a0 bool, _a1 error) *MockWarningsAggregator_Collect_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockWarningsAggregator_Collect_Call) RunAndReturn(run func(context.Context, uuid.UUID, messages_sender.Warning, bool) (bool, error)) *MockWarningsAggregator_Collect_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockWarningsAggregator creates a new instance of MockWarningsAggregator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockWarningsAggregator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockWarningsAggregator {
+	mock := &MockWarningsAggregator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
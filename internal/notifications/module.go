@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pgx_adapter "github.com/x0k/medods-authentication-service/internal/adapters/pgx"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/outbox"
+)
+
+type Module struct {
+	// Отправляет сообщения через `sender` с учетом настроек пользователя
+	Sender *Sender
+	Router *http.ServeMux
+	// `nil`, если агрегация отключена
+	Aggregator *Aggregator[pgx.Tx]
+}
+
+func New(
+	log *logger.Logger,
+	pgxPool *pgxpool.Pool,
+	cfg Config,
+	sender messages_sender.Sender,
+	outbox outbox.Outbox[pgx.Tx],
+	auditLog audit.AuditLog[pgx.Tx],
+) *Module {
+	service := newService(
+		log.With(slog.String("component", "service")),
+		cfg,
+		NewPgPreferencesRepository(
+			log.With(slog.String("component", "preferences_repository")),
+			pgxPool,
+		),
+		auditLog,
+		pgx_adapter.NewUnitOfWorkFactory(pgxPool),
+		time.Now,
+	)
+	controller := newController(
+		log.With(slog.String("component", "controller")),
+		service,
+		cfg.FreshLoginMaxAge,
+	)
+	var (
+		aggregator         *Aggregator[pgx.Tx]
+		warningsAggregator WarningsAggregator
+	)
+	if cfg.Aggregation.Window > 0 {
+		aggregator = NewAggregator(
+			log.With(slog.String("component", "aggregator")),
+			cfg.Aggregation,
+			NewPgAggregatesRepository(
+				log.With(slog.String("component", "aggregates_repository")),
+				pgxPool,
+			),
+			outbox,
+			pgx_adapter.NewUnitOfWorkFactory(pgxPool),
+			time.Now,
+		)
+		warningsAggregator = aggregator
+	}
+	return &Module{
+		Sender: NewSender(
+			log.With(slog.String("component", "sender")),
+			service,
+			warningsAggregator,
+			sender,
+			time.Now,
+		),
+		Router:     newRouter(controller),
+		Aggregator: aggregator,
+	}
+}
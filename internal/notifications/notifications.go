@@ -0,0 +1,100 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var ErrInvalidPreferences = errors.New("invalid preferences")
+var ErrInvalidTimeOfDay = errors.New("invalid time of day")
+
+type Config struct {
+	// Доступные пользователям каналы доставки
+	Channels    []string
+	Aggregation AggregatorConfig
+	// Настройки можно изменить только в течение этого времени после входа
+	FreshLoginMaxAge time.Duration
+}
+
+// Время суток задается в минутах от полуночи, интервал может переходить через полночь
+type QuietHours struct {
+	Start    int
+	End      int
+	Location *time.Location
+}
+
+// Возвращает окончание тихих часов, если `t` попадает в них
+func (q QuietHours) Until(t time.Time) (time.Time, bool) {
+	local := t.In(q.Location)
+	minute := local.Hour()*60 + local.Minute()
+	var inside bool
+	if q.Start < q.End {
+		inside = minute >= q.Start && minute < q.End
+	} else {
+		inside = minute >= q.Start || minute < q.End
+	}
+	if !inside {
+		return time.Time{}, false
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(), q.End/60, q.End%60, 0, 0, q.Location)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, true
+}
+
+type Preferences struct {
+	// Предупреждения о других событиях не отправляются
+	Events []messages_sender.WarningEvent
+	// Каналы доставки предупреждений и кодов подтверждения
+	Channels []string
+	// Предупреждения в тихие часы откладываются до их окончания, `nil` - без тихих часов
+	QuietHours *QuietHours
+	// Предупреждения объединяются в сводку вместо отдельных сообщений
+	Digest bool
+}
+
+// Предупреждения о компрометации сессии нельзя отключить, они доставляются
+// без учета тихих часов и объединения в сводки
+func Mandatory(event messages_sender.WarningEvent) bool {
+	return event == messages_sender.TokenReuseWarning
+}
+
+// Пользователи без сохраненных настроек получают все предупреждения во все каналы
+func DefaultPreferences(channels []string) Preferences {
+	return Preferences{
+		Events:   messages_sender.WarningEvents,
+		Channels: channels,
+	}
+}
+
+type PreferencesRepository[T any] interface {
+	// Возвращает `shared.ErrNotFound`, если пользователь не менял настройки
+	Preferences(ctx context.Context, userId uuid.UUID) (Preferences, error)
+	SavePreferences(
+		ctx context.Context,
+		uow unit_of_work.UnitOfWork[T],
+		userId uuid.UUID,
+		preferences Preferences,
+		now time.Time,
+	) error
+}
+
+// Формат `15:04`
+func ParseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTimeOfDay, value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func FormatTimeOfDay(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
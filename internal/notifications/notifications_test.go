@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	night := QuietHours{Start: 22 * 60, End: 7 * 60, Location: moscow}
+	lunch := QuietHours{Start: 13 * 60, End: 14 * 60, Location: time.UTC}
+	cases := []struct {
+		name   string
+		quiet  QuietHours
+		now    time.Time
+		until  time.Time
+		inside bool
+	}{
+		{
+			name:   "should postpone evening to next morning",
+			quiet:  night,
+			now:    time.Date(2024, 11, 20, 23, 30, 0, 0, moscow),
+			until:  time.Date(2024, 11, 21, 7, 0, 0, 0, moscow),
+			inside: true,
+		},
+		{
+			name:   "should postpone early morning to the same morning",
+			quiet:  night,
+			now:    time.Date(2024, 11, 21, 3, 0, 0, 0, moscow),
+			until:  time.Date(2024, 11, 21, 7, 0, 0, 0, moscow),
+			inside: true,
+		},
+		{
+			name:   "should use quiet hours timezone",
+			quiet:  night,
+			now:    time.Date(2024, 11, 20, 20, 0, 0, 0, time.UTC),
+			until:  time.Date(2024, 11, 21, 7, 0, 0, 0, moscow),
+			inside: true,
+		},
+		{
+			name:  "should not postpone at the end of quiet hours",
+			quiet: night,
+			now:   time.Date(2024, 11, 21, 7, 0, 0, 0, moscow),
+		},
+		{
+			name:  "should not postpone in the afternoon",
+			quiet: night,
+			now:   time.Date(2024, 11, 21, 15, 0, 0, 0, moscow),
+		},
+		{
+			name:   "should postpone within daytime interval",
+			quiet:  lunch,
+			now:    time.Date(2024, 11, 21, 13, 15, 0, 0, time.UTC),
+			until:  time.Date(2024, 11, 21, 14, 0, 0, 0, time.UTC),
+			inside: true,
+		},
+		{
+			name:  "should not postpone outside daytime interval",
+			quiet: lunch,
+			now:   time.Date(2024, 11, 21, 23, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			until, inside := tc.quiet.Until(tc.now)
+			if inside != tc.inside {
+				t.Fatalf("expected inside %t, got %t", tc.inside, inside)
+			}
+			if !until.Equal(tc.until) {
+				t.Errorf("expected %s, got %s", tc.until, until)
+			}
+		})
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	cases := []struct {
+		value   string
+		minutes int
+		err     bool
+	}{
+		{value: "00:00", minutes: 0},
+		{value: "07:30", minutes: 450},
+		{value: "23:59", minutes: 1439},
+		{value: "24:00", err: true},
+		{value: "7:30pm", err: true},
+		{value: "", err: true},
+	}
+	for _, tc := range cases {
+		minutes, err := ParseTimeOfDay(tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expected error", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tc.value, err)
+			continue
+		}
+		if minutes != tc.minutes {
+			t.Errorf("%q: expected %d, got %d", tc.value, tc.minutes, minutes)
+		}
+		if FormatTimeOfDay(minutes) != tc.value {
+			t.Errorf("%q: expected the same formatted value, got %q", tc.value, FormatTimeOfDay(minutes))
+		}
+	}
+}
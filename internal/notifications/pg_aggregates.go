@@ -0,0 +1,170 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+type PgAggregatesRepository struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func NewPgAggregatesRepository(log *logger.Logger, pool *pgxpool.Pool) *PgAggregatesRepository {
+	return &PgAggregatesRepository{
+		log:  log,
+		pool: pool,
+	}
+}
+
+const touchAggregateQuery = `UPDATE warning_aggregate SET
+	occurrences = occurrences + 1,
+	events = CASE WHEN $3::text = ANY(events) THEN events ELSE array_append(events, $3::text) END,
+	ip_addresses = CASE WHEN $4::text = ANY(ip_addresses) THEN ip_addresses ELSE array_append(ip_addresses, $4::text) END,
+	locale = CASE WHEN $5::text = '' THEN locale ELSE $5::text END,
+	report_url = CASE WHEN $6::text = '' THEN report_url ELSE $6::text END,
+	first_at = LEAST(first_at, $7),
+	last_at = GREATEST(last_at, $7)
+WHERE id = (
+	SELECT id FROM warning_aggregate
+	WHERE user_id = $1 AND key = $2 AND window_end > $8
+	ORDER BY window_end DESC
+	LIMIT 1
+	FOR UPDATE
+)`
+
+const openAggregateQuery = `INSERT INTO warning_aggregate
+(id, user_id, key, events, ip_addresses, occurrences, delivered, locale, report_url, first_at, last_at, window_end)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+// Блокировка окна пользователя до конца транзакции исключает открытие двух окон
+// параллельными предупреждениями
+const lockAggregateQuery = `SELECT pg_advisory_xact_lock(hashtext($1::text || ':' || $2))`
+
+// Выполняется в отдельной транзакции с уровнем изоляции `READ COMMITTED`,
+// чтобы после ожидания блокировки увидеть окно, открытое другой транзакцией
+func (r *PgAggregatesRepository) TouchOrOpen(
+	ctx context.Context,
+	a Aggregate,
+	warning messages_sender.Warning,
+	now time.Time,
+) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log.Error(ctx, "failed to rollback transaction", sl.Err(err))
+		}
+	}()
+	args := []any{a.UserId, a.Key}
+	r.log.Debug(ctx, "executing query", slog.String("query", lockAggregateQuery), slog.Any("args", args))
+	if _, err := tx.Exec(ctx, lockAggregateQuery, args...); err != nil {
+		return false, err
+	}
+	args = []any{a.UserId, a.Key, string(warning.Event), warning.IpAddress, warning.Locale, warning.ReportUrl, warning.Time, now}
+	r.log.Debug(ctx, "executing query", slog.String("query", touchAggregateQuery), slog.Any("args", args[:4]))
+	cmd, err := tx.Exec(ctx, touchAggregateQuery, args...)
+	if err != nil {
+		return false, err
+	}
+	touched := cmd.RowsAffected() == 1
+	if !touched {
+		if err := r.open(ctx, tx, a); err != nil {
+			return false, err
+		}
+	}
+	return touched, tx.Commit(ctx)
+}
+
+func (r *PgAggregatesRepository) open(ctx context.Context, tx pgx.Tx, a Aggregate) error {
+	args := []any{
+		a.Id,
+		a.UserId,
+		a.Key,
+		eventsToStrings(a.Events),
+		a.IpAddresses,
+		a.Occurrences,
+		a.Delivered,
+		a.Locale,
+		a.ReportUrl,
+		a.FirstAt,
+		a.LastAt,
+		a.WindowEnd,
+	}
+	r.log.Debug(ctx, "executing query", slog.String("query", openAggregateQuery), slog.Any("args", args[:3]))
+	_, err := tx.Exec(ctx, openAggregateQuery, args...)
+	return err
+}
+
+const takeClosedAggregatesQuery = `DELETE FROM warning_aggregate
+WHERE id IN (
+	SELECT id FROM warning_aggregate
+	WHERE window_end <= $1
+	ORDER BY window_end
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, key, events, ip_addresses, occurrences, delivered, locale, report_url, first_at, last_at, window_end`
+
+func (r *PgAggregatesRepository) TakeClosed(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	now time.Time,
+	limit int,
+) ([]Aggregate, error) {
+	args := []any{now, limit}
+	r.log.Debug(ctx, "executing query", slog.String("query", takeClosedAggregatesQuery), slog.Any("args", args))
+	rows, err := uow.Tx().Query(ctx, takeClosedAggregatesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Aggregate, error) {
+		var (
+			a      Aggregate
+			events []string
+		)
+		err := row.Scan(
+			&a.Id,
+			&a.UserId,
+			&a.Key,
+			&events,
+			&a.IpAddresses,
+			&a.Occurrences,
+			&a.Delivered,
+			&a.Locale,
+			&a.ReportUrl,
+			&a.FirstAt,
+			&a.LastAt,
+			&a.WindowEnd,
+		)
+		a.Events = stringsToEvents(events)
+		return a, err
+	})
+}
+
+func eventsToStrings(events []messages_sender.WarningEvent) []string {
+	result := make([]string, len(events))
+	for i, e := range events {
+		result[i] = string(e)
+	}
+	return result
+}
+
+func stringsToEvents(events []string) []messages_sender.WarningEvent {
+	result := make([]messages_sender.WarningEvent, len(events))
+	for i, e := range events {
+		result[i] = messages_sender.WarningEvent(e)
+	}
+	return result
+}
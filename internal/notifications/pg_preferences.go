@@ -0,0 +1,103 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type PgPreferencesRepository struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func NewPgPreferencesRepository(log *logger.Logger, pool *pgxpool.Pool) *PgPreferencesRepository {
+	return &PgPreferencesRepository{
+		log:  log,
+		pool: pool,
+	}
+}
+
+const preferencesQuery = `SELECT events, channels, quiet_hours_start, quiet_hours_end, quiet_hours_timezone, digest
+FROM notification_preferences WHERE user_id = $1`
+
+func (r *PgPreferencesRepository) Preferences(ctx context.Context, userId uuid.UUID) (Preferences, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", preferencesQuery), slog.String("user_id", userId.String()))
+	var (
+		p          Preferences
+		events     []string
+		quietStart *int
+		quietEnd   *int
+		timezone   *string
+	)
+	err := r.pool.QueryRow(ctx, preferencesQuery, userId).Scan(
+		&events,
+		&p.Channels,
+		&quietStart,
+		&quietEnd,
+		&timezone,
+		&p.Digest,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Preferences{}, shared.ErrNotFound
+	}
+	if err != nil {
+		return Preferences{}, err
+	}
+	p.Events = stringsToEvents(events)
+	if quietStart != nil && quietEnd != nil && timezone != nil {
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			return Preferences{}, fmt.Errorf("load quiet hours timezone: %w", err)
+		}
+		p.QuietHours = &QuietHours{
+			Start:    *quietStart,
+			End:      *quietEnd,
+			Location: location,
+		}
+	}
+	return p, nil
+}
+
+const savePreferencesQuery = `INSERT INTO notification_preferences
+(user_id, events, channels, quiet_hours_start, quiet_hours_end, quiet_hours_timezone, digest, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id) DO UPDATE SET
+	events = EXCLUDED.events,
+	channels = EXCLUDED.channels,
+	quiet_hours_start = EXCLUDED.quiet_hours_start,
+	quiet_hours_end = EXCLUDED.quiet_hours_end,
+	quiet_hours_timezone = EXCLUDED.quiet_hours_timezone,
+	digest = EXCLUDED.digest,
+	updated_at = EXCLUDED.updated_at`
+
+func (r *PgPreferencesRepository) SavePreferences(
+	ctx context.Context,
+	uow unit_of_work.UnitOfWork[pgx.Tx],
+	userId uuid.UUID,
+	preferences Preferences,
+	now time.Time,
+) error {
+	var (
+		quietStart *int
+		quietEnd   *int
+		timezone   *string
+	)
+	if q := preferences.QuietHours; q != nil {
+		name := q.Location.String()
+		quietStart, quietEnd, timezone = &q.Start, &q.End, &name
+	}
+	args := []any{userId, eventsToStrings(preferences.Events), preferences.Channels, quietStart, quietEnd, timezone, preferences.Digest, now}
+	r.log.Debug(ctx, "executing query", slog.String("query", savePreferencesQuery), slog.Any("args", args))
+	_, err := uow.Tx().Exec(ctx, savePreferencesQuery, args...)
+	return err
+}
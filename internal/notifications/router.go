@@ -0,0 +1,16 @@
+package notifications
+
+import "net/http"
+
+type Controller interface {
+	Preferences(w http.ResponseWriter, r *http.Request)
+	UpdatePreferences(w http.ResponseWriter, r *http.Request)
+}
+
+// Маршруты требуют аутентифицированного пользователя
+func newRouter(controller Controller) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /me/notifications", controller.Preferences)
+	mux.HandleFunc("PUT /me/notifications", controller.UpdatePreferences)
+	return mux
+}
@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type PreferencesService interface {
+	Preferences(ctx context.Context, userId uuid.UUID) (Preferences, *shared.DomainError)
+}
+
+type WarningsAggregator interface {
+	// Возвращает `true`, если предупреждение войдет в сводку
+	Collect(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning, digest bool) (bool, error)
+}
+
+// Применяет настройки пользователя перед доставкой. Коды подтверждения
+// и обязательные предупреждения отправляются всегда и без задержки,
+// но только в выбранные каналы.
+type Sender struct {
+	log                *logger.Logger
+	preferencesService PreferencesService
+	// Необязателен, без него предупреждения отправляются по отдельности
+	aggregator WarningsAggregator
+	next       messages_sender.Sender
+	now        func() time.Time
+}
+
+func NewSender(
+	log *logger.Logger,
+	preferencesService PreferencesService,
+	aggregator WarningsAggregator,
+	next messages_sender.Sender,
+	now func() time.Time,
+) *Sender {
+	return &Sender{
+		log:                log,
+		preferencesService: preferencesService,
+		aggregator:         aggregator,
+		next:               next,
+		now:                now,
+	}
+}
+
+func (s *Sender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	p, err := s.preferencesService.Preferences(ctx, userId)
+	if err != nil {
+		return err.Err
+	}
+	if Mandatory(warning.Event) {
+		return s.next.SendWarning(messages_sender.WithChannels(ctx, p.Channels), userId, warning)
+	}
+	if !slices.Contains(p.Events, warning.Event) {
+		s.log.Debug(
+			ctx,
+			"warning is disabled by user",
+			slog.String("user_id", userId.String()),
+			slog.String("event", string(warning.Event)),
+		)
+		return nil
+	}
+	if p.QuietHours != nil {
+		if until, ok := p.QuietHours.Until(s.now()); ok {
+			return &messages_sender.PostponedError{Until: until}
+		}
+	}
+	if s.aggregator == nil {
+		return s.next.SendWarning(messages_sender.WithChannels(ctx, p.Channels), userId, warning)
+	}
+	collected, aErr := s.aggregator.Collect(ctx, userId, warning, p.Digest)
+	if aErr != nil {
+		return aErr
+	}
+	if collected {
+		return nil
+	}
+	return s.next.SendWarning(messages_sender.WithChannels(ctx, p.Channels), userId, warning)
+}
+
+func (s *Sender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	p, err := s.preferencesService.Preferences(ctx, userId)
+	if err != nil {
+		return err.Err
+	}
+	if p.QuietHours != nil {
+		if until, ok := p.QuietHours.Until(s.now()); ok {
+			return &messages_sender.PostponedError{Until: until}
+		}
+	}
+	return s.next.SendDigest(messages_sender.WithChannels(ctx, p.Channels), userId, digest)
+}
+
+func (s *Sender) SendVerificationCode(ctx context.Context, userId uuid.UUID, code string) error {
+	p, err := s.preferencesService.Preferences(ctx, userId)
+	if err != nil {
+		return err.Err
+	}
+	return s.next.SendVerificationCode(messages_sender.WithChannels(ctx, p.Channels), userId, code)
+}
@@ -0,0 +1,241 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+func withChannels(channels ...string) any {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		actual, ok := messages_sender.ChannelsFromContext(ctx)
+		return ok && slices.Equal(actual, channels)
+	})
+}
+
+var errTest = errors.New("test error")
+
+func TestSender(t *testing.T) {
+	now := time.Date(2024, 11, 20, 23, 0, 0, 0, time.UTC)
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.SuspiciousRefreshWarning,
+		IpAddress: "127.0.0.1",
+	}
+	digest := messages_sender.Digest{
+		Events: []messages_sender.WarningEvent{messages_sender.SuspiciousRefreshWarning},
+		Count:  3,
+	}
+	reuse := messages_sender.Warning{
+		Event:     messages_sender.TokenReuseWarning,
+		IpAddress: "127.0.0.1",
+	}
+	night := &QuietHours{Start: 22 * 60, End: 7 * 60, Location: time.UTC}
+	cases := []struct {
+		name  string
+		send  func(s *Sender) error
+		setup func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender)
+		// Без агрегатора предупреждения отправляются по отдельности
+		aggregate bool
+		err       error
+	}{
+		{
+			name: "should deliver warning to enabled channels",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   messages_sender.WarningEvents,
+					Channels: []string{"sms"},
+				}, nil)
+				next.EXPECT().SendWarning(withChannels("sms"), userId, warning).Return(nil)
+			},
+		},
+		{
+			name: "should drop disabled warning",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+					Channels: []string{"email"},
+				}, nil)
+			},
+		},
+		{
+			name: "should postpone warning in quiet hours",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:     messages_sender.WarningEvents,
+					Channels:   []string{"email"},
+					QuietHours: night,
+				}, nil)
+			},
+			err: &messages_sender.PostponedError{Until: time.Date(2024, 11, 21, 7, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "should deliver token reuse warning immediately",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, reuse)
+			},
+			aggregate: true,
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:     nil,
+					Channels:   []string{"sms"},
+					QuietHours: night,
+					Digest:     true,
+				}, nil)
+				next.EXPECT().SendWarning(withChannels("sms"), userId, reuse).Return(nil)
+			},
+		},
+		{
+			name: "should send verification code in quiet hours",
+			send: func(s *Sender) error {
+				return s.SendVerificationCode(context.Background(), userId, "123456")
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:     nil,
+					Channels:   []string{"email", "webhook"},
+					QuietHours: night,
+				}, nil)
+				next.EXPECT().SendVerificationCode(withChannels("email", "webhook"), userId, "123456").Return(nil)
+			},
+		},
+		{
+			name: "should deliver warning outside aggregation window",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			aggregate: true,
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   messages_sender.WarningEvents,
+					Channels: []string{"email"},
+				}, nil)
+				agg.EXPECT().Collect(mock.Anything, userId, warning, false).Return(false, nil)
+				next.EXPECT().SendWarning(withChannels("email"), userId, warning).Return(nil)
+			},
+		},
+		{
+			name: "should not send collected warning",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			aggregate: true,
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   messages_sender.WarningEvents,
+					Channels: []string{"email"},
+					Digest:   true,
+				}, nil)
+				agg.EXPECT().Collect(mock.Anything, userId, warning, true).Return(true, nil)
+			},
+		},
+		{
+			name: "should return delivery error for retry",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			aggregate: true,
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   messages_sender.WarningEvents,
+					Channels: []string{"email"},
+				}, nil)
+				agg.EXPECT().Collect(mock.Anything, userId, warning, false).Return(false, nil)
+				next.EXPECT().SendWarning(withChannels("email"), userId, warning).Return(errTest)
+			},
+			err: errTest,
+		},
+		{
+			name: "should deliver digest to enabled channels",
+			send: func(s *Sender) error {
+				return s.SendDigest(context.Background(), userId, digest)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:   nil,
+					Channels: []string{"webhook"},
+				}, nil)
+				next.EXPECT().SendDigest(withChannels("webhook"), userId, digest).Return(nil)
+			},
+		},
+		{
+			name: "should postpone digest in quiet hours",
+			send: func(s *Sender) error {
+				return s.SendDigest(context.Background(), userId, digest)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{
+					Events:     messages_sender.WarningEvents,
+					Channels:   []string{"email"},
+					QuietHours: night,
+				}, nil)
+			},
+			err: &messages_sender.PostponedError{Until: time.Date(2024, 11, 21, 7, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "should fail if preferences are unavailable",
+			send: func(s *Sender) error {
+				return s.SendWarning(context.Background(), userId, warning)
+			},
+			setup: func(prefs *MockPreferencesService, agg *MockWarningsAggregator, next *messages_sender.MockSender) {
+				prefs.EXPECT().Preferences(mock.Anything, userId).Return(Preferences{}, shared.NewUnexpectedError(
+					ErrFailedToGetPreferences,
+					"failed to get preferences",
+				))
+			},
+			err: ErrFailedToGetPreferences,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logger.New(slog.New(slog.NewTextHandler(&buf, nil)))
+			prefs := NewMockPreferencesService(t)
+			agg := NewMockWarningsAggregator(t)
+			next := messages_sender.NewMockSender(t)
+			tc.setup(prefs, agg, next)
+			var aggregator WarningsAggregator
+			if tc.aggregate {
+				aggregator = agg
+			}
+			s := NewSender(log, prefs, aggregator, next, func() time.Time { return now })
+			err := tc.send(s)
+			if tc.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var expected *messages_sender.PostponedError
+			if errors.As(tc.err, &expected) {
+				var actual *messages_sender.PostponedError
+				if !errors.As(err, &actual) || !actual.Until.Equal(expected.Until) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var ErrFailedToGetPreferences = errors.New("failed to get preferences")
+var ErrFailedToUpdatePreferences = errors.New("failed to update preferences")
+var ErrLoginRequired = errors.New("login required")
+
+// Сведения о клиенте, изменяющем настройки
+type Client struct {
+	IpAddress string
+	UserAgent string
+	// Время входа, с которого началась сессия клиента
+	AuthTime time.Time
+}
+
+type service[T any] struct {
+	log             *logger.Logger
+	cfg             Config
+	preferencesRepo PreferencesRepository[T]
+	auditLog        audit.AuditLog[T]
+	uowFactory      unit_of_work.Factory[T]
+	now             func() time.Time
+}
+
+func newService[T any](
+	log *logger.Logger,
+	cfg Config,
+	preferencesRepo PreferencesRepository[T],
+	auditLog audit.AuditLog[T],
+	uowFactory unit_of_work.Factory[T],
+	now func() time.Time,
+) *service[T] {
+	return &service[T]{
+		log:             log,
+		cfg:             cfg,
+		preferencesRepo: preferencesRepo,
+		auditLog:        auditLog,
+		uowFactory:      uowFactory,
+		now:             now,
+	}
+}
+
+func (s *service[T]) Preferences(ctx context.Context, userId uuid.UUID) (Preferences, *shared.DomainError) {
+	p, err := s.preferencesRepo.Preferences(ctx, userId)
+	if errors.Is(err, shared.ErrNotFound) {
+		return DefaultPreferences(s.cfg.Channels), nil
+	}
+	if err != nil {
+		return Preferences{}, shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToGetPreferences, err),
+			"failed to get preferences",
+		)
+	}
+	// Каналы могли быть отключены в конфигурации после сохранения настроек,
+	// без каналов пользователь не получил бы коды подтверждения
+	p.Channels = slices.DeleteFunc(p.Channels, func(c string) bool {
+		return !slices.Contains(s.cfg.Channels, c)
+	})
+	if len(p.Channels) == 0 {
+		p.Channels = s.cfg.Channels
+	}
+	// Настройки могли быть сохранены до появления обязательных предупреждений
+	for _, e := range messages_sender.WarningEvents {
+		if Mandatory(e) && !slices.Contains(p.Events, e) {
+			p.Events = append(p.Events, e)
+		}
+	}
+	return p, nil
+}
+
+// Похищенный Access токен не должен позволять скрыть предупреждения
+// от владельца, поэтому требуется недавний вход, а каждое изменение
+// записывается в журнал аудита.
+func (s *service[T]) UpdatePreferences(
+	ctx context.Context,
+	userId uuid.UUID,
+	p Preferences,
+	client Client,
+) *shared.DomainError {
+	if client.AuthTime.IsZero() || s.now().Sub(client.AuthTime) > s.cfg.FreshLoginMaxAge {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToUpdatePreferences, ErrLoginRequired),
+			"recent login is required",
+		)
+	}
+	if err := s.validate(p); err != nil {
+		return shared.NewDomainError(
+			fmt.Errorf("%w: %w", ErrFailedToUpdatePreferences, err),
+			err.Error(),
+		)
+	}
+	uow, err := s.uowFactory(ctx)
+	if err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: create unit of work: %s", ErrFailedToUpdatePreferences, err),
+			"failed to update preferences",
+		)
+	}
+	defer func() {
+		if err := uow.Rollback(ctx); err != nil {
+			s.log.Error(ctx, "failed to rollback unit of work", sl.Err(err))
+		}
+	}()
+	now := s.now()
+	if err := s.preferencesRepo.SavePreferences(ctx, uow, userId, p, now); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: %s", ErrFailedToUpdatePreferences, err),
+			"failed to update preferences",
+		)
+	}
+	if err := s.auditLog.Record(ctx, uow, audit.Event{
+		Id:        uuid.New(),
+		Type:      audit.PreferencesEvent,
+		Outcome:   audit.SuccessOutcome,
+		UserId:    userId,
+		IpAddress: client.IpAddress,
+		UserAgent: client.UserAgent,
+		Reason:    "notification preferences changed",
+		CreatedAt: now,
+	}); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: record audit event: %s", ErrFailedToUpdatePreferences, err),
+			"failed to update preferences",
+		)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return shared.NewUnexpectedError(
+			fmt.Errorf("%w: commit: %s", ErrFailedToUpdatePreferences, err),
+			"failed to update preferences",
+		)
+	}
+	return nil
+}
+
+func (s *service[T]) validate(p Preferences) error {
+	for i, e := range p.Events {
+		if !e.Valid() {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidPreferences, e)
+		}
+		if slices.Contains(p.Events[:i], e) {
+			return fmt.Errorf("%w: duplicate event %q", ErrInvalidPreferences, e)
+		}
+	}
+	for _, e := range messages_sender.WarningEvents {
+		if Mandatory(e) && !slices.Contains(p.Events, e) {
+			return fmt.Errorf("%w: event %q can not be disabled", ErrInvalidPreferences, e)
+		}
+	}
+	if len(p.Channels) == 0 {
+		return fmt.Errorf("%w: at least one channel is required", ErrInvalidPreferences)
+	}
+	for i, c := range p.Channels {
+		if !slices.Contains(s.cfg.Channels, c) {
+			return fmt.Errorf("%w: unavailable channel %q", ErrInvalidPreferences, c)
+		}
+		if slices.Contains(p.Channels[:i], c) {
+			return fmt.Errorf("%w: duplicate channel %q", ErrInvalidPreferences, c)
+		}
+	}
+	if q := p.QuietHours; q != nil {
+		if q.Location == nil {
+			return fmt.Errorf("%w: quiet hours timezone is required", ErrInvalidPreferences)
+		}
+		if q.Start == q.End {
+			return fmt.Errorf("%w: quiet hours must not be empty", ErrInvalidPreferences)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/audit"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+var testConfig = Config{
+	Channels:         []string{messages_sender.EmailChannel, messages_sender.SmsChannel},
+	FreshLoginMaxAge: 10 * time.Minute,
+}
+
+type serviceMocks struct {
+	repo       *MockPreferencesRepository[any]
+	auditLog   *audit.MockAuditLog[any]
+	uowFactory *unit_of_work.MockFactory[any]
+	uow        *unit_of_work.MockUnitOfWork[any]
+}
+
+func newTestService(t *testing.T, now time.Time, setup func(serviceMocks)) *service[any] {
+	var buf bytes.Buffer
+	log := logger.New(slog.New(slog.NewTextHandler(&buf, nil)))
+	m := serviceMocks{
+		repo:       NewMockPreferencesRepository[any](t),
+		auditLog:   audit.NewMockAuditLog[any](t),
+		uowFactory: unit_of_work.NewMockFactory[any](t),
+		uow:        unit_of_work.NewMockUnitOfWork[any](t),
+	}
+	if setup != nil {
+		setup(m)
+	}
+	return newService(log, testConfig, m.repo, m.auditLog, m.uowFactory.Execute, func() time.Time { return now })
+}
+
+func TestServicePreferences(t *testing.T) {
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	cases := []struct {
+		name     string
+		stored   Preferences
+		err      error
+		channels []string
+		events   []messages_sender.WarningEvent
+	}{
+		{
+			name:     "should use defaults for user without preferences",
+			err:      shared.ErrNotFound,
+			channels: testConfig.Channels,
+			events:   messages_sender.WarningEvents,
+		},
+		{
+			name: "should drop unavailable channels",
+			stored: Preferences{
+				Events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+				Channels: []string{messages_sender.WebhookChannel, messages_sender.SmsChannel},
+			},
+			channels: []string{messages_sender.SmsChannel},
+			events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+		},
+		{
+			name: "should fall back to available channels",
+			stored: Preferences{
+				Events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+				Channels: []string{messages_sender.WebhookChannel},
+			},
+			channels: testConfig.Channels,
+			events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+		},
+		{
+			name: "should enable mandatory events",
+			stored: Preferences{
+				Channels: []string{messages_sender.SmsChannel},
+			},
+			channels: []string{messages_sender.SmsChannel},
+			events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, time.Now(), func(m serviceMocks) {
+				m.repo.EXPECT().Preferences(mock.Anything, userId).Return(tc.stored, tc.err)
+			})
+			p, err := s.Preferences(context.Background(), userId)
+			if err != nil {
+				t.Fatal(err.Err)
+			}
+			if !slices.Equal(p.Channels, tc.channels) {
+				t.Errorf("expected channels %v, got %v", tc.channels, p.Channels)
+			}
+			if !slices.Equal(p.Events, tc.events) {
+				t.Errorf("expected events %v, got %v", tc.events, p.Events)
+			}
+		})
+	}
+}
+
+func TestServiceUpdatePreferences(t *testing.T) {
+	now := time.Now()
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	valid := Preferences{
+		Events:   []messages_sender.WarningEvent{messages_sender.TokenReuseWarning},
+		Channels: []string{messages_sender.SmsChannel},
+		QuietHours: &QuietHours{
+			Start:    22 * 60,
+			End:      7 * 60,
+			Location: time.UTC,
+		},
+		Digest: true,
+	}
+	with := func(update func(p *Preferences)) Preferences {
+		p := valid
+		update(&p)
+		return p
+	}
+	client := Client{
+		IpAddress: "127.0.0.1",
+		UserAgent: "test",
+		AuthTime:  now.Add(-time.Minute),
+	}
+	cases := []struct {
+		name        string
+		preferences Preferences
+		invalid     bool
+	}{
+		{
+			name:        "should save valid preferences",
+			preferences: valid,
+		},
+		{
+			name: "should reject disabling mandatory events",
+			preferences: with(func(p *Preferences) {
+				p.Events = nil
+			}),
+			invalid: true,
+		},
+		{
+			name: "should reject unknown event",
+			preferences: with(func(p *Preferences) {
+				p.Events = []messages_sender.WarningEvent{"login"}
+			}),
+			invalid: true,
+		},
+		{
+			name: "should reject duplicate channel",
+			preferences: with(func(p *Preferences) {
+				p.Channels = []string{messages_sender.SmsChannel, messages_sender.SmsChannel}
+			}),
+			invalid: true,
+		},
+		{
+			name: "should reject unavailable channel",
+			preferences: with(func(p *Preferences) {
+				p.Channels = []string{messages_sender.WebhookChannel}
+			}),
+			invalid: true,
+		},
+		{
+			name: "should require a channel",
+			preferences: with(func(p *Preferences) {
+				p.Channels = nil
+			}),
+			invalid: true,
+		},
+		{
+			name: "should reject empty quiet hours",
+			preferences: with(func(p *Preferences) {
+				p.QuietHours = &QuietHours{Start: 60, End: 60, Location: time.UTC}
+			}),
+			invalid: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, now, func(m serviceMocks) {
+				if tc.invalid {
+					return
+				}
+				m.uowFactory.EXPECT().Execute(mock.Anything).Return(m.uow, nil)
+				m.uow.EXPECT().Rollback(mock.Anything).Return(nil)
+				m.uow.EXPECT().Commit(mock.Anything).Return(nil)
+				m.repo.EXPECT().SavePreferences(mock.Anything, m.uow, userId, tc.preferences, now).Return(nil)
+				m.auditLog.EXPECT().Record(mock.Anything, m.uow, mock.MatchedBy(func(e audit.Event) bool {
+					return e.Type == audit.PreferencesEvent &&
+						e.Outcome == audit.SuccessOutcome &&
+						e.UserId == userId &&
+						e.IpAddress == client.IpAddress &&
+						e.UserAgent == client.UserAgent
+				})).Return(nil)
+			})
+			err := s.UpdatePreferences(context.Background(), userId, tc.preferences, client)
+			if !tc.invalid {
+				if err != nil {
+					t.Fatal(err.Err)
+				}
+				return
+			}
+			if err == nil || !err.Expected || !errors.Is(err.Err, ErrInvalidPreferences) {
+				t.Fatalf("expected invalid preferences error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestServiceUpdatePreferencesRequiresFreshLogin(t *testing.T) {
+	now := time.Now()
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	preferences := DefaultPreferences(testConfig.Channels)
+	cases := []struct {
+		name     string
+		authTime time.Time
+	}{
+		{
+			name:     "should reject stale login",
+			authTime: now.Add(-testConfig.FreshLoginMaxAge - time.Second),
+		},
+		{
+			name: "should reject token without login time",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, now, nil)
+			err := s.UpdatePreferences(context.Background(), userId, preferences, Client{AuthTime: tc.authTime})
+			if err == nil || !err.Expected || !errors.Is(err.Err, ErrLoginRequired) {
+				t.Fatalf("expected login required error, got %v", err)
+			}
+		})
+	}
+}
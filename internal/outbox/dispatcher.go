@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger/sl"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var ErrUnknownKind = errors.New("unknown message kind")
+var ErrInvalidPayload = errors.New("invalid message payload")
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// После указанного числа неудачных попыток сообщение переносится
+	// в недоставленные
+	MaxAttempts int
+	// Задержка перед повторной попыткой удваивается, но не превышает `MaxBackoff`
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Время, на которое сообщение захватывается для доставки
+	Lease time.Duration
+}
+
+type Dispatcher struct {
+	log    *logger.Logger
+	repo   Repository
+	sender MessagesSender
+	cfg    Config
+	now    func() time.Time
+}
+
+func NewDispatcher(
+	log *logger.Logger,
+	repo Repository,
+	sender MessagesSender,
+	cfg Config,
+	now func() time.Time,
+) *Dispatcher {
+	return &Dispatcher{
+		log:    log,
+		repo:   repo,
+		sender: sender,
+		cfg:    cfg,
+		now:    now,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				d.log.Error(ctx, "failed to dispatch messages", sl.Err(err))
+				break
+			}
+			if n < d.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Доставляет порцию сообщений и возвращает их количество
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.now()
+	messages, err := d.repo.Claim(ctx, now, d.cfg.BatchSize, now.Add(d.cfg.Lease))
+	if err != nil {
+		return 0, fmt.Errorf("claim messages: %w", err)
+	}
+	for _, m := range messages {
+		d.dispatch(ctx, m)
+	}
+	return len(messages), nil
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, m Message) {
+	log := d.log.With(
+		slog.String("message_id", m.Id.String()),
+		slog.String("kind", string(m.Kind)),
+		slog.Int("attempts", m.Attempts),
+	)
+	err := d.deliver(ctx, m)
+	if err == nil {
+		if err := d.repo.Delete(ctx, m.Id); err != nil {
+			log.Error(ctx, "failed to delete delivered message", sl.Err(err))
+		}
+		return
+	}
+	var postponed *messages_sender.PostponedError
+	if errors.As(err, &postponed) {
+		log.Debug(ctx, "message delivery postponed", slog.Time("until", postponed.Until))
+		if err := d.repo.Postpone(ctx, m.Id, postponed.Until); err != nil {
+			log.Error(ctx, "failed to postpone message", sl.Err(err))
+		}
+		return
+	}
+	// Повторные попытки не исправят сообщение, которое невозможно разобрать
+	if m.Attempts >= d.cfg.MaxAttempts || errors.Is(err, ErrUnknownKind) || errors.Is(err, ErrInvalidPayload) {
+		log.Error(ctx, "message moved to dead letters", sl.Err(err))
+		if err := d.repo.DeadLetter(ctx, m.Id, err.Error()); err != nil {
+			log.Error(ctx, "failed to dead letter message", sl.Err(err))
+		}
+		return
+	}
+	log.Warn(ctx, "failed to deliver message", sl.Err(err))
+	if err := d.repo.Retry(ctx, m.Id, d.now().Add(d.backoff(m.Attempts)), err.Error()); err != nil {
+		log.Error(ctx, "failed to schedule message retry", sl.Err(err))
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, m Message) error {
+	ctx = messages_sender.WithDeliveryId(ctx, m.Id)
+	switch m.Kind {
+	case WarningKind:
+		var warning messages_sender.Warning
+		if err := json.Unmarshal([]byte(m.Payload), &warning); err != nil {
+			return fmt.Errorf("%w: decode warning: %s", ErrInvalidPayload, err)
+		}
+		return d.sender.SendWarning(ctx, m.UserId, warning)
+	case DigestKind:
+		var digest messages_sender.Digest
+		if err := json.Unmarshal([]byte(m.Payload), &digest); err != nil {
+			return fmt.Errorf("%w: decode digest: %s", ErrInvalidPayload, err)
+		}
+		return d.sender.SendDigest(ctx, m.UserId, digest)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownKind, m.Kind)
+	}
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
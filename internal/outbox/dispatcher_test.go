@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+var testConfig = Config{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	BaseBackoff:  10 * time.Second,
+	MaxBackoff:   time.Minute,
+	Lease:        time.Minute,
+}
+
+type dispatcherMocks struct {
+	repo   *MockRepository
+	sender *MockMessagesSender
+}
+
+func newTestDispatcher(t *testing.T, now time.Time, setup func(dispatcherMocks)) *Dispatcher {
+	var buf bytes.Buffer
+	log := logger.New(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+	repo := NewMockRepository(t)
+	sender := NewMockMessagesSender(t)
+	if setup != nil {
+		setup(dispatcherMocks{
+			repo:   repo,
+			sender: sender,
+		})
+	}
+	return NewDispatcher(log, repo, sender, testConfig, func() time.Time { return now })
+}
+
+func TestDispatcherDispatch(t *testing.T) {
+	now := time.Now()
+	userId := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	warning := messages_sender.Warning{
+		Event:     messages_sender.SuspiciousRefreshWarning,
+		Time:      now.UTC(),
+		IpAddress: "127.0.0.2",
+	}
+	message, err := Warning(userId, warning, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := messages_sender.Digest{
+		Events:      []messages_sender.WarningEvent{messages_sender.SuspiciousRefreshWarning},
+		Count:       2,
+		Since:       now.UTC().Add(-time.Minute),
+		Until:       now.UTC(),
+		IpAddresses: []string{"127.0.0.2"},
+	}
+	digestMessage, err := Digest(userId, digest, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendErr := errors.New("smtp is down")
+	claim := func(m dispatcherMocks, messages ...Message) {
+		m.repo.EXPECT().
+			Claim(mock.Anything, now, testConfig.BatchSize, now.Add(testConfig.Lease)).
+			Return(messages, nil)
+	}
+	withAttempts := func(m Message, attempts int) Message {
+		m.Attempts = attempts
+		return m
+	}
+	cases := []struct {
+		name  string
+		setup func(dispatcherMocks)
+		n     int
+		err   bool
+	}{
+		{
+			name: "should delete delivered message",
+			setup: func(m dispatcherMocks) {
+				claim(m, withAttempts(message, 1))
+				m.sender.EXPECT().SendWarning(mock.MatchedBy(func(ctx context.Context) bool {
+					id, ok := messages_sender.DeliveryIdFromContext(ctx)
+					return ok && id == message.Id
+				}), userId, warning).Return(nil)
+				m.repo.EXPECT().Delete(mock.Anything, message.Id).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should retry failed message with backoff",
+			setup: func(m dispatcherMocks) {
+				claim(m, withAttempts(message, 2))
+				m.sender.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+				m.repo.EXPECT().Retry(mock.Anything, message.Id, now.Add(20*time.Second), sendErr.Error()).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should dead letter message after max attempts",
+			setup: func(m dispatcherMocks) {
+				claim(m, withAttempts(message, 3))
+				m.sender.EXPECT().SendWarning(mock.Anything, userId, warning).Return(sendErr)
+				m.repo.EXPECT().DeadLetter(mock.Anything, message.Id, sendErr.Error()).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should postpone message without spending attempt",
+			setup: func(m dispatcherMocks) {
+				claim(m, withAttempts(message, 3))
+				until := now.Add(8 * time.Hour)
+				m.sender.EXPECT().
+					SendWarning(mock.Anything, userId, warning).
+					Return(&messages_sender.PostponedError{Until: until})
+				m.repo.EXPECT().Postpone(mock.Anything, message.Id, until).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should deliver digest",
+			setup: func(m dispatcherMocks) {
+				claim(m, withAttempts(digestMessage, 1))
+				m.sender.EXPECT().SendDigest(mock.Anything, userId, digest).Return(nil)
+				m.repo.EXPECT().Delete(mock.Anything, digestMessage.Id).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should dead letter message of unknown kind",
+			setup: func(m dispatcherMocks) {
+				unknown := withAttempts(message, 1)
+				unknown.Kind = "unknown"
+				claim(m, unknown)
+				m.repo.EXPECT().DeadLetter(mock.Anything, message.Id, mock.AnythingOfType("string")).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should dead letter message with invalid payload",
+			setup: func(m dispatcherMocks) {
+				invalid := withAttempts(message, 1)
+				invalid.Payload = "not json"
+				claim(m, invalid)
+				m.repo.EXPECT().DeadLetter(mock.Anything, message.Id, mock.AnythingOfType("string")).Return(nil)
+			},
+			n: 1,
+		},
+		{
+			name: "should return claim error",
+			setup: func(m dispatcherMocks) {
+				m.repo.EXPECT().
+					Claim(mock.Anything, now, testConfig.BatchSize, now.Add(testConfig.Lease)).
+					Return(nil, errors.New("connection refused"))
+			},
+			err: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newTestDispatcher(t, now, tc.setup)
+			n, err := d.Dispatch(context.Background())
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != tc.n {
+				t.Errorf("expected %d messages, got %d", tc.n, n)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := newTestDispatcher(t, time.Now(), nil)
+	cases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 10 * time.Second},
+		{attempts: 2, expected: 20 * time.Second},
+		{attempts: 3, expected: 40 * time.Second},
+		{attempts: 4, expected: time.Minute},
+		{attempts: 100, expected: time.Minute},
+	}
+	for _, tc := range cases {
+		if actual := d.backoff(tc.attempts); actual != tc.expected {
+			t.Errorf("attempts %d: expected %s, got %s", tc.attempts, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package outbox
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	messages_sender "github.com/x0k/medods-authentication-service/internal/messages_sender"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockMessagesSender is an autogenerated mock type for the MessagesSender type
+type MockMessagesSender struct {
+	mock.Mock
+}
+
+type MockMessagesSender_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockMessagesSender) EXPECT() *MockMessagesSender_Expecter {
+	return &MockMessagesSender_Expecter{mock: &_m.Mock}
+}
+
+// SendDigest provides a mock function with given fields: ctx, userId, digest
+func (_m *MockMessagesSender) SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error {
+	ret := _m.Called(ctx, userId, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendDigest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, messages_sender.Digest) error); ok {
+		r0 = rf(ctx, userId, digest)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMessagesSender_SendDigest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendDigest'
+type MockMessagesSender_SendDigest_Call struct {
+	*mock.Call
+}
+
+// SendDigest is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - digest messages_sender.Digest
+func (_e *MockMessagesSender_Expecter) SendDigest(ctx interface{}, userId interface{}, digest interface{}) *MockMessagesSender_SendDigest_Call {
+	return &MockMessagesSender_SendDigest_Call{Call: _e.mock.On("SendDigest", ctx, userId, digest)}
+}
+
+func (_c *MockMessagesSender_SendDigest_Call) Run(run func(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest)) *MockMessagesSender_SendDigest_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(messages_sender.Digest))
+	})
+	return _c
+}
+
+func (_c *MockMessagesSender_SendDigest_Call) Return(_a0 error) *MockMessagesSender_SendDigest_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMessagesSender_SendDigest_Call) RunAndReturn(run func(context.Context, uuid.UUID, messages_sender.Digest) error) *MockMessagesSender_SendDigest_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SendWarning provides a mock function with given fields: ctx, userId, warning
+func (_m *MockMessagesSender) SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error {
+	ret := _m.Called(ctx, userId, warning)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendWarning")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, messages_sender.Warning) error); ok {
+		r0 = rf(ctx, userId, warning)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMessagesSender_SendWarning_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendWarning'
+type MockMessagesSender_SendWarning_Call struct {
+	*mock.Call
+}
+
+// SendWarning is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userId uuid.UUID
+//   - warning messages_sender.Warning
+func (_e *MockMessagesSender_Expecter) SendWarning(ctx interface{}, userId interface{}, warning interface{}) *MockMessagesSender_SendWarning_Call {
+	return &MockMessagesSender_SendWarning_Call{Call: _e.mock.On("SendWarning", ctx, userId, warning)}
+}
+
+func (_c *MockMessagesSender_SendWarning_Call) Run(run func(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning)) *MockMessagesSender_SendWarning_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(messages_sender.Warning))
+	})
+	return _c
+}
+
+func (_c *MockMessagesSender_SendWarning_Call) Return(_a0 error) *MockMessagesSender_SendWarning_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMessagesSender_SendWarning_Call) RunAndReturn(run func(context.Context, uuid.UUID, messages_sender.Warning) error) *MockMessagesSender_SendWarning_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockMessagesSender creates a new instance of MockMessagesSender. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockMessagesSender(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockMessagesSender {
+	mock := &MockMessagesSender{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,85 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package outbox
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	unit_of_work "github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+// MockOutbox is an autogenerated mock type for the Outbox type
+type MockOutbox[T any] struct {
+	mock.Mock
+}
+
+type MockOutbox_Expecter[T any] struct {
+	mock *mock.Mock
+}
+
+func (_m *MockOutbox[T]) EXPECT() *MockOutbox_Expecter[T] {
+	return &MockOutbox_Expecter[T]{mock: &_m.Mock}
+}
+
+// Enqueue provides a mock function with given fields: ctx, uow, message
+func (_m *MockOutbox[T]) Enqueue(ctx context.Context, uow unit_of_work.UnitOfWork[T], message Message) error {
+	ret := _m.Called(ctx, uow, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Enqueue")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, unit_of_work.UnitOfWork[T], Message) error); ok {
+		r0 = rf(ctx, uow, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockOutbox_Enqueue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Enqueue'
+type MockOutbox_Enqueue_Call[T any] struct {
+	*mock.Call
+}
+
+// Enqueue is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uow unit_of_work.UnitOfWork[T]
+//   - message Message
+func (_e *MockOutbox_Expecter[T]) Enqueue(ctx interface{}, uow interface{}, message interface{}) *MockOutbox_Enqueue_Call[T] {
+	return &MockOutbox_Enqueue_Call[T]{Call: _e.mock.On("Enqueue", ctx, uow, message)}
+}
+
+func (_c *MockOutbox_Enqueue_Call[T]) Run(run func(ctx context.Context, uow unit_of_work.UnitOfWork[T], message Message)) *MockOutbox_Enqueue_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(unit_of_work.UnitOfWork[T]), args[2].(Message))
+	})
+	return _c
+}
+
+func (_c *MockOutbox_Enqueue_Call[T]) Return(_a0 error) *MockOutbox_Enqueue_Call[T] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockOutbox_Enqueue_Call[T]) RunAndReturn(run func(context.Context, unit_of_work.UnitOfWork[T], Message) error) *MockOutbox_Enqueue_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockOutbox creates a new instance of MockOutbox. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockOutbox[T any](t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockOutbox[T] {
+	mock := &MockOutbox[T]{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,292 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package outbox
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockRepository is an autogenerated mock type for the Repository type
+type MockRepository struct {
+	mock.Mock
+}
+
+type MockRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockRepository) EXPECT() *MockRepository_Expecter {
+	return &MockRepository_Expecter{mock: &_m.Mock}
+}
+
+// Claim provides a mock function with given fields: ctx, now, limit, leaseUntil
+func (_m *MockRepository) Claim(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]Message, error) {
+	ret := _m.Called(ctx, now, limit, leaseUntil)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Claim")
+	}
+
+	var r0 []Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int, time.Time) ([]Message, error)); ok {
+		return rf(ctx, now, limit, leaseUntil)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int, time.Time) []Message); ok {
+		r0 = rf(ctx, now, limit, leaseUntil)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int, time.Time) error); ok {
+		r1 = rf(ctx, now, limit, leaseUntil)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRepository_Claim_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Claim'
+type MockRepository_Claim_Call struct {
+	*mock.Call
+}
+
+// Claim is a helper method to define mock.On call
+//   - ctx context.Context
+//   - now time.Time
+//   - limit int
+//   - leaseUntil time.Time
+func (_e *MockRepository_Expecter) Claim(ctx interface{}, now interface{}, limit interface{}, leaseUntil interface{}) *MockRepository_Claim_Call {
+	return &MockRepository_Claim_Call{Call: _e.mock.On("Claim", ctx, now, limit, leaseUntil)}
+}
+
+func (_c *MockRepository_Claim_Call) Run(run func(ctx context.Context, now time.Time, limit int, leaseUntil time.Time)) *MockRepository_Claim_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time), args[2].(int), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRepository_Claim_Call) Return(_a0 []Message, _a1 error) *MockRepository_Claim_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRepository_Claim_Call) RunAndReturn(run func(context.Context, time.Time, int, time.Time) ([]Message, error)) *MockRepository_Claim_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeadLetter provides a mock function with given fields: ctx, id, lastError
+func (_m *MockRepository) DeadLetter(ctx context.Context, id uuid.UUID, lastError string) error {
+	ret := _m.Called(ctx, id, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeadLetter")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, id, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_DeadLetter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeadLetter'
+type MockRepository_DeadLetter_Call struct {
+	*mock.Call
+}
+
+// DeadLetter is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+//   - lastError string
+func (_e *MockRepository_Expecter) DeadLetter(ctx interface{}, id interface{}, lastError interface{}) *MockRepository_DeadLetter_Call {
+	return &MockRepository_DeadLetter_Call{Call: _e.mock.On("DeadLetter", ctx, id, lastError)}
+}
+
+func (_c *MockRepository_DeadLetter_Call) Run(run func(ctx context.Context, id uuid.UUID, lastError string)) *MockRepository_DeadLetter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_DeadLetter_Call) Return(_a0 error) *MockRepository_DeadLetter_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_DeadLetter_Call) RunAndReturn(run func(context.Context, uuid.UUID, string) error) *MockRepository_DeadLetter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type MockRepository_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+func (_e *MockRepository_Expecter) Delete(ctx interface{}, id interface{}) *MockRepository_Delete_Call {
+	return &MockRepository_Delete_Call{Call: _e.mock.On("Delete", ctx, id)}
+}
+
+func (_c *MockRepository_Delete_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockRepository_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRepository_Delete_Call) Return(_a0 error) *MockRepository_Delete_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_Delete_Call) RunAndReturn(run func(context.Context, uuid.UUID) error) *MockRepository_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Postpone provides a mock function with given fields: ctx, id, nextAttemptAt
+func (_m *MockRepository) Postpone(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time) error {
+	ret := _m.Called(ctx, id, nextAttemptAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Postpone")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, id, nextAttemptAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_Postpone_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Postpone'
+type MockRepository_Postpone_Call struct {
+	*mock.Call
+}
+
+// Postpone is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+//   - nextAttemptAt time.Time
+func (_e *MockRepository_Expecter) Postpone(ctx interface{}, id interface{}, nextAttemptAt interface{}) *MockRepository_Postpone_Call {
+	return &MockRepository_Postpone_Call{Call: _e.mock.On("Postpone", ctx, id, nextAttemptAt)}
+}
+
+func (_c *MockRepository_Postpone_Call) Run(run func(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time)) *MockRepository_Postpone_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRepository_Postpone_Call) Return(_a0 error) *MockRepository_Postpone_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_Postpone_Call) RunAndReturn(run func(context.Context, uuid.UUID, time.Time) error) *MockRepository_Postpone_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Retry provides a mock function with given fields: ctx, id, nextAttemptAt, lastError
+func (_m *MockRepository) Retry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	ret := _m.Called(ctx, id, nextAttemptAt, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Retry")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time, string) error); ok {
+		r0 = rf(ctx, id, nextAttemptAt, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_Retry_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Retry'
+type MockRepository_Retry_Call struct {
+	*mock.Call
+}
+
+// Retry is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+//   - nextAttemptAt time.Time
+//   - lastError string
+func (_e *MockRepository_Expecter) Retry(ctx interface{}, id interface{}, nextAttemptAt interface{}, lastError interface{}) *MockRepository_Retry_Call {
+	return &MockRepository_Retry_Call{Call: _e.mock.On("Retry", ctx, id, nextAttemptAt, lastError)}
+}
+
+func (_c *MockRepository_Retry_Call) Run(run func(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string)) *MockRepository_Retry_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(time.Time), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_Retry_Call) Return(_a0 error) *MockRepository_Retry_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_Retry_Call) RunAndReturn(run func(context.Context, uuid.UUID, time.Time, string) error) *MockRepository_Retry_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockRepository creates a new instance of MockRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockRepository {
+	mock := &MockRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
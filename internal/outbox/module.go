@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+)
+
+type Module struct {
+	Outbox *PgOutbox
+	log    *logger.Logger
+	cfg    Config
+}
+
+func New(
+	log *logger.Logger,
+	pgxPool *pgxpool.Pool,
+	cfg Config,
+) *Module {
+	return &Module{
+		Outbox: NewPgOutbox(
+			log.With(slog.String("component", "outbox")),
+			pgxPool,
+		),
+		log: log,
+		cfg: cfg,
+	}
+}
+
+// Диспетчер создается отдельно, так как отправитель сам может
+// записывать сообщения в очередь
+func (m *Module) NewDispatcher(sender MessagesSender) *Dispatcher {
+	return NewDispatcher(
+		m.log.With(slog.String("component", "dispatcher")),
+		m.Outbox,
+		sender,
+		m.cfg,
+		time.Now,
+	)
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+	"github.com/x0k/medods-authentication-service/internal/messages_sender"
+)
+
+type Kind string
+
+const (
+	WarningKind Kind = "warning"
+	DigestKind  Kind = "digest"
+)
+
+type Message struct {
+	Id     uuid.UUID
+	Kind   Kind
+	UserId uuid.UUID
+	// Содержимое сообщения, интерпретируется в зависимости от вида
+	Payload string
+	// Количество попыток доставки, включая текущую
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// Запись выполняется в той же единице работы, что и изменение,
+// о котором сообщается пользователю
+type Outbox[T any] interface {
+	Enqueue(ctx context.Context, uow unit_of_work.UnitOfWork[T], message Message) error
+}
+
+type MessagesSender interface {
+	SendWarning(ctx context.Context, userId uuid.UUID, warning messages_sender.Warning) error
+	SendDigest(ctx context.Context, userId uuid.UUID, digest messages_sender.Digest) error
+}
+
+type Repository interface {
+	// Захватывает готовые к доставке сообщения до `leaseUntil`,
+	// сообщения захваченные другими экземплярами пропускаются
+	Claim(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]Message, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Retry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	// Переносит доставку без учета попытки
+	Postpone(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time) error
+	DeadLetter(ctx context.Context, id uuid.UUID, lastError string) error
+}
+
+func Warning(userId uuid.UUID, warning messages_sender.Warning, now time.Time) (Message, error) {
+	return newMessage(WarningKind, userId, warning, now)
+}
+
+func Digest(userId uuid.UUID, digest messages_sender.Digest, now time.Time) (Message, error) {
+	return newMessage(DigestKind, userId, digest, now)
+}
+
+func newMessage(kind Kind, userId uuid.UUID, payload any, now time.Time) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Id:        uuid.New(),
+		Kind:      kind,
+		UserId:    userId,
+		Payload:   string(data),
+		CreatedAt: now,
+	}, nil
+}
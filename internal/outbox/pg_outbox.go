@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/lib/unit_of_work"
+)
+
+type PgOutbox struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func NewPgOutbox(log *logger.Logger, pool *pgxpool.Pool) *PgOutbox {
+	return &PgOutbox{
+		log:  log,
+		pool: pool,
+	}
+}
+
+const enqueueMessageQuery = `INSERT INTO outbox_message (id, kind, user_id, payload, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $5)`
+
+func (o *PgOutbox) Enqueue(ctx context.Context, uow unit_of_work.UnitOfWork[pgx.Tx], message Message) error {
+	if message.Id == uuid.Nil {
+		message.Id = uuid.New()
+	}
+	args := []any{message.Id, message.Kind, message.UserId, message.Payload, message.CreatedAt}
+	o.log.Debug(ctx, "executing query", slog.String("query", enqueueMessageQuery), slog.Any("args", args[:3]))
+	_, err := uow.Tx().Exec(ctx, enqueueMessageQuery, args...)
+	return err
+}
+
+// Захват продлевает срок следующей попытки, поэтому сообщение, не обработанное
+// из-за остановки экземпляра, будет доставлено повторно после окончания захвата
+const claimMessagesQuery = `UPDATE outbox_message
+SET attempts = attempts + 1, next_attempt_at = $3
+WHERE id IN (
+	SELECT id FROM outbox_message
+	WHERE status = 'pending' AND next_attempt_at <= $1
+	ORDER BY next_attempt_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, user_id, payload, attempts, created_at`
+
+func (o *PgOutbox) Claim(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]Message, error) {
+	args := []any{now, limit, leaseUntil}
+	o.log.Debug(ctx, "executing query", slog.String("query", claimMessagesQuery), slog.Any("args", args))
+	rows, err := o.pool.Query(ctx, claimMessagesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Message, error) {
+		var m Message
+		err := row.Scan(&m.Id, &m.Kind, &m.UserId, &m.Payload, &m.Attempts, &m.CreatedAt)
+		return m, err
+	})
+}
+
+const deleteMessageQuery = `DELETE FROM outbox_message WHERE id = $1`
+
+func (o *PgOutbox) Delete(ctx context.Context, id uuid.UUID) error {
+	o.log.Debug(ctx, "executing query", slog.String("query", deleteMessageQuery), slog.String("id", id.String()))
+	_, err := o.pool.Exec(ctx, deleteMessageQuery, id)
+	return err
+}
+
+const retryMessageQuery = `UPDATE outbox_message SET next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+func (o *PgOutbox) Retry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	args := []any{id, nextAttemptAt, lastError}
+	o.log.Debug(ctx, "executing query", slog.String("query", retryMessageQuery), slog.Any("args", args))
+	_, err := o.pool.Exec(ctx, retryMessageQuery, args...)
+	return err
+}
+
+const postponeMessageQuery = `UPDATE outbox_message SET next_attempt_at = $2, attempts = attempts - 1 WHERE id = $1`
+
+func (o *PgOutbox) Postpone(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time) error {
+	args := []any{id, nextAttemptAt}
+	o.log.Debug(ctx, "executing query", slog.String("query", postponeMessageQuery), slog.Any("args", args))
+	_, err := o.pool.Exec(ctx, postponeMessageQuery, args...)
+	return err
+}
+
+const deadLetterMessageQuery = `UPDATE outbox_message SET status = 'dead', last_error = $2 WHERE id = $1`
+
+func (o *PgOutbox) DeadLetter(ctx context.Context, id uuid.UUID, lastError string) error {
+	args := []any{id, lastError}
+	o.log.Debug(ctx, "executing query", slog.String("query", deadLetterMessageQuery), slog.Any("args", args))
+	_, err := o.pool.Exec(ctx, deadLetterMessageQuery, args...)
+	return err
+}
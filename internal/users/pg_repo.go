@@ -0,0 +1,71 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/x0k/medods-authentication-service/internal/lib/logger"
+	"github.com/x0k/medods-authentication-service/internal/shared"
+)
+
+type Status string
+
+const (
+	ActiveStatus Status = "active"
+	// Заблокированные пользователи не могут входить и обновлять токены,
+	// но продолжают получать предупреждения
+	BlockedStatus Status = "blocked"
+)
+
+type PgRepository struct {
+	log  *logger.Logger
+	pool *pgxpool.Pool
+}
+
+func NewPgRepository(log *logger.Logger, pool *pgxpool.Pool) *PgRepository {
+	return &PgRepository{
+		log:  log,
+		pool: pool,
+	}
+}
+
+const userExistsQuery = `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND status = $2)`
+
+func (r *PgRepository) UserExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", userExistsQuery), slog.String("user_id", id.String()))
+	var exists bool
+	err := r.pool.QueryRow(ctx, userExistsQuery, id, ActiveStatus).Scan(&exists)
+	return exists, err
+}
+
+const emailByIdQuery = `SELECT email FROM users WHERE id = $1`
+
+func (r *PgRepository) EmailById(ctx context.Context, id uuid.UUID) (string, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", emailByIdQuery), slog.String("user_id", id.String()))
+	var email string
+	err := r.pool.QueryRow(ctx, emailByIdQuery, id).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", shared.ErrNotFound
+	}
+	return email, err
+}
+
+const phoneByIdQuery = `SELECT phone FROM users WHERE id = $1`
+
+// Возвращает `shared.ErrNotFound` и для пользователей без номера телефона
+func (r *PgRepository) PhoneById(ctx context.Context, id uuid.UUID) (string, error) {
+	r.log.Debug(ctx, "executing query", slog.String("query", phoneByIdQuery), slog.String("user_id", id.String()))
+	var phone *string
+	err := r.pool.QueryRow(ctx, phoneByIdQuery, id).Scan(&phone)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && phone == nil) {
+		return "", shared.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return *phone, nil
+}
@@ -0,0 +1 @@
+DROP TABLE signing_key;
\ No newline at end of file
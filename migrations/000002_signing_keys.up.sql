@@ -0,0 +1,13 @@
+CREATE TABLE
+  signing_key (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    material BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+  );
+
+CREATE UNIQUE INDEX signing_key_active_idx ON signing_key ((retired_at IS NULL))
+WHERE
+  retired_at IS NULL;
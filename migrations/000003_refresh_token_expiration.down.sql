@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+DROP COLUMN expires_at;
\ No newline at end of file
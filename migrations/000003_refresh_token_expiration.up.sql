@@ -0,0 +1,6 @@
+ALTER TABLE refresh_token
+ADD COLUMN expires_at TIMESTAMPTZ NOT NULL DEFAULT now() + INTERVAL '30 days';
+
+ALTER TABLE refresh_token
+ALTER COLUMN expires_at
+DROP DEFAULT;
@@ -0,0 +1,3 @@
+ALTER TABLE refresh_token
+DROP COLUMN session_started_at,
+DROP COLUMN last_used_at;
\ No newline at end of file
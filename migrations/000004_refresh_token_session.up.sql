@@ -0,0 +1,3 @@
+ALTER TABLE refresh_token
+ADD COLUMN session_started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+ADD COLUMN last_used_at TIMESTAMPTZ NOT NULL DEFAULT now();
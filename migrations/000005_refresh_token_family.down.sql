@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+DROP COLUMN family_id;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE refresh_token
+ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+ALTER TABLE refresh_token
+ALTER COLUMN family_id
+DROP DEFAULT;
+
+CREATE INDEX refresh_token_family_idx ON refresh_token (user_id, family_id);
@@ -0,0 +1 @@
+DROP TABLE revoked_access_token;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE
+  revoked_access_token (
+    jti UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+  );
+
+CREATE INDEX revoked_access_token_expires_at_idx ON revoked_access_token (expires_at);
@@ -0,0 +1 @@
+DROP TABLE user_token_version;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE
+  user_token_version (
+    user_id UUID PRIMARY KEY,
+    version BIGINT NOT NULL
+  );
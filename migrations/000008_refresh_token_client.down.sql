@@ -0,0 +1,3 @@
+ALTER TABLE refresh_token
+DROP COLUMN last_ip,
+DROP COLUMN user_agent;
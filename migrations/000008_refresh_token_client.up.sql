@@ -0,0 +1,3 @@
+ALTER TABLE refresh_token
+ADD COLUMN last_ip TEXT NOT NULL DEFAULT '',
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
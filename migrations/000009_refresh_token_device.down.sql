@@ -0,0 +1,5 @@
+DROP INDEX refresh_token_last_used_idx;
+
+ALTER TABLE refresh_token
+DROP COLUMN created_at,
+DROP COLUMN refresh_count;
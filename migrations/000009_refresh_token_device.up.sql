@@ -0,0 +1,9 @@
+ALTER TABLE refresh_token
+ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+ADD COLUMN refresh_count BIGINT NOT NULL DEFAULT 0;
+
+UPDATE refresh_token
+SET
+  created_at = session_started_at;
+
+CREATE INDEX refresh_token_last_used_idx ON refresh_token (user_id, last_used_at DESC);
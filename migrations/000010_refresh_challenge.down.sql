@@ -0,0 +1 @@
+DROP TABLE refresh_challenge;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE
+  refresh_challenge (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    access_token_hash BYTEA NOT NULL,
+    code_hash BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL
+  );
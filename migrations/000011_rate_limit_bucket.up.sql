@@ -0,0 +1,9 @@
+CREATE TABLE
+  rate_limit_bucket (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL
+  );
+
+CREATE INDEX rate_limit_bucket_full_at_idx ON rate_limit_bucket (full_at);
@@ -0,0 +1,16 @@
+CREATE TABLE
+  audit_event (
+    id UUID PRIMARY KEY,
+    type TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    device_id BYTEA,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+  );
+
+CREATE INDEX audit_event_user_id_created_at_idx ON audit_event (user_id, created_at);
+
+CREATE INDEX audit_event_created_at_idx ON audit_event (created_at);
@@ -0,0 +1,4 @@
+DROP INDEX audit_event_ip_idx;
+
+ALTER TABLE audit_event
+DROP COLUMN ip;
@@ -0,0 +1,11 @@
+ALTER TABLE audit_event
+ADD COLUMN ip INET;
+
+UPDATE audit_event
+SET
+  ip = ip_address::INET
+WHERE
+  ip_address ~ '^[0-9.]+$'
+  OR ip_address ~ '^[0-9a-fA-F:]+$';
+
+CREATE INDEX audit_event_ip_idx ON audit_event USING gist (ip inet_ops);
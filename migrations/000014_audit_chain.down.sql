@@ -0,0 +1,7 @@
+DROP TABLE audit_checkpoint;
+
+DROP INDEX audit_event_unsealed_idx;
+
+ALTER TABLE audit_event
+DROP COLUMN seq,
+DROP COLUMN hash;
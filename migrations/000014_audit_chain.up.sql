@@ -0,0 +1,15 @@
+ALTER TABLE audit_event
+ADD COLUMN seq BIGINT UNIQUE,
+ADD COLUMN hash BYTEA;
+
+CREATE INDEX audit_event_unsealed_idx ON audit_event (created_at, id)
+WHERE
+  seq IS NULL;
+
+CREATE TABLE
+  audit_checkpoint (
+    seq BIGINT PRIMARY KEY,
+    hash BYTEA NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+  );
@@ -0,0 +1,16 @@
+CREATE TABLE
+  outbox_message (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+  );
+
+CREATE INDEX outbox_message_pending_idx ON outbox_message (next_attempt_at)
+WHERE
+  status = 'pending';
@@ -0,0 +1,5 @@
+CREATE TABLE
+  used_report_token (
+    id UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+  );
@@ -0,0 +1,11 @@
+CREATE TABLE
+  notification_preferences (
+    user_id UUID PRIMARY KEY,
+    events TEXT[] NOT NULL,
+    channels TEXT[] NOT NULL,
+    quiet_hours_start SMALLINT,
+    quiet_hours_end SMALLINT,
+    quiet_hours_timezone TEXT,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL
+  );
@@ -0,0 +1 @@
+DROP TABLE warning_aggregate;
@@ -0,0 +1,19 @@
+CREATE TABLE
+  warning_aggregate (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    key TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    ip_addresses TEXT[] NOT NULL,
+    occurrences INT NOT NULL,
+    delivered INT NOT NULL,
+    locale TEXT NOT NULL,
+    report_url TEXT NOT NULL,
+    first_at TIMESTAMPTZ NOT NULL,
+    last_at TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL
+  );
+
+CREATE INDEX warning_aggregate_user_key_idx ON warning_aggregate (user_id, key, window_end);
+
+CREATE INDEX warning_aggregate_window_end_idx ON warning_aggregate (window_end);
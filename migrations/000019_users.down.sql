@@ -0,0 +1,19 @@
+ALTER TABLE outbox_message
+DROP CONSTRAINT outbox_message_user_id_fkey;
+
+ALTER TABLE warning_aggregate
+DROP CONSTRAINT warning_aggregate_user_id_fkey;
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_user_id_fkey;
+
+ALTER TABLE refresh_challenge
+DROP CONSTRAINT refresh_challenge_user_id_fkey;
+
+ALTER TABLE user_token_version
+DROP CONSTRAINT user_token_version_user_id_fkey;
+
+ALTER TABLE refresh_token
+DROP CONSTRAINT refresh_token_user_id_fkey;
+
+DROP TABLE users;
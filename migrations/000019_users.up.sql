@@ -0,0 +1,64 @@
+CREATE TABLE
+  users (
+    id UUID PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    phone TEXT,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'blocked')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+  );
+
+INSERT INTO
+  users (id, email)
+SELECT
+  user_id,
+  user_id::TEXT || '@users.invalid'
+FROM
+  (
+    SELECT
+      user_id
+    FROM
+      refresh_token
+    UNION
+    SELECT
+      user_id
+    FROM
+      user_token_version
+    UNION
+    SELECT
+      user_id
+    FROM
+      refresh_challenge
+    UNION
+    SELECT
+      user_id
+    FROM
+      notification_preferences
+    UNION
+    SELECT
+      user_id
+    FROM
+      warning_aggregate
+    UNION
+    SELECT
+      user_id
+    FROM
+      outbox_message
+  ) AS known_users;
+
+ALTER TABLE refresh_token
+ADD CONSTRAINT refresh_token_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE user_token_version
+ADD CONSTRAINT user_token_version_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE refresh_challenge
+ADD CONSTRAINT refresh_challenge_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE notification_preferences
+ADD CONSTRAINT notification_preferences_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE warning_aggregate
+ADD CONSTRAINT warning_aggregate_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE outbox_message
+ADD CONSTRAINT outbox_message_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
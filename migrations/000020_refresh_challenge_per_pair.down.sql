@@ -0,0 +1,4 @@
+DROP INDEX refresh_challenge_access_token_hash_idx;
+
+ALTER TABLE refresh_challenge
+DROP COLUMN pair_expires_at;
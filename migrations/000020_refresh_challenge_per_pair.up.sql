@@ -0,0 +1,6 @@
+DELETE FROM refresh_challenge;
+
+ALTER TABLE refresh_challenge
+ADD COLUMN pair_expires_at TIMESTAMPTZ NOT NULL;
+
+CREATE UNIQUE INDEX refresh_challenge_access_token_hash_idx ON refresh_challenge (access_token_hash);
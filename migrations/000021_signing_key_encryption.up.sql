@@ -0,0 +1 @@
+ALTER TABLE signing_key ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT false;